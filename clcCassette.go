@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+)
+
+// cassettes swap the HTTP transport underneath the client (see clientOptions), so they apply to
+// whatever is logged in now or later.  Replaying needs the same logins and commands as were recorded, in the same order.
+func (app *AppState) cmdCassette(argMode, argFile string) {
+	if argMode == "status" {
+		if app.recorder != nil {
+			fmt.Printf("recording to %s, %d interactions so far\n", app.cassette, app.recorder.Count())
+		} else if app.player != nil {
+			fmt.Printf("replaying from %s, %d interactions not yet used\n", app.cassette, app.player.Remaining())
+		} else {
+			fmt.Printf("no cassette, calls go to the network\n")
+		}
+		return
+	}
+
+	if argMode == "off" {
+		app.cassetteOff()
+		return
+	}
+
+	if ((argMode != "record") && (argMode != "replay")) || (argFile == "") {
+		app.badCommand()
+		return
+	}
+
+	app.cassetteOff()
+
+	if argMode == "record" {
+		app.recorder = NewCassetteRecorder(argFile, nil)
+		fmt.Printf("recording HTTP calls to %s\n", argFile)
+	} else {
+		c, err := LoadCassette(argFile)
+		if err != nil {
+			app.failf("could not read cassette: %s\n", err.Error())
+			return
+		}
+
+		app.player = NewCassettePlayer(c)
+		fmt.Printf("replaying %d HTTP interactions from %s\n", len(c.Interactions), argFile)
+	}
+
+	app.cassette = argFile
+	app.reconfigure()
+}
+
+func (app *AppState) cassetteOff() {
+	if app.recorder != nil {
+		fmt.Printf("recorded %d interactions to %s\n", app.recorder.Count(), app.cassette)
+	} else if app.player != nil {
+		if n := app.player.Remaining(); n > 0 {
+			fmt.Printf("warning: %d interactions in %s were not replayed\n", n, app.cassette)
+		}
+	}
+
+	app.recorder = nil
+	app.player = nil
+	app.cassette = ""
+	app.reconfigure()
+}
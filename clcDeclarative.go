@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (app *AppState) cmdPlan(argFile string) {
+	if argFile == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	doc, err := LoadConfigDocument(argFile)
+	if err != nil {
+		app.failf("could not read config: %s\n", err.Error())
+		return
+	}
+
+	plan, err := PlanConfig(app.clc, doc)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(plan, func() {
+		printPlan(plan)
+	})
+	app.bindResult("plan", plan, "")
+}
+
+func (app *AppState) cmdApply(argFile string) {
+	if argFile == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	doc, err := LoadConfigDocument(argFile)
+	if err != nil {
+		app.failf("could not read config: %s\n", err.Error())
+		return
+	}
+
+	plan, err := PlanConfig(app.clc, doc)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	if app.textOutput() {
+		printPlan(plan)
+	}
+
+	if !plan.HasChanges() {
+		app.emit(plan, func() {})
+		return
+	}
+
+	err = ApplyPlan(app.clc, plan, func(lb *LBPlan, step *PlanStep) {
+		if !app.textOutput() {
+			return
+		} else if step == nil {
+			fmt.Printf("creating load balancer %s/%s\n", lb.DataCenter, lb.Name)
+		} else {
+			fmt.Printf("%s/%s: %s port %d %s\n", lb.DataCenter, lb.Name, step.Action, step.Port, step.PoolID)
+		}
+	})
+	if err != nil {
+		app.failf("apply failed: %s\n", err.Error())
+		return
+	}
+
+	app.emit(plan, func() { // the plan now carries the new LBIDs and PoolIDs
+		fmt.Printf("apply complete\n")
+	})
+	app.bindResult("plan", plan, "")
+}
+
+func printPlan(plan *ConfigPlan) {
+	nCreateLB, nCreate, nUpdate, nDelete := 0, 0, 0, 0
+
+	for _, lb := range plan.LoadBalancers {
+		if lb.CreateLB {
+			nCreateLB++
+			fmt.Printf("LB %s/%s: + create load balancer\n", lb.DataCenter, lb.Name)
+		} else if len(lb.Steps) == 0 {
+			fmt.Printf("LB %s/%s (lbid %s): no changes\n", lb.DataCenter, lb.Name, lb.LBID)
+		} else {
+			fmt.Printf("LB %s/%s (lbid %s):\n", lb.DataCenter, lb.Name, lb.LBID)
+		}
+
+		for _, step := range lb.Steps {
+			if step.Action == PLAN_DELETE_POOL {
+				nDelete++
+				fmt.Printf("  - delete pool port:%d, PoolID:%s\n", step.Port, step.PoolID)
+
+			} else if step.Action == PLAN_UPDATE_POOL {
+				nUpdate++
+				fmt.Printf("  ~ update pool port:%d, PoolID:%s\n", step.Port, step.PoolID)
+				for _, change := range step.Changes {
+					fmt.Printf("      %s\n", change)
+				}
+
+			} else if step.Action == PLAN_CREATE_POOL {
+				nCreate++
+				fmt.Printf("  + create pool port:%d, method:%s, mode:%s, nodes:[ %s ]\n",
+					step.Port, step.Pool.Method, step.Pool.Mode, nodeListString(step.Pool.Nodes))
+			}
+		}
+
+		for _, warning := range lb.Warnings {
+			fmt.Printf("  ! %s\n", warning)
+		}
+	}
+
+	fmt.Printf("plan: %d load balancers to create, %d pools to create, %d to update, %d to delete\n",
+		nCreateLB, nCreate, nUpdate, nDelete)
+}
+
+func (app *AppState) cmdLoadbalancerExport(parts []string) { // parts[0:2]="LB export"
+	all := false
+	annotations := true
+	args := make([]string, 0, 3)
+
+	for _, s := range parts[2:] {
+		if s == "--all" {
+			all = true
+		} else if s == "--no-annotations" {
+			annotations = false
+		} else if strings.HasPrefix(s, "--") {
+			app.failf("unknown option: %s\n", s)
+			return
+		} else {
+			args = append(args, s)
+		}
+	}
+
+	if (all && (len(args) > 1)) || (!all && ((len(args) < 2) || (len(args) > 3))) {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	var doc *ConfigDocument
+	var err error
+	file := ""
+
+	if all {
+		doc, err = ExportAll(app.clc, annotations)
+		if len(args) == 1 {
+			file = args[0]
+		}
+	} else {
+		var lbcfg *LBConfig
+		lbcfg, err = ExportLB(app.clc, args[0], args[1], annotations)
+		if err == nil {
+			doc = &ConfigDocument{LoadBalancers: []LBConfig{*lbcfg}}
+		}
+		if len(args) == 3 {
+			file = args[2]
+		}
+	}
+
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	if file != "" {
+		err = WriteConfigDocument(file, doc)
+		if err != nil {
+			app.failf("could not write %s: %s\n", file, err.Error())
+			return
+		}
+
+		fmt.Printf("exported %d load balancers to %s\n", len(doc.LoadBalancers), file)
+		return
+	}
+
+	app.emit(doc, func() { // a config document has no text form of its own, show the file contents
+		renderYAML(doc)
+	})
+}
+
+// "drift [--interval 5m] [--count N] [--report file.json] configfile".  Sets exit code 2 when there is drift.
+// With --interval it checks repeatedly, forever unless --count is given, and a check that fails doesn't
+// stop the next; any failure fails the command once it is done.
+func (app *AppState) cmdDrift(parts []string) { // parts[0]="drift"
+	var interval time.Duration
+	count := 0
+	reportFile := ""
+	configFile := ""
+
+	for idx := 1; idx < len(parts); idx++ {
+		s := parts[idx]
+		if ((s == "--interval") || (s == "--count") || (s == "--report")) && (idx+1 < len(parts)) {
+			idx++
+			value := parts[idx]
+			var err error
+
+			if s == "--interval" {
+				interval, err = time.ParseDuration(value)
+			} else if s == "--count" {
+				count, err = strconv.Atoi(value)
+			} else {
+				reportFile = value
+			}
+
+			if err != nil {
+				app.failf("invalid %s: %s\n", s, value)
+				return
+			}
+		} else if strings.HasPrefix(s, "--") || (configFile != "") {
+			app.badCommand()
+			return
+		} else {
+			configFile = s
+		}
+	}
+
+	if configFile == "" {
+		app.badCommand()
+		return
+	}
+
+	if (interval == 0) && (count == 0) {
+		count = 1
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	failed, run := 0, 1
+	for ; ; run++ {
+		err := app.checkDrift(configFile, reportFile)
+		if (err != nil) && (interval == 0) {
+			app.failf("%s\n", err.Error())
+			return
+		} else if err != nil { // unattended monitoring goes on, the failure counts at the end
+			failed++
+			app.failf("drift check %d: %s\n", run, err.Error())
+		}
+
+		if (count > 0) && (run >= count) {
+			break
+		}
+
+		time.Sleep(interval)
+	}
+
+	if failed > 0 {
+		app.failf("%d of %d drift checks failed\n", failed, run)
+	}
+}
+
+// one drift check, reported and bound to "drift".  exitCode is 2 if it found drift.
+func (app *AppState) checkDrift(configFile, reportFile string) error {
+	doc, err := LoadConfigDocument(configFile) // re-read, the file may have been updated in the meantime
+	if err != nil {
+		return fmt.Errorf("could not read config: %s", err.Error())
+	}
+
+	report, err := DetectDrift(app.clc, doc)
+	if err != nil {
+		return fmt.Errorf("remote call failed, err=%s", err.Error())
+	}
+
+	app.emit(report, func() {
+		printDriftReport(report)
+	})
+	app.bindResult("drift", report, "")
+
+	if reportFile != "" {
+		err = writeDriftReport(reportFile, report)
+		if err != nil {
+			return fmt.Errorf("could not write %s: %s", reportFile, err.Error())
+		}
+	}
+
+	app.exitCode = 0
+	if report.Drifted {
+		app.exitCode = 2
+	}
+
+	return nil
+}
+
+func printDriftReport(report *DriftReport) {
+	if !report.Drifted {
+		fmt.Printf("drift check at %s: no drift\n", report.CheckedAt)
+		return
+	}
+
+	fmt.Printf("drift check at %s: %d differences\n", report.CheckedAt, len(report.Items))
+	for _, item := range report.Items {
+		where := fmt.Sprintf("%s/%s", item.DataCenter, item.LBName)
+		if item.Port != 0 {
+			where += fmt.Sprintf(" port %d", item.Port)
+		}
+		if item.PoolID != "" {
+			where += fmt.Sprintf(" (PoolID %s)", item.PoolID)
+		}
+
+		fmt.Printf("  %s: %s", where, item.Kind)
+		if item.Field != "" {
+			fmt.Printf(" %s", item.Field)
+		}
+		if item.Live != "" {
+			fmt.Printf(" live=%s", item.Live)
+		}
+		if item.Desired != "" {
+			fmt.Printf(" desired=%s", item.Desired)
+		}
+		fmt.Printf("\n")
+	}
+}
+
+// written to a temporary file and renamed, so a reader never sees half a report
+func writeDriftReport(path string, report *DriftReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, append(data, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// "--cross-dc" anywhere in parts picks the cross-datacenter policies.  Returns parts without it.
+func takeCrossDC(parts []string) ([]string, bool) {
+	ret := make([]string, 0, len(parts))
+	crossDC := false
+	for _, s := range parts {
+		if s == "--cross-dc" {
+			crossDC = true
+		} else {
+			ret = append(ret, s)
+		}
+	}
+	return ret, crossDC
+}
+
+func (app *AppState) cmdFirewallList(parts []string) { // parts[0:2]="firewall list"
+	parts, crossDC := takeCrossDC(parts)
+	if len(parts) != 3 {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	policies, err := app.clc.listFirewallPolicies(parts[2], crossDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(policies, func() {
+		if len(policies) == 0 {
+			fmt.Printf("no firewall policies\n")
+		}
+		for idx := range policies {
+			printFirewallPolicy(&policies[idx])
+		}
+	})
+	app.bindResult("firewalls", policies, "")
+}
+
+func (app *AppState) cmdFirewallDetails(parts []string) { // parts[0:2]="firewall details"
+	parts, crossDC := takeCrossDC(parts)
+	if len(parts) != 4 {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	policy, err := app.clc.inspectFirewallPolicy(parts[2], crossDC, parts[3])
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emitFirewallPolicy(policy)
+}
+
+func (app *AppState) emitFirewallPolicy(policy *FirewallPolicy) {
+	app.emit(policy, func() {
+		printFirewallPolicy(policy)
+	})
+	app.bindResult("firewall", policy, policy.PolicyID)
+}
+
+func printFirewallPolicy(policy *FirewallPolicy) {
+	state := "enabled"
+	if !policy.Enabled {
+		state = "disabled"
+	}
+
+	to := strings.Join(policy.Destinations, ",")
+	if policy.CrossDC {
+		to = fmt.Sprintf("%s in %s", to, policy.DestinationDC)
+	}
+	if policy.DestinationAccount != "" {
+		to = fmt.Sprintf("%s (account %s)", to, policy.DestinationAccount)
+	}
+
+	ports := "all traffic"
+	if len(policy.Ports) > 0 {
+		ports = "ports:[ " + strings.Join(policy.Ports, " ") + " ]"
+	}
+
+	fmt.Printf("firewall %s: dc=%s, %s, status=%s\n", policy.PolicyID, policy.DataCenter, state, policy.Status)
+	fmt.Printf("    %s -> %s, %s\n", strings.Join(policy.Sources, ","), to, ports)
+}
+
+// firewallFromArgs applies sources=, destinations=, ports=, account=, destdc= and enabled= to policy.
+// Returns false (having said why) on a bad argument.
+func (app *AppState) firewallFromArgs(policy *FirewallPolicy, args []string) bool {
+	for _, s := range args {
+		key, value, found := strings.Cut(s, "=")
+		if !found {
+			app.badCommand()
+			return false
+		}
+
+		switch key {
+		case "sources":
+			policy.Sources = splitList(value)
+		case "destinations":
+			policy.Destinations = splitList(value)
+		case "ports":
+			policy.Ports = splitList(value)
+		case "account":
+			policy.DestinationAccount = strings.ToUpper(value)
+		case "destdc":
+			policy.DestinationDC = value
+		case "enabled":
+			if (value != "true") && (value != "false") {
+				app.failf("enabled= takes true or false\n")
+				return false
+			}
+			policy.Enabled = (value == "true")
+		default:
+			app.badCommand()
+			return false
+		}
+	}
+
+	if err := ValidateFirewallPolicy(policy); err != nil {
+		app.failf("%s\n", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// "a,b,,c" -> [a b c]
+func splitList(s string) []string {
+	ret := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			ret = append(ret, item)
+		}
+	}
+	return ret
+}
+
+func (app *AppState) cmdFirewallCreate(parts []string) { // parts[0:2]="firewall create"
+	parts, crossDC := takeCrossDC(parts)
+	if len(parts) < 3 {
+		app.badCommand()
+		return
+	}
+
+	argDC := parts[2]
+	policy := &FirewallPolicy{CrossDC: crossDC, Enabled: true}
+	if !app.firewallFromArgs(policy, parts[3:]) {
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	policyID, err := app.clc.createFirewallPolicy(argDC, policy)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	created, err := app.clc.inspectFirewallPolicy(argDC, crossDC, policyID)
+	if err != nil {
+		app.failf("firewall policy %s created, but could not be read back, err=%s\n", policyID, err.Error())
+		return
+	}
+
+	app.emitFirewallPolicy(created)
+}
+
+func (app *AppState) cmdFirewallUpdate(parts []string) { // parts[0:2]="firewall update"
+	parts, crossDC := takeCrossDC(parts)
+	if len(parts) < 5 {
+		app.badCommand()
+		return
+	}
+
+	argDC, argPolicyID := parts[2], parts[3]
+
+	if !app.haveClient() {
+		return
+	}
+
+	policy, err := app.clc.inspectFirewallPolicy(argDC, crossDC, argPolicyID)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	if crossDC {
+		for _, s := range parts[4:] {
+			if !strings.HasPrefix(s, "enabled=") {
+				app.failf("a cross-DC firewall policy can only be enabled or disabled, not changed\n")
+				return
+			}
+		}
+	}
+
+	if !app.firewallFromArgs(policy, parts[4:]) {
+		return
+	}
+
+	err = app.clc.updateFirewallPolicy(argDC, policy)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	updated, err := app.clc.inspectFirewallPolicy(argDC, crossDC, argPolicyID)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emitFirewallPolicy(updated)
+}
+
+func (app *AppState) cmdFirewallDelete(parts []string) { // parts[0:2]="firewall delete"
+	parts, crossDC := takeCrossDC(parts)
+	if len(parts) != 4 {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	err := app.clc.deleteFirewallPolicy(parts[2], crossDC, parts[3])
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("firewall policy deleted\n")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// jobTracker is the session's, made on first use so that it outlives logins
+func (app *AppState) jobTracker() *JobTracker {
+	if app.jobs == nil {
+		app.jobs = NewJobTracker(defaultJobOptions)
+		app.jobs.Listen(app.jobProgress)
+	}
+	return app.jobs
+}
+
+// jobProgress reports a job's new status while a command waits on it.  How it ended is up to the command.
+func (app *AppState) jobProgress(event JobEvent) {
+	if event.Type != JOB_EVENT_STATUS {
+		return
+	}
+
+	line := fmt.Sprintf("%s: %s, %s after %s\n", event.Job.JobID, event.Job.Description, event.Job.Status,
+		event.Job.Elapsed().Round(time.Second))
+	if app.textOutput() {
+		fmt.Print(line)
+	} else {
+		fmt.Fprint(os.Stderr, line)
+	}
+}
+
+func (app *AppState) cmdJobs(parts []string) { // parts[0]="jobs"
+	if (len(parts) == 1) || ((len(parts) == 2) && (parts[1] == "--all")) {
+		app.cmdJobsList(len(parts) == 2)
+	} else if (parts[1] == "wait") && (len(parts) <= 3) {
+		app.cmdJobsWait(parts[2:])
+	} else {
+		app.badCommand()
+	}
+}
+
+func (app *AppState) cmdJobsList(all bool) {
+	tracker := app.jobTracker()
+	tracker.Refresh()
+	jobs := tracker.List(all)
+
+	app.emit(jobs, func() {
+		if len(jobs) == 0 {
+			fmt.Printf("no outstanding jobs\n")
+		}
+		for idx := range jobs {
+			printJob(&jobs[idx])
+		}
+	})
+	app.bindResult("jobs", jobs, "")
+}
+
+func (app *AppState) cmdJobsWait(ids []string) {
+	tracker := app.jobTracker()
+
+	if len(ids) == 0 {
+		for _, job := range tracker.List(false) {
+			ids = append(ids, job.JobID)
+		}
+	} else if _, ok := tracker.Get(ids[0]); !ok {
+		app.failf("no job %s, see \"jobs --all\"\n", ids[0])
+		return
+	}
+
+	if app.textOutput() && (len(ids) > 1) {
+		fmt.Printf("waiting for %d jobs\n", len(ids))
+	}
+
+	errs := make([]error, len(ids))
+	forEachParallel(len(ids), INSPECT_MAX_WORKERS, nil, func(idx int) {
+		errs[idx] = tracker.Wait(ids[idx])
+	})
+
+	jobs := make([]Job, len(ids))
+	failed := 0
+	for idx, id := range ids {
+		jobs[idx], _ = tracker.Get(id)
+		if errs[idx] != nil {
+			failed++
+		}
+	}
+
+	app.emit(jobs, func() {
+		if len(jobs) == 0 {
+			fmt.Printf("no outstanding jobs\n")
+		}
+		for idx := range jobs {
+			printJob(&jobs[idx])
+		}
+	})
+	app.bindResult("jobs", jobs, "")
+
+	if failed > 0 {
+		app.failf("%d of %d jobs did not succeed\n", failed, len(jobs))
+	}
+}
+
+func printJob(job *Job) {
+	fmt.Printf("%s: %s, %s (%s %s, status %s) after %s\n", job.JobID, job.Description, job.State, job.Kind, job.Ref,
+		job.Status, job.Elapsed().Round(time.Second))
+	if job.Result != "" {
+		fmt.Printf("    result: %s\n", job.Result)
+	}
+	if job.Error != "" {
+		fmt.Printf("    %s\n", job.Error)
+	}
+}
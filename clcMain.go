@@ -3,21 +3,29 @@ package main
 import (
 	"fmt"
 	"bufio"
+	"log/slog"
+	"net/http"
 	"os"
 	"strings"
 	"strconv"
+	"time"
 )
 
 
 func main() {
-	fmt.Printf("CenturyLinkCloud LBaaS client app\n")
-
-	in := bufio.NewReader(os.Stdin)
-
 	app := AppState {
 		clc: nil,
+		vars: make(map[string]string),
 	}
 
+	if len(os.Args) > 1 {	// "apiTool run script.clc" etc, no REPL
+		os.Exit(app.runCommandLine(os.Args[1:]))
+	}
+
+	fmt.Printf("CenturyLinkCloud LBaaS client app\n")
+
+	in := bufio.NewReader(os.Stdin)
+
 	for {  // infinite loop
 		fmt.Printf("\n> ")	// prompt
 		line, err := in.ReadString('\n')
@@ -42,14 +50,30 @@ func main() {
 }
 
 func processInputLine(app *AppState, in string) {
-	
-	parts := strings.Split(in, " ")
-	nonnull_parts := make([]string, 0, len(parts))
+	app.lastErr = nil	// each line starts clean, scripts look at this afterwards
 
-	for idx := 0; idx < len(parts); idx++ {	// strings.Split leaves empty strings in place for consecutive spaces
-		if parts[idx] != "" {
-			nonnull_parts = append(nonnull_parts, parts[idx])
-		}
+	if strings.HasPrefix(strings.TrimSpace(in), "#") {
+		return // comment line, mostly found in scripts
+	}
+
+	in, err := app.expandVars(in)
+	if err != nil {
+		app.failf("%s\n", err.Error())
+		return
+	}
+
+	processCommand(app, splitCommandLine(in))
+}
+
+// processCommand runs one command that is already split into words, from the REPL, a script or the command line
+func processCommand(app *AppState, parts []string) {
+	app.lineOutput = ""	// --output and --query apply to this line only
+	app.lineQuery = ""
+
+	nonnull_parts, err := app.takeOutputOptions(parts)
+	if err != nil {
+		app.failf("%s\n", err.Error())
+		return
 	}
 
 	if len(nonnull_parts) == 0 {
@@ -95,6 +119,45 @@ func processInputLine(app *AppState, in string) {
 	} else if cmd0 == "args" {
 		cmdArgs(nonnull_parts)
 
+	} else if cmd0 == "plan" {
+		app.cmdPlan(cmd1) // "plan file.yaml"
+
+	} else if cmd0 == "apply" {
+		app.cmdApply(cmd1) // "apply file.yaml"
+
+	} else if cmd0 == "drift" {
+		app.cmdDrift(nonnull_parts) // "drift [--interval 5m] [--count N] [--report file] file.yaml"
+
+	} else if cmd0 == "output" {
+		app.cmdOutput(cmd1) // "output json", sets the default for this session
+
+	} else if cmd0 == "set" {
+		app.cmdSet(cmd1, nonnull_parts) // "set name value"
+
+	} else if cmd0 == "unset" {
+		app.cmdUnset(cmd1) // "unset name"
+
+	} else if cmd0 == "vars" {
+		app.cmdVars() // "vars"
+
+	} else if cmd0 == "source" {
+		app.cmdSource(nonnull_parts) // "source [--continue-on-error] [--transcript file] file"
+
+	} else if cmd0 == "assert" {
+		app.cmdAssert(nonnull_parts) // "assert lb.status == ready"
+
+	} else if cmd0 == "cache" {
+		app.cmdCache(cmd1, cmd2) // "cache on [file]", "cache off|clear|status"
+
+	} else if cmd0 == "stats" {
+		app.cmdStats() // "stats"
+
+	} else if cmd0 == "debug" {
+		app.cmdDebug(cmd1) // "debug on|off|requests|responses|json|text"
+
+	} else if cmd0 == "cassette" {
+		app.cmdCassette(cmd1, cmd2) // "cassette record file", "cassette replay file", "cassette off|status"
+
 	} else if cmd0 == "auth" {
 		if cmd1 == "login" {
 			app.cmdAuthLogin(cmd2, cmd3) // "auth login user pass"
@@ -104,15 +167,21 @@ func processInputLine(app *AppState, in string) {
 			app.cmdAuthLogout() // "auth logout"
 		} else if cmd1 == "status" {
 			app.cmdAuthStatus() // "auth status"
+		} else if cmd1 == "token" {
+			app.cmdAuthToken() // "auth token"
+		} else if cmd1 == "fake" {
+			app.cmdAuthFake() // "auth fake"
 		} else {
-			cmdUsage()
+			app.badCommand()
 		}
 
 	} else if cmd0 == "DC" {
 		if cmd1 == "list" {
 			app.cmdDatacenterList() // "DC list"
+		} else if cmd1 == "details" {
+			app.cmdDatacenterDetails(cmd2) // "DC details dc"
 		} else {
-			cmdUsage()
+			app.badCommand()
 		}
 
 	} else if cmd0 == "LB" {
@@ -123,11 +192,95 @@ func processInputLine(app *AppState, in string) {
 		} else if cmd1 == "details" {
 			app.cmdLoadbalancerDetails(cmd2, cmd3) // "LB details dc lbid"
 		} else if cmd1 == "list" {
-			app.cmdLoadbalancerList() // "LB list"
+			app.cmdLoadbalancerList(nonnull_parts) // "LB list [--details]"
+		} else if cmd1 == "export" {
+			app.cmdLoadbalancerExport(nonnull_parts) // "LB export dc lbid [file]" or "LB export --all [file]"
+		} else {
+			app.badCommand()
+		}
+
+	} else if cmd0 == "server" {
+		if cmd1 == "list" {
+			app.cmdServerList(cmd2) // "server list [dc]"
+		} else if cmd1 == "find" {
+			app.cmdServerFind(cmd2, cmd3) // "server find text [dc]"
+		} else if cmd1 == "details" {
+			app.cmdServerDetails(cmd2) // "server details id"
+		} else if cmd1 == "create" {
+			app.cmdServerCreate(nonnull_parts) // "server create dc name template=... group=... cpu=N memory=GB [...] [--no-wait]"
+		} else if (serverOperationVerbs[cmd1] != "") || (cmd1 == "maintenance-mode") {
+			app.cmdServerOperation(nonnull_parts) // "server poweron id... [--no-wait]", "server maintenance-mode on|off id..."
+		} else {
+			app.badCommand()
+		}
+
+	} else if cmd0 == "template" {
+		if cmd1 == "list" {
+			app.cmdTemplateList(cmd2) // "template list dc"
+		} else {
+			app.badCommand()
+		}
+
+	} else if cmd0 == "group" {
+		if cmd1 == "list" {
+			app.cmdGroupList(cmd2) // "group list dc"
+		} else if cmd1 == "tree" {
+			app.cmdGroupTree(cmd2) // "group tree [dc]"
+		} else if cmd1 == "details" {
+			app.cmdGroupDetails(cmd2, cmd3) // "group details dc name|id"
+		} else {
+			app.badCommand()
+		}
+
+	} else if cmd0 == "publicip" {
+		if cmd1 == "list" {
+			app.cmdPublicIPList(cmd2) // "publicip list serverID"
+		} else if cmd1 == "details" {
+			app.cmdPublicIPDetails(cmd2, cmd3) // "publicip details serverID ip"
+		} else if cmd1 == "add" {
+			app.cmdPublicIPAdd(nonnull_parts) // "publicip add serverID ports=... [sources=...] [internal=ip] [--no-wait]"
+		} else if cmd1 == "update" {
+			app.cmdPublicIPUpdate(nonnull_parts) // "publicip update serverID ip [ports=...] [sources=...] [--no-wait]"
+		} else if cmd1 == "delete" {
+			app.cmdPublicIPDelete(nonnull_parts) // "publicip delete serverID ip [--no-wait]"
+		} else {
+			app.badCommand()
+		}
+
+	} else if cmd0 == "network" {
+		if cmd1 == "list" {
+			app.cmdNetworkList(cmd2) // "network list dc"
+		} else if cmd1 == "details" {
+			app.cmdNetworkDetails(nonnull_parts) // "network details dc networkID [ips=none|claimed|free|all]"
+		} else if cmd1 == "claim" {
+			app.cmdNetworkClaim(cmd2, cmd3) // "network claim dc [--no-wait]"
+		} else if cmd1 == "release" {
+			app.cmdNetworkRelease(cmd2, cmd3) // "network release dc networkID"
 		} else {
-			cmdUsage()
+			app.badCommand()
 		}
 
+	} else if cmd0 == "firewall" {
+		if cmd1 == "list" {
+			app.cmdFirewallList(nonnull_parts) // "firewall list dc [--cross-dc]"
+		} else if cmd1 == "details" {
+			app.cmdFirewallDetails(nonnull_parts) // "firewall details dc policyID [--cross-dc]"
+		} else if cmd1 == "create" {
+			app.cmdFirewallCreate(nonnull_parts) // "firewall create dc sources=... destinations=... ports=... [--cross-dc]"
+		} else if cmd1 == "update" {
+			app.cmdFirewallUpdate(nonnull_parts) // "firewall update dc policyID [enabled=true|false] ... [--cross-dc]"
+		} else if cmd1 == "delete" {
+			app.cmdFirewallDelete(nonnull_parts) // "firewall delete dc policyID [--cross-dc]"
+		} else {
+			app.badCommand()
+		}
+
+	} else if cmd0 == "operation" {
+		app.cmdOperation(cmd1, cmd2) // "operation status|wait operationID"
+
+	} else if cmd0 == "jobs" {
+		app.cmdJobs(nonnull_parts) // "jobs [--all]", "jobs wait [JobID]"
+
 	} else if cmd0 == "pool" {
 		if cmd1 == "create" {
 			app.cmdPoolCreate(cmd2, cmd3, nonnull_parts) // "pool create dc lbid"
@@ -135,16 +288,49 @@ func processInputLine(app *AppState, in string) {
 			app.cmdPoolUpdate(cmd2, cmd3, cmd4, nonnull_parts) // "pool update dc lbid poolID"
 		} else if cmd1 == "delete" {
 			app.cmdPoolDelete(cmd2, cmd3, cmd4) // "pool delete dc lbid poolID"
+		} else if cmd1 == "sync" {
+			app.cmdPoolSync(nonnull_parts) // "pool sync dc lbid poolID group [target=port] [--dry-run]"
 		} else {
-			cmdUsage()
+			app.badCommand()
 		}
 
 	} else {
-		cmdUsage()
+		app.badCommand()
 	}
 }
 
 
+// splits on spaces, except inside double quotes: pool update ... --query "{{range .nodes}}{{.targetIP}} {{end}}"
+func splitCommandLine(in string) []string {
+	parts := make([]string, 0, 8)
+	current := make([]byte, 0, len(in))
+	inQuotes := false
+	hasToken := false
+
+	for idx := 0; idx < len(in); idx++ {
+		c := in[idx]
+		if c == '"' {
+			inQuotes = !inQuotes
+			hasToken = true	// "" is an empty argument, not nothing
+		} else if (c == ' ' || c == '\t') && !inQuotes {
+			if hasToken {
+				parts = append(parts, string(current))
+			}
+			current = current[:0]
+			hasToken = false
+		} else {
+			current = append(current, c)
+			hasToken = true
+		}
+	}
+
+	if hasToken {
+		parts = append(parts, string(current))
+	}
+
+	return parts
+}
+
 func cmdUsage() {	// does not consider the args
 	fmt.Printf("Usage:\n")
 	fmt.Printf("\thelp\n")
@@ -153,14 +339,76 @@ func cmdUsage() {	// does not consider the args
 	fmt.Printf("\tauth env\n")
 	fmt.Printf("\tauth logout\n")	
 	fmt.Printf("\tauth status\n")
+	fmt.Printf("\tauth token         (prints the export lines to reuse this login, including the token)\n")
+	fmt.Printf("\tauth fake          (in-memory LBaaS, nothing leaves this process)\n")
+	fmt.Printf("\tcache on [file]    (cache DC and LB lookups, kept in file between sessions)\n")
+	fmt.Printf("\tcache off|clear|status\n")
+	fmt.Printf("\tstats              (calls, throttling and rate limit waits per host)\n")
+	fmt.Printf("\tdebug on|off|requests|responses    (HTTP dumps, secrets redacted)\n")
+	fmt.Printf("\tdebug json|text    (log format)\n")
+	fmt.Printf("\tcassette record file    (save HTTP calls, secrets scrubbed)\n")
+	fmt.Printf("\tcassette replay file    (answer HTTP calls from the file, no network)\n")
+	fmt.Printf("\tcassette off|status\n")
 	fmt.Printf("\tDC list\n")	
+	fmt.Printf("\tDC details DC      (templates, storage, networks, load balancing, bare metal and import options)\n")
 	fmt.Printf("\tLB create DC name desc\n")	
 	fmt.Printf("\tLB delete DC LBID\n")	
 	fmt.Printf("\tLB details DC LBID\n")	
-	fmt.Printf("\tLB list\n")	
+	fmt.Printf("\tLB list [--details [--workers N] [--rate perSecond]]\n")	
+	fmt.Printf("\tLB export [--no-annotations] DC LBID [file]\n")
+	fmt.Printf("\tLB export [--no-annotations] --all [file]\n")
+	fmt.Printf("\tserver list [DC]\n")
+	fmt.Printf("\tserver find text [DC]    (by name, description or IP)\n")
+	fmt.Printf("\tserver details ServerID\n")
+	fmt.Printf("\tserver poweron|reboot ServerID... [--no-wait]\n")
+	fmt.Printf("\tserver poweroff|shutdown|pause ServerID... [--drain] [--no-wait]    (--drain: out of LB pools first)\n")
+	fmt.Printf("\tserver maintenance-mode on|off ServerID... [--no-wait]\n")
+	fmt.Printf("\tserver create DC name template=T group=name|GroupID cpu=N memory=GB [network=name|ID] [ip=IP]\n")
+	fmt.Printf("\t      [password=P] [description=D] [type=standard|hyperscale] [storage=standard|premium|hyperscale]\n")
+	fmt.Printf("\t      [field.ID=value...] [--no-wait]\n")
+	fmt.Printf("\ttemplate list DC\n")
+	fmt.Printf("\tgroup list DC\n")
+	fmt.Printf("\tgroup tree [DC]\n")
+	fmt.Printf("\tgroup details DC name|GroupID\n")
+	fmt.Printf("\tpublicip list ServerID\n")
+	fmt.Printf("\tpublicip details ServerID IP\n")
+	fmt.Printf("\tpublicip add ServerID ports=tcp/80,tcp/8000-8010,icmp [sources=CIDR,...] [internal=IP] [--no-wait]\n")
+	fmt.Printf("\tpublicip update ServerID IP [ports=...] [sources=CIDR,...|any] [--no-wait]\n")
+	fmt.Printf("\tpublicip delete ServerID IP [--no-wait]\n")
+	fmt.Printf("\tnetwork list DC\n")
+	fmt.Printf("\tnetwork details DC NetworkID [ips=none|claimed|free|all]    (default claimed)\n")
+	fmt.Printf("\tnetwork claim DC [--no-wait]\n")
+	fmt.Printf("\tnetwork release DC NetworkID\n")
+	fmt.Printf("\tfirewall list DC [--cross-dc]\n")
+	fmt.Printf("\tfirewall details DC PolicyID [--cross-dc]\n")
+	fmt.Printf("\tfirewall create DC sources=CIDR,... destinations=CIDR,... ports=any|tcp/80,udp/53,icmp,... [account=alias] [enabled=false]\n")
+	fmt.Printf("\tfirewall create DC --cross-dc sources=CIDR destinations=CIDR destdc=DC [account=alias] [enabled=false]\n")
+	fmt.Printf("\tfirewall update DC PolicyID [sources=...] [destinations=...] [ports=...] [enabled=true|false]\n")
+	fmt.Printf("\tfirewall update DC PolicyID --cross-dc enabled=true|false\n")
+	fmt.Printf("\tfirewall delete DC PolicyID [--cross-dc]\n")
+	fmt.Printf("\toperation status|wait OperationID    (what --no-wait left running)\n")
+	fmt.Printf("\tjobs [--all]       (operations and LB requests this session is still waiting on)\n")
+	fmt.Printf("\tjobs wait [JobID]  (all outstanding jobs without a JobID)\n")
 	fmt.Printf("\tpool create DC LBID <pool details>\n")
 	fmt.Printf("\tpool update DC LBID PoolID <pool details>\n")	
-	fmt.Printf("\tpool delete DC LBID PoolID\n")	
+	fmt.Printf("\tpool delete DC LBID PoolID\n")
+	fmt.Printf("\tpool sync DC LBID PoolID group [target=port] [--dry-run]    (nodes = the group's active servers)\n")
+	fmt.Printf("\t  (pool details: port= method= persistence= timeout= mode= target= nodes=IP,server:ID,group:name,...)\n")	
+	fmt.Printf("\tplan configfile\n")
+	fmt.Printf("\tapply configfile\n")
+	fmt.Printf("\tdrift [--interval 5m] [--count N] [--report file.json] configfile\n")
+	fmt.Printf("\toutput [text|json|yaml|csv|table|wide]\n")
+	fmt.Printf("\t  (any command also takes --output FORMAT and --query PATH|TEMPLATE)\n")
+	fmt.Printf("\tset name value\n")
+	fmt.Printf("\tunset name\n")
+	fmt.Printf("\tvars\n")
+	fmt.Printf("\tsource [--continue-on-error] [--transcript file] file\n")
+	fmt.Printf("\tassert name ==|!=|<|<=|>|>= value\n")
+}
+
+func (app *AppState) badCommand() {
+	cmdUsage()
+	app.lastErr = fmt.Errorf("unrecognized command")
 }
 
 func cmdArgs(parts []string) {	// accept any args, dump them out for debugging
@@ -173,6 +421,46 @@ func cmdArgs(parts []string) {	// accept any args, dump them out for debugging
 
 type AppState struct {
 	clc CenturyLinkClient
+
+	outputFormat string // session default set by "output", "" means text
+	lineOutput   string // --output on the current line
+	lineQuery    string // --query on the current line
+
+	vars    map[string]string // "set" variables plus results bound by commands, expanded as $name or ${name}
+	lastErr error             // set by failf, reset at the start of each line
+	depth   int               // nesting level of "source", so a script cannot source itself forever
+
+	envLogin bool // command line mode: log in from the environment when a command first needs it
+	exitCode int  // command line mode: exit code for a command that succeeded, e.g. 2 from drift
+
+	recorder *CassetteRecorder // set by "cassette record"
+	player   *CassettePlayer   // set by "cassette replay"
+	cassette string            // file of either
+
+	cache *ResponseCache // set by "cache on"
+	jobs  *JobTracker    // every login's, so "jobs" lists what the session started, see clcJobs.go
+
+	debugRequests  bool           // "debug", applied to the client now and at every login
+	debugResponses bool
+	logJSON        bool
+	logLevel       *slog.LevelVar // of the session logger, Debug while dumps are on
+}
+
+// failf reports a command failure the same way the commands always have, and also
+// remembers it so that scripts can stop (or not) on the failing line
+func (app *AppState) failf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Print(msg)
+	app.lastErr = fmt.Errorf("%s", strings.TrimSpace(msg))
+}
+
+// warnf is for a notice that does not fail the command, kept off stdout when that is json or yaml
+func (app *AppState) warnf(format string, args ...interface{}) {
+	if app.textOutput() {
+		fmt.Printf(format, args...)
+	} else {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
 }
 
 func cmdHelp(args []string) {	//  args[0]="help"
@@ -180,6 +468,28 @@ func cmdHelp(args []string) {	//  args[0]="help"
 	fmt.Printf("No command-specific help available\n")
 }
 
+// haveClient is the check at the top of every command that needs the API.  In command line mode
+// it logs in from the CLC_API_* environment the first time it is needed.
+func (app *AppState) haveClient() bool {
+	if (app.clc == nil) && app.envLogin {
+		app.envLogin = false	// one attempt only
+
+		new_clc, err := ClientReload(app.clientOptions()...)
+		if err != nil {
+			app.failf("could not log in from environment: err=%s\n", err.Error())
+			return false
+		}
+		app.clc = new_clc
+	}
+
+	if app.clc == nil {
+		app.failf("no user is logged in\n")
+		return false
+	}
+
+	return true
+}
+
 func (app *AppState) cmdAuthEnv() {		// wrapper that fetches user/pass from env
 
 	if app.clc != nil {
@@ -187,9 +497,9 @@ func (app *AppState) cmdAuthEnv() {		// wrapper that fetches user/pass from env
 		app.clc = nil
 	}
 
-	new_clc, err := ClientReload()
+	new_clc, err := ClientReload(app.clientOptions()...)
 	if err != nil {
-		fmt.Printf("could not log in: err=%s\n", err.Error())
+		app.failf("could not log in: err=%s\n", err.Error())
 		app.clc = nil
 	} else {
 		app.clc = new_clc
@@ -199,7 +509,7 @@ func (app *AppState) cmdAuthEnv() {		// wrapper that fetches user/pass from env
 
 func (app *AppState) cmdAuthLogin(argUsername string, argPassword string) {
 	if (argUsername == "") || (argPassword == "") {
-		cmdUsage()
+		app.badCommand()
 		return
 	}
 
@@ -208,9 +518,9 @@ func (app *AppState) cmdAuthLogin(argUsername string, argPassword string) {
 		app.clc = nil
 	}
 
-	new_clc, err := ClientLogin(argUsername, argPassword)
+	new_clc, err := ClientLogin(argUsername, argPassword, app.clientOptions()...)
 	if err != nil {
-		fmt.Printf("could not log in: err=%s\n", err.Error())
+		app.failf("could not log in: err=%s\n", err.Error())
 		app.clc = nil
 	} else {
 		app.clc = new_clc
@@ -218,6 +528,151 @@ func (app *AppState) cmdAuthLogin(argUsername string, argPassword string) {
 	}
 }
 
+func (app *AppState) cmdAuthFake() {
+	if app.clc != nil {
+		app.clc.logout()
+		app.clc = nil
+	}
+
+	app.clc = defaultFakeClient()
+	app.clc.configure(app.clientOptions()...)
+	fmt.Printf("logged in to in-memory fake: user=%s, accountAlias=%s\n", app.clc.getUsername(), app.clc.getAccountAlias())
+}
+
+func (app *AppState) cmdAuthToken() {
+	if !app.haveClient() {
+		return
+	}
+
+	env := app.clc.exportEnv()
+	if env == "" {
+		app.failf("this session has no token to export\n")
+		return
+	}
+
+	fmt.Printf("%s", env)
+}
+
+func (app *AppState) cmdStats() {
+	if !app.haveClient() {
+		return
+	}
+
+	stats := app.clc.getStats()
+
+	app.emit(stats, func() {
+		if len(stats) == 0 {
+			fmt.Printf("no HTTP calls made by this client\n")
+		}
+		for _, hs := range stats {
+			fmt.Printf("%s: calls=%d, throttled=%d, waited=%s in %d calls, rate=%.1f/s (limit %.1f/s)\n",
+				hs.Host, hs.Calls, hs.Throttled, hs.Waited.Round(time.Millisecond), hs.Waits, hs.Rate, hs.Limit)
+		}
+	})
+	app.bindResult("stats", stats, "")
+}
+
+func (app *AppState) cmdCache(argMode, argFile string) {
+	if argMode == "on" {
+		opts := defaultCacheOptions
+		opts.Path = argFile
+		app.cache = NewResponseCache(opts)
+		app.reconfigure()
+		fmt.Printf("caching datacenters for %s, load balancers for %s\n", opts.DatacenterTTL, opts.LoadBalancerTTL)
+
+	} else if argMode == "off" {
+		app.cache = nil
+		app.reconfigure()
+
+	} else if argMode == "clear" {
+		if app.cache != nil {
+			app.cache.Clear()
+		}
+
+	} else if argMode == "status" {
+		if app.cache == nil {
+			fmt.Printf("no cache\n")
+			return
+		}
+
+		stats := app.cache.Stats()
+		app.emit(stats, func() {
+			fmt.Printf("cache: entries=%d, hits=%d, revalidated=%d, misses=%d", stats.Entries, stats.Hits, stats.Revalidated, stats.Misses)
+			if stats.Path != "" {
+				fmt.Printf(", file=%s", stats.Path)
+			}
+			fmt.Printf("\n")
+		})
+
+	} else {
+		app.badCommand()
+	}
+}
+
+func (app *AppState) cmdDebug(argMode string) {
+	if argMode == "on" {
+		app.debugRequests, app.debugResponses = true, true
+	} else if argMode == "off" {
+		app.debugRequests, app.debugResponses = false, false
+	} else if argMode == "requests" {
+		app.debugRequests, app.debugResponses = true, false
+	} else if argMode == "responses" {
+		app.debugRequests, app.debugResponses = false, true
+	} else if argMode == "json" {
+		app.logJSON = true
+	} else if argMode == "text" {
+		app.logJSON = false
+	} else if argMode == "" {
+		fmt.Printf("debug: requests=%t, responses=%t, json=%t\n", app.debugRequests, app.debugResponses, app.logJSON)
+		return
+	} else {
+		app.badCommand()
+		return
+	}
+
+	app.reconfigure()
+}
+
+// the session settings every client gets, at login and whenever they change
+func (app *AppState) clientOptions() []ClientOption {
+	if app.logLevel == nil {
+		app.logLevel = new(slog.LevelVar)
+	}
+
+	if app.debugRequests || app.debugResponses {
+		app.logLevel.Set(slog.LevelDebug)
+	} else {
+		app.logLevel.Set(slog.LevelInfo)
+	}
+
+	logger := NewTextLogger(os.Stderr, app.logLevel)
+	if app.logJSON {
+		logger = NewJSONLogger(os.Stderr, app.logLevel)
+	}
+
+	var transport http.RoundTripper // nil is the network
+	if app.recorder != nil {
+		transport = app.recorder
+	} else if app.player != nil {
+		transport = app.player
+	}
+
+	return []ClientOption{
+		WithLogger(logger),
+		WithDebug(app.debugRequests, app.debugResponses),
+		WithTransport(transport),
+		WithCache(app.cache),
+		WithJobs(app.jobTracker()),
+	}
+}
+
+func (app *AppState) reconfigure() {
+	opts := app.clientOptions()
+	if app.clc != nil {
+		app.clc.configure(opts...)
+	}
+}
+
 func (app *AppState) cmdAuthLogout() {
 	if app.clc != nil {
 		user := app.clc.getUsername()
@@ -239,46 +694,57 @@ func (app *AppState) cmdAuthStatus() {
 
 
 func (app *AppState) cmdDatacenterList() {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
 	dclist, err := app.clc.listAllDC()
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
 		return
 	}
 
-	for _,dc := range dclist {
-		fmt.Printf("DC: id=%s, name=\"%s\"\n", dc.DCID, dc.Name)
-	}
+	app.emit(dclist, func() {
+		for _,dc := range dclist {
+			fmt.Printf("DC: id=%s, name=\"%s\"\n", dc.DCID, dc.Name)
+		}
+	})
+
+	app.bindResult("dcs", dclist, "")
 }
 
 func (app *AppState) cmdLoadbalancerCreate(argDC string, argName string, argDesc string) {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
 	lbinf,err := app.clc.createLB(argDC, argName, argDesc)
+	if herr, ok := err.(HttpError); ok && (herr.Code() == HTTP_ERROR_NOSERVICE) {
+		app.failf("%s\n", err.Error()) // refused before any LB call
+		return
+	}
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
 		return
 	}
+
+	lbinf.JobID = TrackLB(app.clc, argDC, lbinf.LBID, lbinf.Status, lbReadyTimeout)
 	
-	fmt.Printf("createLB status: lbid=%s \n", lbinf.LBID)
+	app.emit(lbinf, func() {
+		fmt.Printf("createLB status: lbid=%s \n", lbinf.LBID)
+		fmt.Printf("request %s, tracked as %s\n", lbinf.Status, lbinf.JobID)
+	})
+	app.bindResult("lb", lbinf, lbinf.LBID)
 }
 
 func (app *AppState) cmdLoadbalancerDelete(argDC string, argLBID string) {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
 	_,err := app.clc.deleteLB(argDC, argLBID)
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
 		return
 	}
 
@@ -286,58 +752,113 @@ func (app *AppState) cmdLoadbalancerDelete(argDC string, argLBID string) {
 }
 
 func (app *AppState) cmdLoadbalancerDetails(argDC string, argLBID string) {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
 	lb,err := app.clc.inspectLB(argDC, argLBID)
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
 		return
 	}
 	
-	fmt.Printf("LB details: dc=%s, lbid=%s, status=%s, IP=%s \n",
-		lb.DataCenter, lb.LBID, lb.Status, lb.PublicIP)
-	fmt.Printf("  name=%s, description=%s \n", lb.Name, lb.Description)
+	app.emit(lb, func() {
+		printLoadbalancerDetails(lb)
+	})
 
-	if len(lb.Pools) == 0 {
-		fmt.Printf("  (no pools defined)\n")
-	} else {
-		for _,pool := range lb.Pools {
-			printPoolDetails(&pool, "  ")
+	app.bindResult("lb", lb, lb.LBID)
+}
+
+// "LB list [--details [--workers N] [--rate N]]".  --details inspects every LB, N at once, at most
+// --rate calls per second
+func (app *AppState) cmdLoadbalancerList(parts []string) {	// parts[0:2]="LB list"
+	details := false
+	opts := InspectOptions{}
+
+	for idx := 2; idx < len(parts); idx++ {
+		s := parts[idx]
+		if s == "--details" {
+			details = true
+		} else if ((s == "--workers") || (s == "--rate")) && (idx+1 < len(parts)) {
+			idx++
+			conv, e := strconv.ParseFloat(parts[idx], 64)
+			if (e != nil) || (conv < 0) {
+				app.failf("invalid %s: %s\n", s, parts[idx])
+				return
+			}
+
+			if s == "--workers" {
+				opts.Workers = int(conv)
+			} else {
+				opts.PerSecond = conv
+			}
+		} else {
+			app.badCommand()
+			return
 		}
 	}
-}
 
-func (app *AppState) cmdLoadbalancerList() {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
 	lblist,err := app.clc.listAllLB()
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	if details {
+		app.listLoadbalancerDetails(lblist, opts)
 		return
 	}
 	
-	for _,lb := range lblist {	// we get LBSummary back
-		fmt.Printf("LB: dc=%s, lbid=%s, name=\"%s\", desc=\"%s\",\n    ip=%s \n",
-			lb.DataCenter, lb.LBID, lb.Name, lb.Description, lb.PublicIP)
+	app.emit(lblist, func() {
+		for _,lb := range lblist {	// we get LBSummary back
+			fmt.Printf("LB: dc=%s, lbid=%s, name=\"%s\", desc=\"%s\",\n    ip=%s \n",
+				lb.DataCenter, lb.LBID, lb.Name, lb.Description, lb.PublicIP)
+		}
+	})
+
+	app.bindResult("lbs", lblist, "")
+}
+
+func (app *AppState) listLoadbalancerDetails(lblist []LoadBalancerSummary, opts InspectOptions) {
+	results := InspectAll(app.clc, lblist, opts)
+
+	detailList := make([]LoadBalancerDetails, 0, len(results))
+	for _, r := range results {
+		if r.Details != nil {
+			detailList = append(detailList, *r.Details)
+		}
+	}
+
+	app.emit(detailList, func() {
+		for _, r := range results {
+			if r.Err != nil {
+				fmt.Printf("LB: dc=%s, lbid=%s, name=\"%s\": inspect failed, err=%s\n",
+					r.Summary.DataCenter, r.Summary.LBID, r.Summary.Name, r.Error)
+			} else {
+				printLoadbalancerDetails(r.Details)
+			}
+		}
+	})
+
+	app.bindResult("lbs", detailList, "")
+
+	if n := InspectErrors(results); n > 0 {
+		app.failf("%d of %d load balancers could not be inspected\n", n, len(results))
 	}
 }
 
 
 func (app *AppState) cmdPoolCreate(argDC string, argLBID string, args []string) {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
-	newpoolinfo, err := makePoolFromArgs(args, 4)
-	if err != nil {
-		fmt.Printf("invalid pool details: %s\n", err.Error())
+	newpoolinfo := app.poolFromArgs(argDC, args, 4)
+	if newpoolinfo == nil {
 		return
 	}
 
@@ -346,11 +867,17 @@ func (app *AppState) cmdPoolCreate(argDC string, argLBID string, args []string)
 	
 	pool,err := app.clc.createPool(argDC, argLBID, newpoolinfo)
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
 		return
 	}
+
+	pool.Request.JobID = TrackLBRequest(app.clc, pool.Request, lbReadyTimeout)
 	
-	printPoolDetails(pool, "")
+	app.emit(pool, func() {
+		printPoolDetails(pool, "")
+		fmt.Printf("%s\n", requestTracking(pool.Request))
+	})
+	app.bindResult("pool", pool, pool.PoolID)
 }
 
 
@@ -358,15 +885,37 @@ func (app *AppState) cmdPoolCreate(argDC string, argLBID string, args []string)
 // nyi consider: expand this app to do the whole rest of clc_sdk
 // nyi consider: command-object dispatching
 
+func printLoadbalancerDetails(lb *LoadBalancerDetails) {
+	fmt.Printf("LB details: dc=%s, lbid=%s, status=%s, IP=%s \n",
+		lb.DataCenter, lb.LBID, lb.Status, lb.PublicIP)
+	fmt.Printf("  name=%s, description=%s \n", lb.Name, lb.Description)
+
+	if len(lb.Pools) == 0 {
+		fmt.Printf("  (no pools defined)\n")
+	} else {
+		for _,pool := range lb.Pools {
+			printPoolDetails(&pool, "  ")
+		}
+	}
+}
+
 func printHealthCheck(src *HealthCheckDetails, inset string) {
 	if src == nil {
-		fmt.Println("no health check specified\n")
+		fmt.Printf("%s  no health check specified\n", inset)
 	} else {
 		fmt.Printf("%s  health: unhealthy:%d healthy:%d interval:%d targetPort:%d mode:%s\n", 
 			inset, src.Unhealthy, src.Healthy, src.Interval, src.TargetPort, src.Mode)
 	}
 }
 
+// "request ACCEPTED, tracked as job-3", for a pool change
+func requestTracking(req *LBRequest) string {
+	if req.JobID == "" {
+		return fmt.Sprintf("request %s, not tracked (no link to poll it)", req.Status)
+	}
+	return fmt.Sprintf("request %s, tracked as %s", req.Status, req.JobID)
+}
+
 func printPoolDetails(pool *PoolDetails, inset string) {
 	fmt.Printf("%spool: LBID:%s, PoolID:%s \n", inset, pool.LBID, pool.PoolID)
 	fmt.Printf("%s  port:%d, method:%s, persistence:%s, timeout:%d, mode:%s \n", inset, 
@@ -382,19 +931,51 @@ func printPoolDetails(pool *PoolDetails, inset string) {
 	fmt.Printf("]\n")
 }
 
-func makePoolFromArgs(args []string, ignore int) (*PoolDetails, error) {
-	pool := PoolDetails {	// install defaults
-		PoolID:"",
-		LBID:"",
-		IncomingPort:8080,
-		Method:"roundrobin",
-		Health:nil,
-		Persistence:"none",
-		TimeoutMS:1000,
-		Mode:"tcp",
+// makePoolFromArgs, then server: and group: nodes looked up, node IPs checked against the DC's
+// networks, and the result shown.  nil after a failure.
+func (app *AppState) poolFromArgs(argDC string, args []string, ignore int) *PoolDetails {
+	newpoolinfo, err := makePoolFromArgs(args, ignore)
+	if err != nil {
+		app.failf("invalid pool details: %s\n", err.Error())
+		return nil
+	}
+
+	resolutions, err := ResolvePoolNodes(app.clc, argDC, newpoolinfo)
+	if err != nil {
+		app.failf("could not resolve pool nodes: %s\n", err.Error())
+		return nil
+	}
+
+	if len(newpoolinfo.Nodes) > 0 { // only a pool with nodes needs the networks
+		networks, err := app.clc.listNetworks(argDC)
+		if err != nil {
+			app.warnf("could not look up the networks in %s, node IPs not checked: %s\n", NormalizeDC(argDC), err.Error())
+		}
+
+		for _, warning := range NodeNetworkWarnings(newpoolinfo.Nodes, networks) {
+			app.warnf("warning: %s\n", warning)
+		}
+	}
+
+	if app.textOutput() {
+		fmt.Printf("parsed pool details from command line:\n")
+		printPoolDetails(newpoolinfo, "    ")
+		for _, r := range resolutions {
+			fmt.Printf("      %s ->", r.Ref)
+			for idx := range r.Servers {
+				fmt.Printf(" %s=%s", r.Servers[idx], r.IPs[idx])
+			}
+			fmt.Printf("\n")
+		}
 	}
 
-	target_port := 8080
+	return newpoolinfo
+}
+
+func makePoolFromArgs(args []string, ignore int) (*PoolDetails, error) {
+	pool := defaultPoolDetails()	// install defaults
+
+	target_port := defaultTargetPort
 
 	for idx,s := range args {
 		if idx < ignore {
@@ -439,7 +1020,7 @@ func makePoolFromArgs(args []string, ignore int) (*PoolDetails, error) {
 
 		} else if strings.HasPrefix(s, "nodes=") {
 			s = strings.TrimPrefix(s, "nodes=")
-			parts := strings.Split(s, ",")	// comma-separated list with no spaces allowed
+			parts := strings.Split(s, ",")	// comma-separated list with no spaces allowed, IPs or server:ID or group:name
 
 			nodes := make([]PoolNode, len(parts), len(parts))
 			for idx,part := range parts {
@@ -468,22 +1049,17 @@ func makePoolFromArgs(args []string, ignore int) (*PoolDetails, error) {
 		}
 	}
 
-	fmt.Printf("parsed pool details from command line:\n")
-	printPoolDetails(&pool, "    ")
-
 	return &pool, nil
 }
 
 func (app *AppState) cmdPoolUpdate(argDC string, argLBID string, argPoolID string, args []string) {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
-	newpoolinfo, err := makePoolFromArgs(args, 5)
-	if err != nil {
-		fmt.Printf("invalid pool details: %s\n", err.Error())
-		return 
+	newpoolinfo := app.poolFromArgs(argDC, args, 5)
+	if newpoolinfo == nil {
+		return
 	}
 
 	newpoolinfo.PoolID = argPoolID
@@ -491,25 +1067,34 @@ func (app *AppState) cmdPoolUpdate(argDC string, argLBID string, argPoolID strin
 	
 	pool,err := app.clc.updatePool(argDC,argLBID, newpoolinfo)
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
 		return
 	}
+
+	pool.Request.JobID = TrackLBRequest(app.clc, pool.Request, lbReadyTimeout)
 	
-	printPoolDetails(pool, "")
+	app.emit(pool, func() {
+		printPoolDetails(pool, "")
+		fmt.Printf("%s\n", requestTracking(pool.Request))
+	})
+	app.bindResult("pool", pool, pool.PoolID)
 }
 
 func (app *AppState) cmdPoolDelete(argDC string, argLBID string, argPoolID string) {
-	if app.clc == nil {
-		fmt.Printf("no user is logged in\n")
+	if !app.haveClient() {
 		return
 	}
 
-	err := app.clc.deletePool(argDC, argLBID, argPoolID)
+	request,err := app.clc.deletePool(argDC, argLBID, argPoolID)
 	if err != nil {
-		fmt.Printf("remote call failed, err=%s\n", err.Error())
+		app.failf("remote call failed, err=%s\n", err.Error())
 		return
 	}
 
-	fmt.Printf("pool deleted\n")
+	request.JobID = TrackLBRequest(app.clc, request, lbReadyTimeout)
+
+	app.emit(request, func() {
+		fmt.Printf("pool deleted, %s\n", requestTracking(request))
+	})
 }
 
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// "apiTool mockserver [--listen addr] [--state file] [--account A] [--user u] [--password p] [--token-ttl 10m]"
+// serves the v2 and LBaaS APIs from memory until interrupted, for running scripts end to end
+func runMockServer(args []string) int {
+	listen := "127.0.0.1:8443"
+	statePath := ""
+	account := "MOCK"
+	username := "mockuser"
+	password := "mockpassword"
+	var tokenTTL time.Duration
+
+	for idx := 0; idx < len(args); idx++ {
+		s := args[idx]
+		if !strings.HasPrefix(s, "--") || (idx+1 >= len(args)) {
+			fmt.Printf("mockserver: bad argument %s\n", s)
+			cmdLineUsage()
+			return 1
+		}
+
+		idx++
+		value := args[idx]
+
+		if s == "--listen" {
+			listen = value
+		} else if s == "--state" {
+			statePath = value
+		} else if s == "--account" {
+			account = value
+		} else if s == "--user" {
+			username = value
+		} else if s == "--password" {
+			password = value
+		} else if s == "--token-ttl" {
+			var err error
+			tokenTTL, err = time.ParseDuration(value)
+			if err != nil {
+				fmt.Printf("mockserver: invalid --token-ttl %s\n", value)
+				return 1
+			}
+		} else {
+			fmt.Printf("mockserver: unknown option %s\n", s)
+			cmdLineUsage()
+			return 1
+		}
+	}
+
+	mock := NewMockServer(account, username, password, defaultFakeClient().dcs...)
+	mock.SetTokenTTL(tokenTTL)
+	mock.Backend().SetLBService("UC1", false) // as "auth fake" has it
+	mock.Backend().SeedDemoServers()          // replaced by the state file's, if it has any
+
+	if statePath != "" {
+		err := mock.SetStateFile(statePath)
+		if err != nil {
+			fmt.Printf("mockserver: could not load %s: %s\n", statePath, err.Error())
+			return 1
+		}
+	}
+
+	srv, err := mock.StartTLS(listen)
+	if err != nil {
+		fmt.Printf("mockserver: %s\n", err.Error())
+		return 1
+	}
+	defer srv.Close()
+
+	addr := srv.Listener.Addr().String()
+	fmt.Printf("mock server listening on https://%s, use it with:\n", addr)
+	fmt.Printf("\texport CLC_API_V2_SERVER=%s\n", addr)
+	fmt.Printf("\texport CLC_API_LB_SERVER=%s\n", addr)
+	fmt.Printf("\texport CLC_API_USERNAME=%s\n", username)
+	fmt.Printf("\texport CLC_API_PASSWORD=%s\n", password)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+
+	fmt.Printf("mock server stopped after %d logins\n", mock.Logins())
+	return 0
+}
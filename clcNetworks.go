@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (app *AppState) cmdNetworkList(argDC string) {
+	if argDC == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	networks, err := app.clc.listNetworks(argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(networks, func() {
+		if len(networks) == 0 {
+			fmt.Printf("no networks in %s\n", NormalizeDC(argDC))
+		}
+		for idx := range networks {
+			printNetwork(&networks[idx])
+		}
+	})
+	app.bindResult("networks", networks, "")
+}
+
+func (app *AppState) cmdNetworkDetails(parts []string) { // parts[0:2]="network details"
+	if (len(parts) < 4) || (len(parts) > 5) {
+		app.badCommand()
+		return
+	}
+
+	ipAddresses := NETWORK_IPS_CLAIMED
+	if len(parts) == 5 {
+		if !strings.HasPrefix(parts[4], "ips=") {
+			app.badCommand()
+			return
+		}
+		ipAddresses = strings.TrimPrefix(parts[4], "ips=")
+	}
+
+	if (ipAddresses != NETWORK_IPS_NONE) && (ipAddresses != NETWORK_IPS_CLAIMED) &&
+		(ipAddresses != NETWORK_IPS_FREE) && (ipAddresses != NETWORK_IPS_ALL) {
+		app.failf("ips= takes none, claimed, free or all\n")
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	network, err := app.clc.inspectNetwork(parts[2], parts[3], ipAddresses)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(network, func() {
+		printNetwork(network)
+		if (ipAddresses != NETWORK_IPS_NONE) && (len(network.IPAddresses) == 0) {
+			fmt.Printf("  (no %s addresses)\n", ipAddresses)
+		}
+		for _, ip := range network.IPAddresses {
+			state := "free"
+			if ip.Claimed {
+				state = "claimed"
+			}
+			fmt.Printf("  %-15s %-7s %-12s %s\n", ip.Address, state, ip.Type, ip.ServerID)
+		}
+	})
+	app.bindResult("network", network, network.NetworkID)
+}
+
+func printNetwork(network *NetworkDetails) {
+	fmt.Printf("network %s: dc=%s, name=%s, cidr=%s, gateway=%s, netmask=%s\n",
+		network.NetworkID, network.DataCenter, network.Name, network.CIDR, network.Gateway, network.Netmask)
+	fmt.Printf("    type=%s, vlan=%d, description=%q\n", network.Type, network.VLAN, network.Description)
+}
+
+func (app *AppState) cmdNetworkClaim(argDC string, argFlag string) {
+	if (argDC == "") || ((argFlag != "") && (argFlag != "--no-wait")) {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	if argFlag == "--no-wait" {
+		operationID, err := app.clc.claimNetwork(argDC)
+		app.emitOperation(operationID, "claim a network in "+NormalizeDC(argDC), err)
+		return
+	}
+
+	if app.textOutput() {
+		fmt.Printf("claiming a network in %s, waiting for it\n", NormalizeDC(argDC))
+	}
+
+	network, err := ClaimNetworkAndWait(app.clc, argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(network, func() {
+		printNetwork(network)
+	})
+	app.bindResult("network", network, network.NetworkID)
+}
+
+func (app *AppState) cmdNetworkRelease(argDC string, argNetworkID string) {
+	if (argDC == "") || (argNetworkID == "") {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	err := app.clc.releaseNetwork(argDC, argNetworkID)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("network released\n")
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+)
+
+// output formats.  "text" is the original human-readable Printf output, the others are stable
+// and meant for parsing.  Field names are the json tags on the sdk.go model structs.
+var outputFormats = []string{"text", "json", "yaml", "csv", "table", "wide"}
+
+func isOutputFormat(s string) bool {
+	for _, f := range outputFormats {
+		if s == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pulls --output/-o and --query out of the command line, in either "--opt value" or "--opt=value" form.
+// Returns what remains.
+func (app *AppState) takeOutputOptions(parts []string) ([]string, error) {
+	rest := make([]string, 0, len(parts))
+
+	for idx := 0; idx < len(parts); idx++ {
+		s := parts[idx]
+		name := s
+		value := ""
+		hasValue := false
+
+		if eq := strings.Index(s, "="); (eq > 0) && strings.HasPrefix(s, "--") {
+			name = s[:eq]
+			value = s[eq+1:]
+			hasValue = true
+		}
+
+		if (name != "--output") && (name != "-o") && (name != "--query") {
+			rest = append(rest, s)
+			continue
+		}
+
+		if !hasValue {
+			if idx+1 >= len(parts) {
+				return nil, fmt.Errorf("%s needs a value", name)
+			}
+			idx++
+			value = parts[idx]
+		}
+
+		if name == "--query" {
+			app.lineQuery = value
+		} else if isOutputFormat(value) {
+			app.lineOutput = value
+		} else {
+			return nil, fmt.Errorf("unknown output format %q, use one of %s", value, strings.Join(outputFormats, "|"))
+		}
+	}
+
+	return rest, nil
+}
+
+func (app *AppState) effectiveOutput() string {
+	if app.lineOutput != "" {
+		return app.lineOutput
+	} else if app.outputFormat != "" {
+		return app.outputFormat
+	}
+
+	return "text"
+}
+
+// the original Printf output, including progress chatter that would spoil parseable output
+func (app *AppState) textOutput() bool {
+	return (app.effectiveOutput() == "text") && (app.lineQuery == "")
+}
+
+func (app *AppState) cmdOutput(argFormat string) { // "output json", or just "output" to show it
+	if argFormat == "" {
+		fmt.Printf("output format: %s\n", app.effectiveOutput())
+		return
+	}
+
+	if !isOutputFormat(argFormat) {
+		app.failf("unknown output format %q, use one of %s\n", argFormat, strings.Join(outputFormats, "|"))
+		return
+	}
+
+	app.outputFormat = argFormat
+}
+
+// emit is how commands present a result: printText for the text format, otherwise the renderer for
+// the selected format.  --query takes precedence over the format.
+func (app *AppState) emit(obj interface{}, printText func()) {
+	var err error
+
+	if app.lineQuery != "" {
+		err = renderQuery(obj, app.lineQuery)
+	} else {
+		switch app.effectiveOutput() {
+		case "json":
+			err = renderJSON(obj)
+		case "yaml":
+			err = renderYAML(obj)
+		case "csv":
+			err = renderCSV(obj)
+		case "table":
+			err = renderTable(obj, false)
+		case "wide":
+			err = renderTable(obj, true)
+		default:
+			printText()
+		}
+	}
+
+	if err != nil {
+		app.failf("could not render output: %s\n", err.Error())
+	}
+}
+
+func renderJSON(obj interface{}) error {
+	b, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", b)
+	return nil
+}
+
+func renderYAML(obj interface{}) error {
+	b, err := yamlMarshal(obj)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", b)
+	return nil
+}
+
+func renderCSV(obj interface{}) error {
+	headers, rows := tableFor(obj, true) // csv always gets every column
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write(headers)
+	w.WriteAll(rows) // flushes
+	return w.Error()
+}
+
+func renderTable(obj interface{}, wide bool) error {
+	headers, rows := tableFor(obj, wide)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\n", strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\n", strings.Join(row, "\t"))
+	}
+
+	return w.Flush()
+}
+
+//// table layouts, one per model type.  wide adds the columns that don't fit on a terminal line.
+
+func tableFor(obj interface{}, wide bool) ([]string, [][]string) {
+	switch t := obj.(type) {
+	case []DataCenterName:
+		rows := make([][]string, len(t))
+		for idx, dc := range t {
+			rows[idx] = []string{dc.DCID, dc.Name}
+		}
+		return []string{"DCID", "NAME"}, rows
+
+	case *DataCenterDetails: // what it offers, counted.  The lists are in the text, json and yaml.
+		headers := []string{"DCID", "NAME", "LOAD BALANCING", "BARE METAL", "STORAGE", "TEMPLATES", "NETWORKS"}
+		row := []string{t.DCID, t.Name, strconv.FormatBool(t.LoadBalancing),
+			strconv.FormatBool(t.Capabilities.SupportsBareMetal), strings.Join(t.StorageTypes, " "),
+			strconv.Itoa(len(t.Capabilities.Templates)), strconv.Itoa(len(t.Capabilities.Networks))}
+		if wide {
+			headers = append(headers, "IMPORT OS TYPES", "BARE METAL SKUS")
+			skus := 0
+			if t.BareMetal != nil {
+				skus = len(t.BareMetal.SKUs)
+			}
+			row = append(row, strconv.Itoa(len(t.Capabilities.ImportableOSTypes)), strconv.Itoa(skus))
+		}
+		return headers, [][]string{row}
+
+	case []LoadBalancerSummary:
+		headers := []string{"DC", "LBID", "NAME", "PUBLIC IP"}
+		if wide {
+			headers = append(headers, "DESCRIPTION")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, lb := range t {
+			rows[idx] = []string{lb.DataCenter, lb.LBID, lb.Name, lb.PublicIP}
+			if wide {
+				rows[idx] = append(rows[idx], lb.Description)
+			}
+		}
+		return headers, rows
+
+	case *LoadBalancerDetails:
+		return tableFor([]LoadBalancerDetails{*t}, wide)
+
+	case []LoadBalancerDetails:
+		headers := []string{"DC", "LBID", "NAME", "STATUS", "PUBLIC IP", "POOLS"}
+		if wide {
+			headers = append(headers, "DESCRIPTION")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, lb := range t {
+			pools := strconv.Itoa(len(lb.Pools))
+			if wide {
+				ids := make([]string, len(lb.Pools))
+				for idxPool, pool := range lb.Pools {
+					ids[idxPool] = fmt.Sprintf("%s:%d", pool.PoolID, pool.IncomingPort)
+				}
+				pools = strings.Join(ids, " ")
+			}
+
+			rows[idx] = []string{lb.DataCenter, lb.LBID, lb.Name, lb.Status, lb.PublicIP, pools}
+			if wide {
+				rows[idx] = append(rows[idx], lb.Description)
+			}
+		}
+		return headers, rows
+
+	case *PoolDetails:
+		return tableFor([]PoolDetails{*t}, wide)
+
+	case []PoolDetails:
+		headers := []string{"LBID", "POOLID", "PORT", "METHOD", "MODE", "NODES"}
+		if wide {
+			headers = append(headers, "PERSISTENCE", "TIMEOUT", "HEALTH")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, pool := range t {
+			nodes := strconv.Itoa(len(pool.Nodes))
+			if wide {
+				nodes = nodeListString(pool.Nodes)
+			}
+
+			rows[idx] = []string{pool.LBID, pool.PoolID, strconv.Itoa(pool.IncomingPort), pool.Method, pool.Mode, nodes}
+			if wide {
+				rows[idx] = append(rows[idx], pool.Persistence, strconv.FormatInt(pool.TimeoutMS, 10), healthCheckText(pool.Health))
+			}
+		}
+		return headers, rows
+
+	case *ServerDetails:
+		return tableFor([]ServerDetails{*t}, wide)
+
+	case []ServerDetails:
+		headers := []string{"DC", "ID", "POWER", "PRIVATE IP", "PUBLIC IP"}
+		if wide {
+			headers = append(headers, "STATUS", "CPU", "MEMORY MB", "OS", "GROUP", "DESCRIPTION")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, server := range t {
+			rows[idx] = []string{server.DataCenter, server.ServerID, server.PowerState,
+				strings.Join(server.PrivateIPs, " "), strings.Join(server.PublicIPs, " ")}
+			if wide {
+				rows[idx] = append(rows[idx], server.Status, strconv.Itoa(server.CPU), strconv.Itoa(server.MemoryMB),
+					server.OSType, server.GroupID, server.Description)
+			}
+		}
+		return headers, rows
+
+	case []ServerOperationResult:
+		headers := []string{"SERVER", "QUEUED", "OPERATION", "STATUS", "ERROR"}
+		if wide {
+			headers = append(headers, "DRAINED")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, result := range t {
+			rows[idx] = []string{result.ServerID, strconv.FormatBool(result.Queued), result.OperationID, result.Status, result.Error}
+			if wide {
+				drained := make([]string, len(result.Drained))
+				for didx, d := range result.Drained {
+					drained[didx] = d.PoolID + "/" + nodeKey(d.Node)
+				}
+				rows[idx] = append(rows[idx], strings.Join(drained, " "))
+			}
+		}
+		return headers, rows
+
+	case []Job:
+		headers := []string{"JOB", "STATE", "KIND", "REF", "STATUS", "ELAPSED", "DESCRIPTION"}
+		if wide {
+			headers = append(headers, "POLLS", "RESULT", "ERROR")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, job := range t {
+			rows[idx] = []string{job.JobID, job.State, job.Kind, job.Ref, job.Status, job.Elapsed().Round(time.Second).String(), job.Description}
+			if wide {
+				rows[idx] = append(rows[idx], strconv.Itoa(job.Polls), job.Result, job.Error)
+			}
+		}
+		return headers, rows
+
+	case []ServerTemplate:
+		headers := []string{"NAME", "OS TYPE", "STORAGE GB", "DESCRIPTION"}
+		if wide {
+			headers = append(headers, "CAPABILITIES")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, template := range t {
+			rows[idx] = []string{template.Name, template.OSType, strconv.Itoa(template.StorageSizeGB), template.Description}
+			if wide {
+				rows[idx] = append(rows[idx], strings.Join(template.Capabilities, " "))
+			}
+		}
+		return headers, rows
+
+	case *PublicIPDetails:
+		return tableFor([]PublicIPDetails{*t}, wide)
+
+	case []PublicIPDetails:
+		headers := []string{"PUBLIC IP", "INTERNAL IP", "PORTS", "SOURCES"}
+		if wide {
+			headers = append(headers, "SERVER")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, ip := range t {
+			ports := make([]string, len(ip.Ports))
+			for pidx, port := range ip.Ports {
+				ports[pidx] = port.String()
+			}
+			rows[idx] = []string{ip.PublicIP, ip.InternalIP, strings.Join(ports, " "), strings.Join(ip.SourceRestrictions, " ")}
+			if wide {
+				rows[idx] = append(rows[idx], ip.ServerID)
+			}
+		}
+		return headers, rows
+
+	case *NetworkDetails: // its addresses, if it came with any
+		if len(t.IPAddresses) == 0 {
+			return tableFor([]NetworkDetails{*t}, wide)
+		}
+
+		headers := []string{"ADDRESS", "CLAIMED", "TYPE", "SERVER"}
+		rows := make([][]string, len(t.IPAddresses))
+		for idx, ip := range t.IPAddresses {
+			rows[idx] = []string{ip.Address, strconv.FormatBool(ip.Claimed), ip.Type, ip.ServerID}
+		}
+		return headers, rows
+
+	case []NetworkDetails:
+		headers := []string{"DC", "NETWORKID", "NAME", "CIDR", "GATEWAY"}
+		if wide {
+			headers = append(headers, "NETMASK", "VLAN", "TYPE", "DESCRIPTION")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, network := range t {
+			rows[idx] = []string{network.DataCenter, network.NetworkID, network.Name, network.CIDR, network.Gateway}
+			if wide {
+				rows[idx] = append(rows[idx], network.Netmask, strconv.Itoa(network.VLAN), network.Type, network.Description)
+			}
+		}
+		return headers, rows
+
+	case *FirewallPolicy:
+		return tableFor([]FirewallPolicy{*t}, wide)
+
+	case []FirewallPolicy:
+		headers := []string{"DC", "POLICYID", "ENABLED", "SOURCES", "DESTINATIONS", "PORTS"}
+		if wide {
+			headers = append(headers, "STATUS", "DEST ACCOUNT", "DEST DC")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, policy := range t {
+			rows[idx] = []string{policy.DataCenter, policy.PolicyID, strconv.FormatBool(policy.Enabled),
+				strings.Join(policy.Sources, " "), strings.Join(policy.Destinations, " "), strings.Join(policy.Ports, " ")}
+			if wide {
+				rows[idx] = append(rows[idx], policy.Status, policy.DestinationAccount, policy.DestinationDC)
+			}
+		}
+		return headers, rows
+
+	case []GroupSummary:
+		headers := []string{"DC", "GROUPID", "PATH", "SERVERS"}
+		if wide {
+			headers = append(headers, "ALL SERVERS", "TYPE", "PARENT")
+		}
+
+		rows := make([][]string, len(t))
+		for idx, g := range t {
+			rows[idx] = []string{g.DataCenter, g.GroupID, g.Path, strconv.Itoa(g.Servers)}
+			if wide {
+				rows[idx] = append(rows[idx], strconv.Itoa(g.AllServers), g.Type, g.ParentID)
+			}
+		}
+		return headers, rows
+
+	case []HostStats:
+		rows := make([][]string, len(t))
+		for idx, hs := range t {
+			rows[idx] = []string{hs.Host, strconv.Itoa(hs.Calls), strconv.Itoa(hs.Throttled), strconv.Itoa(hs.Waits),
+				hs.Waited.Round(time.Millisecond).String(), fmt.Sprintf("%.1f", hs.Rate), fmt.Sprintf("%.1f", hs.Limit)}
+		}
+		return []string{"HOST", "CALLS", "THROTTLED", "WAITS", "WAITED", "RATE", "LIMIT"}, rows
+	}
+
+	return genericTable(obj)
+}
+
+// anything without a layout of its own is shown as its flattened fields, one per row
+func genericTable(obj interface{}) ([]string, [][]string) {
+	vars := make(map[string]string)
+
+	b, err := json.Marshal(obj)
+	if err == nil {
+		var generic interface{}
+		if json.Unmarshal(b, &generic) == nil {
+			flattenVars(vars, "", generic)
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([][]string, len(names))
+	for idx, name := range names {
+		rows[idx] = []string{strings.TrimPrefix(name, "."), vars[name]}
+	}
+
+	return []string{"FIELD", "VALUE"}, rows
+}
+
+func healthCheckText(src *HealthCheckDetails) string {
+	if src == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%d/%ds:%d/%s", src.Unhealthy, src.Healthy, src.Interval, src.TargetPort, src.Mode)
+}
+
+//// --query
+
+// a query is a Go template if it contains {{, otherwise a JSONPath-style field path such as
+// {.pools[0].poolID}, .pools[*].nodes[*].targetIP or status.  Both see the json field names.
+func renderQuery(obj interface{}, query string) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var generic interface{}
+	err = json.Unmarshal(b, &generic)
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(query, "{{") {
+		tmpl, err := template.New("query").Option("missingkey=error").Parse(query)
+		if err != nil {
+			return err
+		}
+
+		err = tmpl.Execute(os.Stdout, generic)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n")
+		return nil
+	}
+
+	results, err := evalPath(generic, query)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		switch t := r.(type) {
+		case map[string]interface{}, []interface{}:
+			b, _ := json.Marshal(t)
+			fmt.Printf("%s\n", b)
+		case nil:
+			fmt.Printf("\n")
+		case float64:
+			fmt.Printf("%s\n", strconv.FormatFloat(t, 'f', -1, 64))
+		default:
+			fmt.Printf("%v\n", t)
+		}
+	}
+
+	return nil
+}
+
+// walks a path of .field, [N] and [*] steps, fanning out on [*]
+func evalPath(root interface{}, path string) ([]interface{}, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "{")
+	path = strings.TrimSuffix(path, "}")
+	path = strings.TrimPrefix(path, "$")
+
+	current := []interface{}{root}
+
+	for path != "" {
+		if path[0] == '.' {
+			path = path[1:]
+			continue
+		}
+
+		if path[0] == '[' {
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in query")
+			}
+			index := path[1:end]
+			path = path[end+1:]
+
+			next := make([]interface{}, 0, len(current))
+			for _, c := range current {
+				list, ok := c.([]interface{})
+				if !ok {
+					return nil, fmt.Errorf("[%s] applied to something that is not a list", index)
+				}
+
+				if index == "*" {
+					next = append(next, list...)
+					continue
+				}
+
+				n, err := strconv.Atoi(index)
+				if err != nil {
+					return nil, fmt.Errorf("bad list index [%s]", index)
+				}
+				if n < 0 {
+					n += len(list)
+				}
+				if (n < 0) || (n >= len(list)) {
+					return nil, fmt.Errorf("list index [%s] out of range", index)
+				}
+				next = append(next, list[n])
+			}
+			current = next
+			continue
+		}
+
+		end := strings.IndexAny(path, ".[")
+		if end < 0 {
+			end = len(path)
+		}
+		field := path[:end]
+		path = path[end:]
+
+		next := make([]interface{}, 0, len(current))
+		for _, c := range current {
+			m, ok := c.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("field %s applied to something that is not an object", field)
+			}
+
+			v, found := m[field]
+			if !found {
+				return nil, fmt.Errorf("no field %s", field)
+			}
+			next = append(next, v)
+		}
+		current = next
+	}
+
+	return current, nil
+}
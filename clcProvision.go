@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// "DC details DC":  what the DC offers, from templates to bare metal
+func (app *AppState) cmdDatacenterDetails(argDC string) {
+	if argDC == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	details, err := InspectDataCenter(app.clc, argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(details, func() {
+		printDatacenterDetails(details)
+	})
+	app.bindResult("dc", details, details.DCID)
+}
+
+func printDatacenterDetails(details *DataCenterDetails) {
+	caps := &details.Capabilities
+	offered := map[bool]string{true: "offered", false: "not offered"}
+
+	fmt.Printf("DC: id=%s, name=\"%s\"\n", details.DCID, details.Name)
+	fmt.Printf("    load balancing: %s\n", offered[details.LoadBalancing])
+	fmt.Printf("    legacy shared load balancer: %s\n", offered[caps.SupportsSharedLoadBalancer])
+	fmt.Printf("    storage types: %s\n", strings.Join(details.StorageTypes, ", "))
+
+	names := make([]string, len(caps.Templates))
+	for idx, t := range caps.Templates {
+		names[idx] = t.Name
+	}
+	fmt.Printf("    templates: %s\n", strings.Join(names, ", "))
+
+	for _, n := range caps.Networks {
+		fmt.Printf("    network: id=%s, name=\"%s\", type=%s\n", n.NetworkID, n.Name, n.Type)
+	}
+
+	fmt.Printf("    bare metal: %s\n", offered[caps.SupportsBareMetal])
+	if details.BareMetal != nil {
+		for _, sku := range details.BareMetal.SKUs {
+			drives := make([]string, len(sku.Storage))
+			for idx, d := range sku.Storage {
+				drives[idx] = fmt.Sprintf("%dGB %s", d.CapacityGB, d.Type)
+			}
+			fmt.Printf("    bare metal SKU: id=%s, cpu=%dx%d \"%s\", memoryGB=%d, storage=%s, hourly=%.2f, availability=%s\n",
+				sku.ID, sku.Sockets, sku.CoresPerSocket, sku.Processor, sku.MemoryGB, strings.Join(drives, "+"), sku.HourlyRate,
+				sku.Availability)
+		}
+		for _, os := range details.BareMetal.OperatingSystems {
+			fmt.Printf("    bare metal OS: type=%s, hourlyPerSocket=%.2f, desc=\"%s\"\n", os.Type, os.HourlyRatePerSocket, os.Description)
+		}
+	}
+
+	for _, os := range caps.ImportableOSTypes {
+		fmt.Printf("    import OS: id=%d, type=%s, desc=\"%s\"\n", os.ID, os.Type, os.Description)
+	}
+}
+
+// "template list DC":  what new servers in the DC can be built from
+func (app *AppState) cmdTemplateList(argDC string) {
+	if argDC == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	caps, err := app.clc.deploymentCapabilities(argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	templates := caps.Templates
+	app.emit(templates, func() {
+		if len(templates) == 0 {
+			fmt.Printf("no templates in %s\n", caps.DataCenter)
+		}
+		for _, t := range templates {
+			fmt.Printf("template: name=%s, os=%s, storageGB=%d, desc=\"%s\"\n", t.Name, t.OSType, t.StorageSizeGB, t.Description)
+		}
+	})
+	app.bindResult("templates", templates, "")
+}
+
+// serverSpecFromArgs takes template=, group=, cpu=, memory= (GB), network=, ip=, password=, description=,
+// type=, storage= and field.ID=value.  group is left as given, a name or an ID.  Returns false (having
+// said why) on a bad argument, and whether --no-wait was given.
+func (app *AppState) serverSpecFromArgs(spec *ServerSpec, args []string) (bool, bool) {
+	noWait := false
+
+	for _, s := range args {
+		if s == "--no-wait" {
+			noWait = true
+			continue
+		}
+
+		key, value, found := strings.Cut(s, "=")
+		if !found {
+			app.badCommand()
+			return false, false
+		}
+
+		var err error
+		switch key {
+		case "template":
+			spec.Template = value
+		case "group":
+			spec.GroupID = value
+		case "cpu":
+			spec.CPU, err = strconv.Atoi(value)
+		case "memory":
+			spec.MemoryGB, err = strconv.Atoi(strings.TrimSuffix(strings.ToUpper(value), "GB"))
+		case "network":
+			spec.NetworkID = value
+		case "ip":
+			spec.IPAddress = value
+		case "password":
+			spec.Password = value
+		case "description":
+			spec.Description = value
+		case "type":
+			spec.Type = value
+		case "storage":
+			spec.StorageType = value
+		default:
+			if !strings.HasPrefix(key, "field.") {
+				app.badCommand()
+				return false, false
+			}
+			if spec.CustomFields == nil {
+				spec.CustomFields = make(map[string]string)
+			}
+			spec.CustomFields[strings.TrimPrefix(key, "field.")] = value
+		}
+
+		if err != nil {
+			app.failf("%s= takes a number\n", key)
+			return false, false
+		}
+	}
+
+	return true, noWait
+}
+
+// "server create DC name template=... group=... cpu=N memory=GB [network=...] [ip=...] [password=...]
+// [description=...] [type=...] [storage=...] [field.ID=value...] [--no-wait]"
+func (app *AppState) cmdServerCreate(parts []string) { // parts[0:2]="server create"
+	if len(parts) < 4 {
+		app.badCommand()
+		return
+	}
+
+	argDC := parts[2]
+	spec := &ServerSpec{Name: parts[3]}
+	ok, noWait := app.serverSpecFromArgs(spec, parts[4:])
+	if !ok {
+		return
+	}
+
+	if spec.GroupID == "" {
+		app.failf("a new server needs a group, group=name or ID\n")
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	root, err := inspectDCGroups(app.clc, argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	group, err := root.FindOne(spec.GroupID)
+	if err != nil {
+		app.failf("%s\n", err.Error())
+		return
+	}
+	spec.GroupID = group.GroupID
+
+	caps, err := app.clc.deploymentCapabilities(argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	if err = ValidateServerSpec(spec, app.clc.getAccountAlias(), caps); err != nil {
+		app.failf("%s\n", err.Error())
+		return
+	}
+
+	if noWait {
+		info, err := app.clc.createServer(spec)
+		if err != nil {
+			app.failf("remote call failed, err=%s\n", err.Error())
+			return
+		}
+
+		info.JobID = TrackServerCreate(app.clc, info, serverCreateTimeout)
+		app.emit(info, func() {
+			fmt.Printf("server %s queued as operation %s, tracked as %s\n", info.Name, info.OperationID, info.JobID)
+		})
+		app.bindResult("creation", info, info.OperationID)
+		return
+	}
+
+	if app.textOutput() {
+		fmt.Printf("creating server %s in %s from %s, waiting for it\n", spec.Name, caps.DataCenter, spec.Template)
+	}
+
+	server, err := CreateServerAndWait(app.clc, spec)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(server, func() {
+		fmt.Printf("server %s created, ip=%s\n", server.ServerID, strings.Join(server.PrivateIPs, ","))
+		printServerSummary(server)
+	})
+	app.bindResult("server", server, server.ServerID)
+}
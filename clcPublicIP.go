@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (app *AppState) cmdPublicIPList(argServerID string) {
+	if argServerID == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	ips, err := ListPublicIPs(app.clc, argServerID)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(ips, func() {
+		if len(ips) == 0 {
+			fmt.Printf("server %s has no public IPs\n", strings.ToUpper(argServerID))
+		}
+		for idx := range ips {
+			printPublicIP(&ips[idx])
+		}
+	})
+	app.bindResult("publicips", ips, "")
+}
+
+func (app *AppState) cmdPublicIPDetails(argServerID string, argIP string) {
+	if (argServerID == "") || (argIP == "") {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	ip, err := app.clc.inspectPublicIP(argServerID, argIP)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(ip, func() {
+		printPublicIP(ip)
+	})
+	app.bindResult("publicip", ip, ip.PublicIP)
+}
+
+func printPublicIP(ip *PublicIPDetails) {
+	ports := make([]string, len(ip.Ports))
+	for idx, port := range ip.Ports {
+		ports[idx] = port.String()
+	}
+
+	sources := "any"
+	if len(ip.SourceRestrictions) > 0 {
+		sources = strings.Join(ip.SourceRestrictions, ",")
+	}
+
+	fmt.Printf("%s -> %s, server:%s, ports:[ %s ], sources:%s\n",
+		ip.PublicIP, ip.InternalIP, ip.ServerID, strings.Join(ports, " "), sources)
+}
+
+// publicIPFromArgs takes ports=, sources= and internal= over what base has.  "sources=any" clears
+// the restrictions.  Returns nil (having said why) on a bad argument.
+func (app *AppState) publicIPFromArgs(base *PublicIPDetails, args []string) (*PublicIPDetails, bool) {
+	ip := *base
+	noWait := false
+
+	for _, s := range args {
+		if s == "--no-wait" {
+			noWait = true
+
+		} else if strings.HasPrefix(s, "ports=") {
+			ports, err := ParsePortSpecs(strings.TrimPrefix(s, "ports="))
+			if err != nil {
+				app.failf("%s\n", err.Error())
+				return nil, false
+			}
+			ip.Ports = ports
+
+		} else if strings.HasPrefix(s, "sources=") {
+			ip.SourceRestrictions = make([]string, 0)
+			for _, cidr := range strings.Split(strings.TrimPrefix(s, "sources="), ",") {
+				if (cidr != "") && (cidr != "any") {
+					ip.SourceRestrictions = append(ip.SourceRestrictions, cidr)
+				}
+			}
+
+		} else if strings.HasPrefix(s, "internal=") {
+			ip.InternalIP = strings.TrimPrefix(s, "internal=")
+
+		} else {
+			app.badCommand()
+			return nil, false
+		}
+	}
+
+	if len(ip.Ports) == 0 {
+		app.failf("a public IP needs at least one port, e.g. ports=tcp/80,tcp/443\n")
+		return nil, false
+	}
+
+	return &ip, noWait
+}
+
+func (app *AppState) cmdPublicIPAdd(parts []string) { // parts[0:2]="publicip add"
+	if len(parts) < 3 {
+		app.badCommand()
+		return
+	}
+
+	argServerID := parts[2]
+	ip, noWait := app.publicIPFromArgs(&PublicIPDetails{}, parts[3:])
+	if ip == nil {
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	if noWait {
+		operationID, err := app.clc.addPublicIP(argServerID, ip)
+		app.emitOperation(operationID, "add a public IP to "+strings.ToUpper(argServerID), err)
+		return
+	}
+
+	if app.textOutput() {
+		fmt.Printf("adding public IP to %s, waiting for it\n", strings.ToUpper(argServerID))
+	}
+
+	added, err := AddPublicIPAndWait(app.clc, argServerID, ip)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(added, func() {
+		printPublicIP(added)
+	})
+	app.bindResult("publicip", added, added.PublicIP)
+}
+
+func (app *AppState) cmdPublicIPUpdate(parts []string) { // parts[0:2]="publicip update"
+	if len(parts) < 4 {
+		app.badCommand()
+		return
+	}
+
+	argServerID, argIP := parts[2], parts[3]
+
+	if !app.haveClient() {
+		return
+	}
+
+	current, err := app.clc.inspectPublicIP(argServerID, argIP)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	ip, noWait := app.publicIPFromArgs(current, parts[4:])
+	if ip == nil {
+		return
+	}
+
+	description := fmt.Sprintf("update public IP %s on %s", argIP, strings.ToUpper(argServerID))
+	operationID, err := app.clc.updatePublicIP(argServerID, ip)
+	if noWait || (err != nil) {
+		app.emitOperation(operationID, description, err)
+		return
+	}
+
+	if err = WaitForOperation(app.clc, operationID, description, operationTimeout); err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	updated, err := app.clc.inspectPublicIP(argServerID, argIP)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(updated, func() {
+		printPublicIP(updated)
+	})
+	app.bindResult("publicip", updated, updated.PublicIP)
+}
+
+func (app *AppState) cmdPublicIPDelete(parts []string) { // parts[0:2]="publicip delete"
+	if (len(parts) < 4) || (len(parts) > 5) || ((len(parts) == 5) && (parts[4] != "--no-wait")) {
+		app.badCommand()
+		return
+	}
+
+	argServerID, argIP := parts[2], parts[3]
+
+	if !app.haveClient() {
+		return
+	}
+
+	description := fmt.Sprintf("remove public IP %s from %s", argIP, strings.ToUpper(argServerID))
+	operationID, err := app.clc.deletePublicIP(argServerID, argIP)
+	if (len(parts) == 5) || (err != nil) {
+		app.emitOperation(operationID, description, err)
+		return
+	}
+
+	if err = WaitForOperation(app.clc, operationID, description, operationTimeout); err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("public IP %s removed from %s\n", argIP, strings.ToUpper(argServerID))
+}
+
+// for --no-wait, the operation as it stands.  With a description it is also tracked as a job, so that
+// "jobs" lists it until it is over.
+func (app *AppState) emitOperation(operationID string, description string, err error) {
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	op := &OperationRef{OperationID: operationID, Status: OPERATION_NOT_STARTED}
+	if status, err := app.clc.operationStatus(operationID); err == nil {
+		op.Status = status
+	}
+
+	if description != "" {
+		op.JobID = TrackOperation(app.clc, operationID, description, operationTimeout)
+	}
+
+	app.emit(op, func() {
+		if op.JobID != "" {
+			fmt.Printf("operation %s: %s, tracked as %s\n", op.OperationID, op.Status, op.JobID)
+		} else {
+			fmt.Printf("operation %s: %s\n", op.OperationID, op.Status)
+		}
+	})
+	app.bindResult("operation", op, op.OperationID)
+}
+
+func (app *AppState) cmdOperation(argMode string, argOperationID string) {
+	if ((argMode != "status") && (argMode != "wait")) || (argOperationID == "") {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	if argMode == "wait" {
+		if err := WaitForOperation(app.clc, argOperationID, "", operationTimeout); err != nil {
+			app.failf("remote call failed, err=%s\n", err.Error())
+			return
+		}
+	}
+
+	app.emitOperation(argOperationID, "", nil)
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// script files are plain REPL lines, one command per line.  Blank lines and lines starting with # are skipped.
+// Variables come from "set name value" or from the results bound by commands, e.g. after "LB create"
+// $last is the new LBID and ${lb.lbid} the same thing.  A $word that isn't a variable is left as it is,
+// so passwords and descriptions may have a $ in them; $$ is a literal $.  "exit" or "quit" ends the
+// script early.
+
+const maxSourceDepth = 8 // scripts may source other scripts, but not without limit
+
+func cmdLineUsage() {
+	fmt.Printf("Usage:\n")
+	fmt.Printf("\tapiTool                    (interactive)\n")
+	fmt.Printf("\tapiTool [--output FORMAT] run [--continue-on-error] [--transcript file] script.clc\n")
+	fmt.Printf("\t(transcript with per-line timing written to script.clc.transcript unless --transcript says where)\n")
+	fmt.Printf("\tapiTool [--output FORMAT] <any interactive command>, e.g. apiTool plan lb.yaml\n")
+	fmt.Printf("\t(logs in from CLC_API_USERNAME etc, as \"auth env\" does)\n")
+	fmt.Printf("\tapiTool mockserver [--listen addr] [--state file] [--account A] [--user u] [--password p] [--token-ttl 10m]\n")
+	fmt.Printf("\t(local stand-in for the v2 and LBaaS APIs, until interrupted)\n")
+}
+
+// runCommandLine handles a non-interactive invocation, and returns the process exit code
+func (app *AppState) runCommandLine(args []string) int {
+	args, err := app.takeOutputOptions(args) // a command line --output is the default for everything run
+	if (err == nil) && (app.lineQuery != "") {
+		err = fmt.Errorf("--query applies to a single command, put it on the script line")
+	}
+	if (err != nil) || (len(args) == 0) {
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+		}
+		cmdLineUsage()
+		return 1
+	}
+
+	app.outputFormat = app.lineOutput
+	app.envLogin = true // scripts and single commands log in from CLC_API_* unless they do "auth" themselves
+
+	if args[0] == "mockserver" {
+		return runMockServer(args[1:])
+	}
+
+	if args[0] == "run" {
+		path, continueOnError, transcript, err := parseScriptArgs(args[1:])
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+			cmdLineUsage()
+			return 1
+		}
+
+		err = app.runScript(path, continueOnError, transcript)
+		if err != nil {
+			fmt.Printf("script failed: %s\n", err.Error())
+			return 1
+		}
+
+		return app.exitCode // 2 if the script's last drift check found drift
+	}
+
+	processCommand(app, args) // any REPL command, run once:  apiTool plan lb.yaml
+	if app.lastErr != nil {
+		return 1
+	}
+
+	return app.exitCode
+}
+
+// accepts [--continue-on-error] [--transcript file] path, in any order
+func parseScriptArgs(args []string) (path string, continueOnError bool, transcript string, err error) {
+	for idx := 0; idx < len(args); idx++ {
+		s := args[idx]
+
+		if s == "--continue-on-error" {
+			continueOnError = true
+
+		} else if s == "--transcript" {
+			if idx+1 >= len(args) {
+				return "", false, "", fmt.Errorf("--transcript needs a file name")
+			}
+			idx++
+			transcript = args[idx]
+
+		} else if strings.HasPrefix(s, "--transcript=") {
+			transcript = strings.TrimPrefix(s, "--transcript=")
+
+		} else if strings.HasPrefix(s, "--") {
+			return "", false, "", fmt.Errorf("unknown option: %s", s)
+
+		} else if path == "" {
+			path = s
+
+		} else {
+			return "", false, "", fmt.Errorf("only one script file may be given")
+		}
+	}
+
+	if path == "" {
+		return "", false, "", fmt.Errorf("no script file given")
+	}
+
+	return path, continueOnError, transcript, nil
+}
+
+// runScript executes each line of the file through processInputLine, writing a transcript with per-line timing
+// to transcriptPath, or to script.clc.transcript beside the script if none is given.  Returns the first failure.
+func (app *AppState) runScript(path string, continueOnError bool, transcriptPath string) error {
+	if app.depth >= maxSourceDepth {
+		return fmt.Errorf("scripts nested more than %d deep", maxSourceDepth)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if transcriptPath == "" {
+		transcriptPath = path + ".transcript"
+	}
+	tf, err := os.Create(transcriptPath)
+	if err != nil {
+		return err
+	}
+	defer tf.Close()
+
+	app.depth++
+	defer func() { app.depth-- }()
+
+	scriptStart := time.Now()
+	fmt.Fprintf(tf, "# apiTool transcript of %s, started %s\n", path, scriptStart.Format(time.RFC3339))
+
+	nRun := 0
+	nFailed := 0
+	var firstErr error
+
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+
+		line := strings.TrimSpace(scanner.Text())
+		if (line == "") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if (line == "exit") || (line == "quit") {
+			fmt.Fprintf(tf, "%5d  stop  %s\n", lineNo, line)
+			break
+		}
+
+		fmt.Printf("\n%s:%d> %s\n", path, lineNo, transcriptLine(line)) // echo, in place of the REPL prompt
+
+		start := time.Now()
+		processInputLine(app, line)
+		elapsed := time.Since(start)
+
+		nRun++
+		status := "ok"
+		if app.lastErr != nil {
+			status = "FAIL"
+			nFailed++
+
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s:%d: %s", path, lineNo, app.lastErr.Error())
+			}
+		}
+
+		fmt.Fprintf(tf, "%5d  %-4s %8dms  %s", lineNo, status, elapsed.Milliseconds(), transcriptLine(line))
+		if app.lastErr != nil {
+			fmt.Fprintf(tf, "    # %s", app.lastErr.Error())
+		}
+		fmt.Fprintf(tf, "\n")
+
+		if (app.lastErr != nil) && !continueOnError {
+			break
+		}
+	}
+
+	if err := scanner.Err(); (err != nil) && (firstErr == nil) {
+		firstErr = err
+	}
+
+	fmt.Fprintf(tf, "# finished %s: %d lines run, %d failed, %dms elapsed\n",
+		time.Now().Format(time.RFC3339), nRun, nFailed, time.Since(scriptStart).Milliseconds())
+
+	return firstErr
+}
+
+// keep passwords out of the echo and the transcript: auth login's, and any password= argument
+func transcriptLine(line string) string {
+	parts := strings.Fields(line)
+	masked := false
+	if (len(parts) >= 4) && (parts[0] == "auth") && (parts[1] == "login") {
+		parts[3] = "********"
+		masked = true
+	}
+
+	for idx, part := range parts {
+		if strings.HasPrefix(strings.ToLower(part), "password=") {
+			parts[idx] = part[:len("password=")] + "********"
+			masked = true
+		}
+	}
+
+	if masked {
+		return strings.Join(parts, " ")
+	}
+	return line
+}
+
+func (app *AppState) cmdSource(parts []string) { // parts[0]="source"
+	path, continueOnError, transcript, err := parseScriptArgs(parts[1:])
+	if err != nil {
+		app.failf("%s\n", err.Error())
+		return
+	}
+
+	err = app.runScript(path, continueOnError, transcript)
+	if err != nil {
+		app.failf("script failed: %s\n", err.Error())
+	}
+}
+
+//// variables
+
+func isVarNameChar(c byte) bool {
+	return ((c >= 'a') && (c <= 'z')) || ((c >= 'A') && (c <= 'Z')) || ((c >= '0') && (c <= '9')) || (c == '_') || (c == '.')
+}
+
+// expandVars replaces $name and ${name} with variable values.  $$ is a literal $.
+// Names may contain dots (${lb.pools.0.poolid}), a trailing dot is not part of the name.  An undefined
+// ${name} is an error, an undefined $name is left alone (it is more likely part of a password).
+func (app *AppState) expandVars(in string) (string, error) {
+	if !strings.Contains(in, "$") {
+		return in, nil
+	}
+
+	out := make([]byte, 0, len(in))
+	for idx := 0; idx < len(in); idx++ {
+		c := in[idx]
+		if (c != '$') || (idx+1 >= len(in)) {
+			out = append(out, c)
+			continue
+		}
+
+		name := ""
+		braced := false
+		if in[idx+1] == '$' {
+			out = append(out, '$')
+			idx++
+			continue
+
+		} else if in[idx+1] == '{' {
+			end := strings.IndexByte(in[idx+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in line")
+			}
+			name = in[idx+2 : idx+2+end]
+			idx += end + 2
+			braced = true
+
+		} else {
+			end := idx + 1
+			for (end < len(in)) && isVarNameChar(in[end]) {
+				end++
+			}
+			for (end > idx+1) && (in[end-1] == '.') {
+				end--
+			}
+			if end == idx+1 { // lone $, leave it alone
+				out = append(out, c)
+				continue
+			}
+			name = in[idx+1 : end]
+			if _, ok := app.vars[name]; !ok {
+				out = append(out, c)
+				continue
+			}
+			idx = end - 1
+		}
+
+		val, ok := app.vars[name]
+		if !ok && braced {
+			return "", fmt.Errorf("undefined variable: ${%s}", name)
+		}
+		out = append(out, val...)
+	}
+
+	return string(out), nil
+}
+
+func (app *AppState) cmdSet(argName string, parts []string) { // parts[0]="set"
+	if (argName == "") || (len(parts) < 3) {
+		app.badCommand()
+		return
+	}
+
+	for idx := 0; idx < len(argName); idx++ {
+		if !isVarNameChar(argName[idx]) {
+			app.failf("invalid variable name: %s\n", argName)
+			return
+		}
+	}
+
+	app.vars[argName] = strings.Join(parts[2:], " ")
+}
+
+func (app *AppState) cmdUnset(argName string) {
+	if argName == "" {
+		app.badCommand()
+		return
+	}
+
+	delete(app.vars, argName)
+}
+
+func (app *AppState) cmdVars() {
+	names := make([]string, 0, len(app.vars))
+	for name := range app.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("%s=%s\n", name, app.vars[name])
+	}
+}
+
+// bindResult makes a command's result available to later lines: every field of obj becomes
+// prefix.field (lowercased, slices as prefix.field.N and prefix.field.count), and $last is set if given
+func (app *AppState) bindResult(prefix string, obj interface{}, last string) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return // nothing sensible to bind
+	}
+
+	var generic interface{}
+	if json.Unmarshal(b, &generic) != nil {
+		return
+	}
+
+	for name := range app.vars { // forget the previous result under this prefix
+		if strings.HasPrefix(name, prefix+".") {
+			delete(app.vars, name)
+		}
+	}
+
+	flattenVars(app.vars, prefix, generic)
+
+	if last != "" {
+		app.vars["last"] = last
+	}
+}
+
+func flattenVars(vars map[string]string, name string, v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range t {
+			flattenVars(vars, name+"."+strings.ToLower(k), sub)
+		}
+	case []interface{}:
+		vars[name+".count"] = strconv.Itoa(len(t))
+		for idx, sub := range t {
+			flattenVars(vars, fmt.Sprintf("%s.%d", name, idx), sub)
+		}
+	case nil:
+		vars[name] = ""
+	case float64:
+		vars[name] = strconv.FormatFloat(t, 'f', -1, 64)
+	default:
+		vars[name] = fmt.Sprint(t)
+	}
+}
+
+//// assert
+
+// "assert lb.status == ready".  The left side is a variable name if one exists by that name, else
+// taken literally; the right side is literal (use $name there).  == and != ignore case, the ordering
+// operators compare numerically.
+func (app *AppState) cmdAssert(parts []string) { // parts[0]="assert"
+	if len(parts) < 4 {
+		app.badCommand()
+		return
+	}
+
+	lhs, ok := app.vars[parts[1]]
+	if !ok {
+		lhs = parts[1]
+	}
+
+	op := parts[2]
+	rhs := strings.Join(parts[3:], " ")
+
+	passed, err := compareOperands(lhs, op, rhs)
+	if err != nil {
+		app.failf("assert: %s\n", err.Error())
+		return
+	}
+
+	if !passed {
+		app.failf("assertion failed: %s %s %s (got %q)\n", parts[1], op, rhs, lhs)
+		return
+	}
+
+	fmt.Printf("assertion passed: %s %s %s\n", parts[1], op, rhs)
+}
+
+func compareOperands(lhs, op, rhs string) (bool, error) {
+	if op == "==" {
+		return strings.EqualFold(lhs, rhs), nil
+	} else if op == "!=" {
+		return !strings.EqualFold(lhs, rhs), nil
+	}
+
+	l, errL := strconv.ParseFloat(lhs, 64)
+	r, errR := strconv.ParseFloat(rhs, 64)
+	if (errL != nil) || (errR != nil) {
+		return false, fmt.Errorf("%s needs numbers, have %q and %q", op, lhs, rhs)
+	}
+
+	if op == "<" {
+		return l < r, nil
+	} else if op == "<=" {
+		return l <= r, nil
+	} else if op == ">" {
+		return l > r, nil
+	} else if op == ">=" {
+		return l >= r, nil
+	}
+
+	return false, fmt.Errorf("unknown operator %s", op)
+}
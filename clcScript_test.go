@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranscriptLineMasksPasswords(t *testing.T) {
+	cases := map[string]string{
+		"auth login jdoe Secret1":                                "auth login jdoe ********",
+		"server create WA1 web template=UBUNTU password=Secret1": "server create WA1 web template=UBUNTU password=********",
+		"server create WA1 web PASSWORD=Secret1 cpu=2":           "server create WA1 web PASSWORD=******** cpu=2",
+		"LB list": "LB list",
+	}
+
+	for line, want := range cases {
+		if got := transcriptLine(line); got != want {
+			t.Errorf("transcriptLine(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+// a password= argument is neither echoed nor written to the transcript
+func TestScriptTranscriptHasNoPasswords(t *testing.T) {
+	app, _ := newFakeApp(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "create.clc")
+	transcript := filepath.Join(dir, "create.log")
+	if err := os.WriteFile(script, []byte("set spec password=Secret1\n"), 0600); err != nil {
+		t.Fatalf("WriteFile: %s", err.Error())
+	}
+
+	if err := app.runScript(script, false, transcript); err != nil {
+		t.Fatalf("runScript: %s", err.Error())
+	}
+
+	data, err := os.ReadFile(transcript)
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err.Error())
+	}
+	if strings.Contains(string(data), "Secret1") || !strings.Contains(string(data), "password=********") {
+		t.Errorf("transcript:\n%s", data)
+	}
+}
+
+func TestExpandVarsUndefined(t *testing.T) {
+	app, _ := newFakeApp(t)
+	app.vars["lb.lbid"] = "abc123"
+
+	cases := map[string]string{
+		"LB get WA1 $lb.lbid":      "LB get WA1 abc123",
+		"LB get WA1 ${lb.lbid}.":   "LB get WA1 abc123.",
+		"auth login jdoe pa$word1": "auth login jdoe pa$word1", // undefined $name stays literal
+		"echo $$lb.lbid":           "echo $lb.lbid",
+	}
+	for in, want := range cases {
+		got, err := app.expandVars(in)
+		if (err != nil) || (got != want) {
+			t.Errorf("expandVars(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	if got, err := app.expandVars("LB get WA1 ${nosuch}"); err == nil {
+		t.Errorf("undefined ${nosuch} expanded to %q, want an error", got)
+	}
+}
+
+// writes a script to a temp dir, returns its path
+func writeScript(t *testing.T, text string) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "test.clc")
+	if err := os.WriteFile(script, []byte(text), 0600); err != nil {
+		t.Fatalf("WriteFile: %s", err.Error())
+	}
+	return script
+}
+
+func TestScriptAssertFailureExitCode(t *testing.T) {
+	app, _ := newFakeApp(t)
+	script := writeScript(t, "set n 3\nassert n == 3\nassert n > 5\nset after yes\n")
+
+	if code := app.runCommandLine([]string{"run", script}); code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+	if _, ok := app.vars["after"]; ok {
+		t.Errorf("line after the failed assert was run")
+	}
+
+	app, _ = newFakeApp(t)
+	script = writeScript(t, "set n 3\nassert n == 3\nassert n <= 3\n")
+	if code := app.runCommandLine([]string{"run", script}); code != 0 {
+		t.Errorf("exit code %d for passing asserts, want 0", code)
+	}
+}
+
+func TestScriptContinueOnError(t *testing.T) {
+	app, _ := newFakeApp(t)
+	script := writeScript(t, "assert 1 == 2\nset after yes\nassert 3 != 3\n")
+
+	err := app.runScript(script, true, "")
+	if (err == nil) || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("runScript = %v, want the first failure, on line 1", err)
+	}
+	if app.vars["after"] != "yes" {
+		t.Errorf("line after the failed assert was not run")
+	}
+
+	if code := app.runCommandLine([]string{"run", "--continue-on-error", script}); code != 1 {
+		t.Errorf("exit code %d, want 1", code)
+	}
+}
+
+// with no --transcript, the transcript goes beside the script
+func TestScriptTranscriptContents(t *testing.T) {
+	app, _ := newFakeApp(t)
+	script := writeScript(t, "# comment\nset n 3\n\nassert n == 4\nset after yes\n")
+
+	if err := app.runScript(script, true, ""); err == nil {
+		t.Fatalf("runScript succeeded, want the assert to fail")
+	}
+
+	data, err := os.ReadFile(script + ".transcript")
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("transcript has %d lines, want 5:\n%s", len(lines), data)
+	}
+	if !strings.HasPrefix(lines[0], "# apiTool transcript of "+script) {
+		t.Errorf("header: %s", lines[0])
+	}
+
+	want := []struct{ lineNo, status, text string }{
+		{"2", "ok", "set n 3"},
+		{"4", "FAIL", "assert n == 4"},
+		{"5", "ok", "set after yes"},
+	}
+	for idx, w := range want {
+		fields := strings.Fields(lines[idx+1])
+		if (len(fields) < 4) || (fields[0] != w.lineNo) || (fields[1] != w.status) || !strings.HasSuffix(fields[2], "ms") ||
+			!strings.Contains(lines[idx+1], w.text) {
+			t.Errorf("transcript line %d: %q, want line %s %s ...ms %s", idx+1, lines[idx+1], w.lineNo, w.status, w.text)
+		}
+	}
+	if !strings.Contains(lines[2], "# assertion failed") {
+		t.Errorf("failed line has no error: %q", lines[2])
+	}
+	if !strings.Contains(lines[4], "3 lines run, 1 failed") {
+		t.Errorf("summary: %q", lines[4])
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func (app *AppState) cmdServerList(argDC string) {
+	if !app.haveClient() {
+		return
+	}
+
+	servers, err := app.clc.listServers(argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emitServers(servers)
+}
+
+// "server find web WA1":  servers whose ID, description or any IP contains the text, any case
+func (app *AppState) cmdServerFind(argText string, argDC string) {
+	if argText == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	servers, err := app.clc.listServers(argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emitServers(FilterServers(servers, argText))
+}
+
+func (app *AppState) emitServers(servers []ServerDetails) {
+	app.emit(servers, func() {
+		for idx := range servers {
+			printServerSummary(&servers[idx])
+		}
+	})
+
+	app.bindResult("servers", servers, "")
+}
+
+func (app *AppState) cmdServerDetails(argServerID string) {
+	if argServerID == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	server, err := app.clc.inspectServer(argServerID)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(server, func() {
+		printServerSummary(server)
+		fmt.Printf("    status=%s, maintenance=%t, group=%s\n", server.Status, server.InMaintenance, server.GroupID)
+		fmt.Printf("    os=\"%s\", cpu=%d, memoryMB=%d, storageGB=%d\n", server.OSType, server.CPU, server.MemoryMB, server.StorageGB)
+		if server.Description != "" {
+			fmt.Printf("    desc=\"%s\"\n", server.Description)
+		}
+	})
+
+	app.bindResult("server", server, server.ServerID)
+}
+
+func printServerSummary(server *ServerDetails) {
+	fmt.Printf("server: dc=%s, id=%s, power=%s, ip=%s", server.DataCenter, server.ServerID, server.PowerState,
+		strings.Join(server.PrivateIPs, ","))
+	if len(server.PublicIPs) > 0 {
+		fmt.Printf(", public=%s", strings.Join(server.PublicIPs, ","))
+	}
+	fmt.Printf("\n")
+}
+
+//////////////// groups
+
+// "group list DC":  every group in the DC, flattened
+func (app *AppState) cmdGroupList(argDC string) {
+	if argDC == "" {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	root, err := inspectDCGroups(app.clc, argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	groups := root.Flatten()
+	app.emit(groups, func() {
+		for _, g := range groups {
+			fmt.Printf("group: dc=%s, id=%s, path=\"%s\", servers=%d (%d with subgroups)\n",
+				g.DataCenter, g.GroupID, g.Path, g.Servers, g.AllServers)
+		}
+	})
+
+	app.bindResult("groups", groups, "")
+}
+
+// "group tree [DC]":  the groups and their servers, every DC if none is given
+func (app *AppState) cmdGroupTree(argDC string) {
+	if !app.haveClient() {
+		return
+	}
+
+	dcs := []string{argDC}
+	if argDC == "" {
+		all, err := app.clc.listAllDC()
+		if err != nil {
+			app.failf("remote call failed, err=%s\n", err.Error())
+			return
+		}
+
+		dcs = make([]string, len(all))
+		for idx, dc := range all {
+			dcs[idx] = dc.DCID
+		}
+	}
+
+	roots := make([]GroupDetails, 0, len(dcs))
+	for _, dc := range dcs {
+		root, err := inspectDCGroups(app.clc, dc)
+		if err != nil {
+			app.failf("remote call failed, err=%s\n", err.Error())
+			return
+		}
+		roots = append(roots, *root)
+	}
+
+	app.emit(roots, func() {
+		for idx := range roots {
+			printGroupTree(&roots[idx], "")
+		}
+	})
+
+	app.bindResult("groups", roots, "")
+}
+
+func printGroupTree(group *GroupDetails, inset string) {
+	fmt.Printf("%s%s  [%s]\n", inset, group.Name, group.GroupID)
+	for _, id := range group.ServerIDs {
+		fmt.Printf("%s    - %s\n", inset, id)
+	}
+	for idx := range group.Groups {
+		printGroupTree(&group.Groups[idx], inset+"  ")
+	}
+}
+
+// "group details DC name|ID"
+func (app *AppState) cmdGroupDetails(argDC string, argGroup string) {
+	if (argDC == "") || (argGroup == "") {
+		app.badCommand()
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	root, err := inspectDCGroups(app.clc, argDC)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	group, err := root.FindOne(argGroup)
+	if err != nil {
+		app.failf("%s\n", err.Error())
+		return
+	}
+
+	app.emit(group, func() {
+		fmt.Printf("group: dc=%s, id=%s, name=\"%s\", type=%s, parent=%s\n",
+			group.DataCenter, group.GroupID, group.Name, group.Type, group.ParentID)
+		if group.Description != "" {
+			fmt.Printf("  desc=\"%s\"\n", group.Description)
+		}
+		fmt.Printf("  servers:[ %s ]\n", strings.Join(group.ServerIDs, " "))
+		for _, sub := range group.Groups {
+			fmt.Printf("  subgroup: id=%s, name=\"%s\", servers=%d\n", sub.GroupID, sub.Name, len(sub.AllServerIDs()))
+		}
+	})
+
+	app.bindResult("group", group, group.GroupID)
+}
+
+// "pool sync DC LBID PoolID group [target=port] [--dry-run]":  the pool's nodes become the group's
+// active servers
+func (app *AppState) cmdPoolSync(parts []string) { // parts[0:2]="pool sync"
+	if len(parts) < 6 {
+		app.badCommand()
+		return
+	}
+
+	argDC, argLBID, argPoolID, argGroup := parts[2], parts[3], parts[4], parts[5]
+	targetPort := 0
+	dryRun := false
+
+	for _, s := range parts[6:] {
+		if s == "--dry-run" {
+			dryRun = true
+		} else if strings.HasPrefix(s, "target=") {
+			conv, e := strconv.Atoi(strings.TrimPrefix(s, "target="))
+			if (e != nil) || (conv <= 0) {
+				app.failf("invalid target port: %s\n", s)
+				return
+			}
+			targetPort = conv
+		} else {
+			app.badCommand()
+			return
+		}
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	result, err := SyncPoolToGroup(app.clc, argDC, argLBID, argPoolID, argGroup, targetPort, dryRun)
+	if err != nil {
+		app.failf("pool sync failed, err=%s\n", err.Error())
+		return
+	}
+
+	app.emit(result, func() {
+		fmt.Printf("group %s ->", argGroup)
+		for idx := range result.Resolution.Servers {
+			fmt.Printf(" %s=%s", result.Resolution.Servers[idx], result.Resolution.IPs[idx])
+		}
+		fmt.Printf("\n")
+
+		if (len(result.Added) == 0) && (len(result.Removed) == 0) {
+			fmt.Printf("pool %s is in sync\n", argPoolID)
+			return
+		}
+
+		fmt.Printf("  + nodes:[ %s ]\n", nodeListString(result.Added))
+		fmt.Printf("  - nodes:[ %s ]\n", nodeListString(result.Removed))
+		if result.Updated {
+			printPoolDetails(result.Pool, "")
+		} else {
+			fmt.Printf("dry run, pool not updated\n")
+		}
+	})
+
+	app.bindResult("pool", result.Pool, result.Pool.PoolID)
+}
+
+// the CLI's names for serverOperation operations
+var serverOperationVerbs = map[string]string{
+	"poweron":  SERVER_OP_POWER_ON,
+	"poweroff": SERVER_OP_POWER_OFF,
+	"reboot":   SERVER_OP_REBOOT,
+	"shutdown": SERVER_OP_SHUT_DOWN,
+	"pause":    SERVER_OP_PAUSE,
+}
+
+// "server poweron|poweroff|reboot|shutdown|pause id... [--drain] [--no-wait]" and
+// "server maintenance-mode on|off id... [--no-wait]"
+func (app *AppState) cmdServerOperation(parts []string) { // parts[0:2]="server <verb>"
+	operation := serverOperationVerbs[parts[1]]
+	args := parts[2:]
+
+	if parts[1] == "maintenance-mode" {
+		if (len(args) > 0) && (args[0] == "on") {
+			operation = SERVER_OP_START_MAINTENANCE
+		} else if (len(args) > 0) && (args[0] == "off") {
+			operation = SERVER_OP_STOP_MAINTENANCE
+		}
+		if len(args) > 0 {
+			args = args[1:]
+		}
+	}
+
+	ids := make([]string, 0)
+	drain, noWait := false, false
+	for _, s := range args {
+		if s == "--drain" {
+			drain = true
+		} else if s == "--no-wait" {
+			noWait = true
+		} else {
+			ids = append(ids, s)
+		}
+	}
+
+	if (operation == "") || (len(ids) == 0) {
+		app.badCommand()
+		return
+	}
+
+	if drain && (operation != SERVER_OP_POWER_OFF) && (operation != SERVER_OP_SHUT_DOWN) && (operation != SERVER_OP_PAUSE) {
+		app.failf("--drain is for poweroff, shutdown and pause\n")
+		return
+	}
+
+	if !app.haveClient() {
+		return
+	}
+
+	drained := make(map[string][]DrainedNode)
+	if drain { // all the servers at once, so each pool is updated once and waited for before any server stops
+		servers := make([]*ServerDetails, 0, len(ids))
+		for _, id := range ids {
+			server, err := app.clc.inspectServer(id)
+			if err != nil {
+				app.failf("could not drain %s: %s\n", strings.ToUpper(id), err.Error())
+				return
+			}
+			servers = append(servers, server)
+		}
+
+		nodes, err := DrainServers(app.clc, servers, false)
+		for _, d := range nodes {
+			drained[d.ServerID] = append(drained[d.ServerID], d)
+			if app.textOutput() {
+				fmt.Printf("%s: removed %s from pool %s on LB %s\n", d.ServerID, nodeKey(d.Node), d.PoolID, d.LBID)
+			}
+		}
+		if err != nil {
+			app.failf("could not drain: %s\n", err.Error())
+			return
+		}
+	}
+
+	results, err := app.clc.serverOperation(operation, ids)
+	if err != nil {
+		app.failf("remote call failed, err=%s\n", err.Error())
+		return
+	}
+
+	for idx := range results {
+		results[idx].Drained = drained[results[idx].ServerID]
+	}
+
+	failed := 0
+	if noWait {
+		TrackServerOperations(app.clc, operation, results, operationTimeout)
+		for _, result := range results {
+			if !result.Queued {
+				failed++
+			}
+		}
+	} else {
+		failed = WaitForServerOperations(app.clc, operation, results, operationTimeout, func(result *ServerOperationResult) {
+			if app.textOutput() {
+				fmt.Printf("%s: %s %s\n", result.ServerID, operation, result.Status)
+			}
+		})
+	}
+
+	app.emit(results, func() {
+		for _, result := range results {
+			if !result.Queued {
+				fmt.Printf("%s: not queued, %s\n", result.ServerID, result.Error)
+			} else if noWait {
+				fmt.Printf("%s: %s queued as operation %s, tracked as %s\n", result.ServerID, operation, result.OperationID, result.JobID)
+			}
+		}
+	})
+	app.bindResult("operations", results, "")
+
+	if failed > 0 {
+		app.failf("%s failed for %d of %d servers\n", operation, failed, len(results))
+	}
+}
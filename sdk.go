@@ -16,69 +16,412 @@ limitations under the License.
 
 package main
 
-// struct declarations provide the Go object model in which we present the API
+import (
+	"fmt"
+	"net"
+)
+
+// struct declarations provide the Go object model in which we present the API.
+// The json tags are the stable field names used by the --output renderers, not the wire format
 type DataCenterName struct {
-	DCID string
-	Name string
+	DCID string `json:"dcid"`
+	Name string `json:"name"`
 }
 
 type PoolNode struct {
-	TargetIP   string // send traffic to this host
-	TargetPort int    // at this port
+	TargetIP   string `json:"targetIP"`   // send traffic to this host
+	TargetPort int    `json:"targetPort"` // at this port
 }
 
 type HealthCheckDetails struct {
-	Unhealthy int
-	Healthy int
-	Interval int
-	TargetPort int
-	Mode string
+	Unhealthy  int    `json:"unhealthy"`
+	Healthy    int    `json:"healthy"`
+	Interval   int    `json:"interval"`
+	TargetPort int    `json:"targetPort"`
+	Mode       string `json:"mode"`
 }
 
 type PoolDetails struct {
-	PoolID string
-	LBID   string // LB this pool belongs to
+	PoolID string `json:"poolID"`
+	LBID   string `json:"lbid"` // LB this pool belongs to
+
+	IncomingPort int                 `json:"incomingPort"` // docs say 'the port on which incoming traffic will send requests', believed to mean 'where the LB is listening on the outside'
+	Method       string              `json:"method"`       // one of: 'roundrobin', 'leastconn'   Q: how to declare suitable constants for those
+	Health       *HealthCheckDetails `json:"health"`
+	Persistence  string              `json:"persistence"` // e.g. 'none'
+	TimeoutMS    int64               `json:"timeoutMS"`
+	Mode         string              `json:"mode"` // one of: 'tcp', 'http'
+
+	Nodes []PoolNode `json:"nodes"`
+
+	Request *LBRequest `json:"request,omitempty"` // from createPool and updatePool, the change to wait for
+}
 
-	IncomingPort int    // docs say 'the port on which incoming traffic will send requests', believed to mean 'where the LB is listening on the outside'
-	Method       string // one of: 'roundrobin', 'leastconn'   Q: how to declare suitable constants for those
-	Health       *HealthCheckDetails
-	Persistence string // e.g. 'none'
-	TimeoutMS   int64
-	Mode        string // one of: 'tcp', 'http'
+// where a node gets traffic when no port is given for it
+const defaultTargetPort = 8080
 
-	Nodes []PoolNode
+// the values a new pool gets for anything not specified
+func defaultPoolDetails() PoolDetails {
+	return PoolDetails{
+		IncomingPort: 8080,
+		Method:       "roundrobin",
+		Health:       nil,
+		Persistence:  "none",
+		TimeoutMS:    1000,
+		Mode:         "tcp",
+	}
 }
 
 // Q: createLB to return this?  Or to just invoke inspectLB and return LBDetails?
 type LoadBalancerCreationInfo struct {
-	LBID        string // the ID should be enough.  This is only a struct so that we have a place to put new fields later if desired
-	RequestTime int64  // per the server-side clock, whose synchronization with any other clock is unknown
+	LBID        string `json:"lbid"`            // the ID should be enough.  This is only a struct so that we have a place to put new fields later if desired
+	RequestTime int64  `json:"requestTime"`     // per the server-side clock, whose synchronization with any other clock is unknown
+	Status      string `json:"status"`          // the request's, the LB itself may still be provisioning
+	JobID       string `json:"jobID,omitempty"` // tracking the request, see TrackLB
+}
+
+// the request object an LB API change returns.  The change is only certain once it is COMPLETE.
+type LBRequest struct {
+	RequestID   string `json:"requestID"`
+	DataCenter  string `json:"dataCenter"`
+	Status      string `json:"status"` // e.g. ACCEPTED, IN_PROGRESS, COMPLETE, FAILED
+	Description string `json:"description"`
+	Href        string `json:"href,omitempty"`  // its status (or self) link, where to poll it.  "" and it can't be tracked
+	JobID       string `json:"jobID,omitempty"` // tracking the request, see TrackLBRequest
 }
 
 type LoadBalancerDetails struct {
-	LBID        string
-	Name        string // unique within dc ?
-	Description string
-	PublicIP    string // omit privateIP, what would that mean?
-	Pools       []PoolDetails
-	Status      string // list of valid states?
-	DataCenter  string
+	LBID        string        `json:"lbid"`
+	Name        string        `json:"name"` // unique within dc ?
+	Description string        `json:"description"`
+	PublicIP    string        `json:"publicIP"` // omit privateIP, what would that mean?
+	Pools       []PoolDetails `json:"pools"`
+	Status      string        `json:"status"` // list of valid states?
+	DataCenter  string        `json:"dataCenter"`
 }
 
 type LoadBalancerSummary struct {
-	LBID        string
-	Name        string
-	Description string
-	PublicIP    string
-	DataCenter  string
+	LBID        string `json:"lbid"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PublicIP    string `json:"publicIP"`
+	DataCenter  string `json:"dataCenter"`
+}
+
+// from the v2 API.  A server's ID is also its name, e.g. WA1ACMEWEB01
+type ServerDetails struct {
+	ServerID      string   `json:"id"`
+	Description   string   `json:"description"`
+	DataCenter    string   `json:"dataCenter"`
+	GroupID       string   `json:"groupID"`
+	Status        string   `json:"status"`     // e.g. 'active', 'underConstruction', 'queuedForDelete'
+	PowerState    string   `json:"powerState"` // one of: 'started', 'stopped', 'paused'
+	InMaintenance bool     `json:"inMaintenance"`
+	OSType        string   `json:"osType"`
+	CPU           int      `json:"cpu"`
+	MemoryMB      int      `json:"memoryMB"`
+	StorageGB     int      `json:"storageGB"`
+	PrivateIPs    []string `json:"privateIPs"`
+	PublicIPs     []string `json:"publicIPs"`
+}
+
+// the first private IP, which is what a pool node wants.  "" if the server has none (yet).
+func (s *ServerDetails) PrivateIP() string {
+	if len(s.PrivateIPs) == 0 {
+		return ""
+	}
+	return s.PrivateIPs[0]
+}
+
+// what became of one server in a batch power or maintenance operation
+type ServerOperationResult struct {
+	ServerID    string `json:"serverID"`
+	Queued      bool   `json:"queued"`
+	OperationID string `json:"operationID,omitempty"`
+	Status      string `json:"status,omitempty"` // the operation's, as last seen
+	Error       string `json:"error,omitempty"`  // why it wasn't queued, or why it failed
+	JobID       string `json:"jobID,omitempty"`  // tracking the operation, see sdkJobs.go
+
+	Drained []DrainedNode `json:"drained,omitempty"` // pool nodes taken out first, see DrainServers
+}
+
+type PublicIPPort struct {
+	Protocol string `json:"protocol"` // one of: 'TCP', 'UDP', 'ICMP'
+	Port     int    `json:"port"`
+	PortTo   int    `json:"portTo,omitempty"` // for a range, Port to PortTo
+}
+
+// "TCP/80", "TCP/8000-8010" or "ICMP", as ParsePortSpec takes them
+func (p PublicIPPort) String() string {
+	if p.Protocol == "ICMP" {
+		return p.Protocol
+	} else if p.PortTo > 0 {
+		return fmt.Sprintf("%s/%d-%d", p.Protocol, p.Port, p.PortTo)
+	}
+	return fmt.Sprintf("%s/%d", p.Protocol, p.Port)
+}
+
+// a public IP NAT'd to one of a server's private IPs, open on some ports, to some sources
+type PublicIPDetails struct {
+	PublicIP           string         `json:"publicIP"` // "" until the add completes
+	ServerID           string         `json:"serverID"`
+	InternalIP         string         `json:"internalIP"` // "" on add means the API picks one
+	Ports              []PublicIPPort `json:"ports"`
+	SourceRestrictions []string       `json:"sourceRestrictions"` // CIDRs allowed in, none means anyone
+}
+
+// a firewall policy lets traffic from its sources to its destinations.  Intra-DC policies join networks
+// within one datacenter (perhaps of another account), cross-DC ones join a CIDR in this datacenter to one
+// in another.
+type FirewallPolicy struct {
+	PolicyID           string   `json:"policyID"`
+	DataCenter         string   `json:"dataCenter"`
+	CrossDC            bool     `json:"crossDC"`
+	Status             string   `json:"status"` // e.g. 'active', 'pending', 'failed'
+	Enabled            bool     `json:"enabled"`
+	Sources            []string `json:"sources"`                      // CIDRs, exactly one if CrossDC
+	Destinations       []string `json:"destinations"`                 // CIDRs, exactly one if CrossDC
+	DestinationAccount string   `json:"destinationAccount,omitempty"` // "" for this account
+	DestinationDC      string   `json:"destinationDC,omitempty"`      // CrossDC only
+	Ports              []string `json:"ports"`                        // not CrossDC: "any", "icmp", "tcp/80", "udp/1-100"
+}
+
+type NetworkIP struct {
+	Address  string `json:"address"`
+	Claimed  bool   `json:"claimed"`
+	ServerID string `json:"serverID,omitempty"`
+	Type     string `json:"type"` // one of: 'private', 'publicMapped', 'virtual'
+}
+
+// a network claimed by the account in one datacenter
+type NetworkDetails struct {
+	NetworkID   string      `json:"networkID"`
+	DataCenter  string      `json:"dataCenter"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CIDR        string      `json:"cidr"`
+	Gateway     string      `json:"gateway"`
+	Netmask     string      `json:"netmask"`
+	Type        string      `json:"type"` // 'private' or 'publishing'
+	VLAN        int         `json:"vlan"`
+	IPAddresses []NetworkIP `json:"ipAddresses,omitempty"` // inspectNetwork only, as many as were asked for
+}
+
+// whether ip is within the network's CIDR
+func (n *NetworkDetails) Contains(ip string) bool {
+	_, ipnet, err := net.ParseCIDR(n.CIDR)
+	addr := net.ParseIP(ip)
+	return (err == nil) && (addr != nil) && ipnet.Contains(addr)
+}
+
+// a template new servers can be built from, see deploymentCapabilities
+type ServerTemplate struct {
+	Name               string   `json:"name"` // what ServerSpec.Template wants, e.g. 'UBUNTU-22-64-TEMPLATE'
+	OSType             string   `json:"osType"`
+	Description        string   `json:"description"`
+	StorageSizeGB      int      `json:"storageSizeGB"`
+	Capabilities       []string `json:"capabilities"` // e.g. 'cpuAutoscale', 'gpuCapable'
+	ReservedDrivePaths []string `json:"reservedDrivePaths"`
+}
+
+// a network new servers in the datacenter can be put on
+type DeployableNetwork struct {
+	NetworkID string `json:"networkID"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	AccountID string `json:"accountID"`
+}
+
+// an OS an imported OVF can be declared as
+type ImportableOSType struct {
+	ID                 int    `json:"id"`
+	Type               string `json:"type"`
+	Description        string `json:"description"`
+	LabProductCode     string `json:"labProductCode,omitempty"`
+	PremiumProductCode string `json:"premiumProductCode,omitempty"`
+}
+
+// what a datacenter can build new servers from and on, and which services it offers
+type DeploymentCapabilities struct {
+	DataCenter                 string              `json:"dataCenter"`
+	SupportsPremiumStorage     bool                `json:"supportsPremiumStorage"`
+	SupportsBareMetal          bool                `json:"supportsBareMetal"`          // see bareMetalCapabilities
+	SupportsSharedLoadBalancer bool                `json:"supportsSharedLoadBalancer"` // the legacy shared LB, not the LB API's, see lbServiceOffered
+	Networks                   []DeployableNetwork `json:"networks"`
+	Templates                  []ServerTemplate    `json:"templates"`
+	ImportableOSTypes          []ImportableOSType  `json:"importableOSTypes"`
+}
+
+// StorageTypes is what ServerSpec.StorageType can be in the datacenter
+func (caps *DeploymentCapabilities) StorageTypes() []string {
+	ret := []string{STORAGE_STANDARD}
+	if caps.SupportsPremiumStorage {
+		ret = append(ret, STORAGE_PREMIUM)
+	}
+	return append(ret, STORAGE_HYPERSCALE) // with SERVER_TYPE_HYPERSCALE only
 }
 
+// a bare-metal configuration, priced per hour
+type BareMetalSKU struct {
+	ID             string           `json:"id"`
+	HourlyRate     float64          `json:"hourlyRate"`
+	Availability   string           `json:"availability"` // 'high', 'low' or 'none'
+	MemoryGB       int              `json:"memoryGB"`
+	Processor      string           `json:"processor"`
+	Sockets        int              `json:"sockets"`
+	CoresPerSocket int              `json:"coresPerSocket"`
+	Storage        []BareMetalDrive `json:"storage"`
+}
+
+type BareMetalDrive struct {
+	CapacityGB int    `json:"capacityGB"`
+	Type       string `json:"type"` // 'Hdd' or 'Ssd'
+	SpeedRpm   int    `json:"speedRpm"`
+}
+
+// an OS bare-metal servers can run, priced per socket per hour
+type BareMetalOS struct {
+	Type                string  `json:"type"`
+	Description         string  `json:"description"`
+	HourlyRatePerSocket float64 `json:"hourlyRatePerSocket"`
+}
+
+// what bare-metal servers a datacenter can build, if its DeploymentCapabilities say it can
+type BareMetalCapabilities struct {
+	SKUs             []BareMetalSKU `json:"skus"`
+	OperatingSystems []BareMetalOS  `json:"operatingSystems"`
+}
+
+// everything known about a datacenter, see InspectDataCenter
+type DataCenterDetails struct {
+	DCID          string                 `json:"dcid"`
+	Name          string                 `json:"name"`
+	StorageTypes  []string               `json:"storageTypes"`
+	Capabilities  DeploymentCapabilities `json:"capabilities"`
+	BareMetal     *BareMetalCapabilities `json:"bareMetal,omitempty"` // nil where it isn't offered
+	LoadBalancing bool                   `json:"loadBalancing"`       // whether createLB can work there, see lbServiceOffered
+}
+
+// a server for createServer to build, see ValidateServerSpec.  The API names it from the DC, account
+// alias, Name and a number, e.g. WEB -> WA1ACMEWEB03.
+type ServerSpec struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	GroupID      string            `json:"groupID"`
+	Template     string            `json:"template"` // a ServerTemplate name
+	CPU          int               `json:"cpu"`
+	MemoryGB     int               `json:"memoryGB"`
+	Type         string            `json:"type"`                   // one of the SERVER_TYPE_ values
+	StorageType  string            `json:"storageType"`            // one of the STORAGE_ values
+	NetworkID    string            `json:"networkID,omitempty"`    // "" for the DC's default network
+	IPAddress    string            `json:"ipAddress,omitempty"`    // "" for the next free address
+	Password     string            `json:"-"`                      // "" has the API make one up
+	CustomFields map[string]string `json:"customFields,omitempty"` // value by custom field ID
+}
+
+// a server being built.  Until it is, it has no ID, only a UUID (see inspectServerUUID).
+type ServerCreationInfo struct {
+	Name        string `json:"name"` // as asked for
+	UUID        string `json:"uuid"`
+	OperationID string `json:"operationID"`
+	JobID       string `json:"jobID,omitempty"` // tracking the build, see TrackServerCreate
+}
+
+// a group and everything under it.  Each datacenter has one root group holding the others.
+type GroupDetails struct {
+	GroupID     string         `json:"groupID"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	DataCenter  string         `json:"dataCenter"`
+	ParentID    string         `json:"parentID"` // "" for the root group
+	Type        string         `json:"type"`     // 'default', or e.g. 'archive' for the system groups
+	ServerIDs   []string       `json:"servers"`  // directly in this group, not in subgroups
+	Groups      []GroupDetails `json:"groups"`
+}
+
+// every server in the group and its subgroups
+func (g *GroupDetails) AllServerIDs() []string {
+	ret := append([]string{}, g.ServerIDs...)
+	for idx := range g.Groups {
+		ret = append(ret, g.Groups[idx].AllServerIDs()...)
+	}
+	return ret
+}
+
+// the groups at or under g whose name or ID is nameOrID
+func (g *GroupDetails) Find(nameOrID string) []*GroupDetails {
+	ret := make([]*GroupDetails, 0)
+	if (g.Name == nameOrID) || (g.GroupID == nameOrID) {
+		ret = append(ret, g)
+	}
+	for idx := range g.Groups {
+		ret = append(ret, g.Groups[idx].Find(nameOrID)...)
+	}
+	return ret
+}
+
+// a group on its own, for listings
+type GroupSummary struct {
+	GroupID    string `json:"groupID"`
+	Name       string `json:"name"`
+	Path       string `json:"path"` // names from the root group down, joined with /
+	DataCenter string `json:"dataCenter"`
+	ParentID   string `json:"parentID"`
+	Type       string `json:"type"`
+	Servers    int    `json:"servers"`    // directly in the group
+	AllServers int    `json:"allServers"` // subgroups included
+}
+
+// Flatten lists g and everything under it, parents before children
+func (g *GroupDetails) Flatten() []GroupSummary {
+	return g.flatten("")
+}
+
+func (g *GroupDetails) flatten(parentPath string) []GroupSummary {
+	path := g.Name
+	if parentPath != "" {
+		path = parentPath + "/" + g.Name
+	}
+
+	ret := []GroupSummary{{
+		GroupID:    g.GroupID,
+		Name:       g.Name,
+		Path:       path,
+		DataCenter: g.DataCenter,
+		ParentID:   g.ParentID,
+		Type:       g.Type,
+		Servers:    len(g.ServerIDs),
+		AllServers: len(g.AllServerIDs()),
+	}}
+
+	for idx := range g.Groups {
+		ret = append(ret, g.Groups[idx].flatten(path)...)
+	}
+	return ret
+}
+
+// FindOne is Find for when only one will do
+func (g *GroupDetails) FindOne(nameOrID string) (*GroupDetails, error) {
+	found := g.Find(nameOrID)
+	if len(found) == 0 {
+		return nil, fmt.Errorf("no group %s in %s", nameOrID, g.DataCenter)
+	} else if len(found) > 1 {
+		return nil, fmt.Errorf("%d groups are named %s in %s, use the group ID", len(found), nameOrID, g.DataCenter)
+	}
+	return found[0], nil
+}
+
+// both implementations (clcImpl, FakeClient) are safe for concurrent use by multiple goroutines,
+// including a token renewal in the middle of parallel calls
 type CenturyLinkClient interface {
 	// authentication
 	logout()
 	hasCredentials() bool
 	getUsername() string
 	getAccountAlias() string
+	configure(opts ...ClientOption) // changes options given at login, e.g. turning on debug
+	getStats() []HostStats          // per host calls, throttling and rate limiter waits
+	exportEnv() string              // "export CLC_API_TOKEN=..." lines to reuse this login elsewhere, "" if there is none
 
 	// datacenter identification
 	listAllDC() ([]DataCenterName, error)
@@ -88,17 +431,59 @@ type CenturyLinkClient interface {
 	deleteLB(dc, lbid string) (bool, error)
 	inspectLB(dc, lbid string) (*LoadBalancerDetails, HttpError)
 	listAllLB() ([]LoadBalancerSummary, error)
+	lbServiceOffered(dc string) (bool, error) // false where the LB API answers 404, it isn't in that DC
 
 	inspectPool(dc, lbid, poolid string) (*PoolDetails, error)
 	createPool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) // send in newpool.PoolID=nil, the return will have it filled in
 	updatePool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) // send in newpool.PoolID, that's the pool whose details to update
-	deletePool(dc, lbid string, poolID string) error
+	deletePool(dc, lbid string, poolID string) (*LBRequest, error)
+	inspectLBRequest(req *LBRequest) (*LBRequest, error) // how a pool change is getting on, from req.Href
+
+	// servers and groups (v2 API)
+	inspectServer(serverID string) (*ServerDetails, error)
+	listServers(dc string) ([]ServerDetails, error) // every server in the DC's groups, dc="" for all DCs
+	rootGroupID(dc string) (string, error)
+	inspectGroup(groupID string) (*GroupDetails, error) // with its subgroups, all the way down
+
+	// power and maintenance operations on many servers at once, one of the SERVER_OP_ values.  Each server
+	// that could be queued has its own operation to wait for.
+	serverOperation(operation string, serverIDs []string) ([]ServerOperationResult, error)
+
+	// server public IPs.  Changes are asynchronous, they return the ID of an operation to wait for.
+	inspectPublicIP(serverID, publicIP string) (*PublicIPDetails, error)
+	addPublicIP(serverID string, ip *PublicIPDetails) (string, error)
+	updatePublicIP(serverID string, ip *PublicIPDetails) (string, error) // ip.PublicIP says which
+	deletePublicIP(serverID, publicIP string) (string, error)
+
+	// firewall policies.  crossDC picks the cross-datacenter API, policy.CrossDC does for create and update.
+	listFirewallPolicies(dc string, crossDC bool) ([]FirewallPolicy, error)
+	inspectFirewallPolicy(dc string, crossDC bool, policyID string) (*FirewallPolicy, error)
+	createFirewallPolicy(dc string, policy *FirewallPolicy) (string, error) // returns the new PolicyID
+	updateFirewallPolicy(dc string, policy *FirewallPolicy) error           // a cross-DC policy can only be enabled or disabled
+	deleteFirewallPolicy(dc string, crossDC bool, policyID string) error
+
+	// networks.  A claim is asynchronous, it returns the ID of an operation to wait for.
+	listNetworks(dc string) ([]NetworkDetails, error)
+	inspectNetwork(dc, networkID, ipAddresses string) (*NetworkDetails, error) // ipAddresses is one of the NETWORK_IPS_ values
+	claimNetwork(dc string) (string, error)
+	releaseNetwork(dc, networkID string) error
+
+	// provisioning.  A new server is asynchronous, createServer returns the operation to wait for.
+	deploymentCapabilities(dc string) (*DeploymentCapabilities, error)
+	bareMetalCapabilities(dc string) (*BareMetalCapabilities, error)
+	createServer(spec *ServerSpec) (*ServerCreationInfo, error) // spec.GroupID says which DC
+	inspectServerUUID(uuid string) (*ServerDetails, error)      // a server by the UUID it has while being built
+
+	// v2 operations
+	operationStatus(operationID string) (string, error) // one of the OPERATION_ values
+	jobTracker() *JobTracker                            // where waits on operations and LB requests are tracked
+	uncached() CenturyLinkClient                        // the same login, but GETs skip any ResponseCache, for polling
 }
 
-func ClientLogin(username, password string) (CenturyLinkClient, error) {
-	return implClientLogin(username, password)
+func ClientLogin(username, password string, opts ...ClientOption) (CenturyLinkClient, error) {
+	return implClientLogin(username, password, opts...)
 }
 
-func ClientReload() (CenturyLinkClient, error) {
-	return implClientFromEnv()
+func ClientReload(opts ...ClientOption) (CenturyLinkClient, error) {
+	return implClientFromEnv(opts...)
 }
@@ -0,0 +1,115 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+//// InspectAll runs inspectLB for many LBs at once.  Results come back in the order of the summaries
+//// given, and one LB failing doesn't stop the others:  its InspectResult carries the error instead.
+
+const (
+	INSPECT_DEFAULT_WORKERS = 8
+	INSPECT_MAX_WORKERS     = 64
+)
+
+type InspectOptions struct {
+	Workers   int     // calls in flight at once, 0 for INSPECT_DEFAULT_WORKERS
+	PerSecond float64 // calls started per second across all workers, 0 for no limit
+}
+
+type InspectResult struct {
+	Summary LoadBalancerSummary  `json:"summary"`
+	Details *LoadBalancerDetails `json:"details,omitempty"` // nil if Err
+	Err     error                `json:"-"`
+	Error   string               `json:"error,omitempty"` // Err as text, for output
+}
+
+func InspectAll(clc CenturyLinkClient, summaries []LoadBalancerSummary, opts InspectOptions) []InspectResult {
+	var tick <-chan time.Time // nil means no pacing
+	if opts.PerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.PerSecond))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	results := make([]InspectResult, len(summaries))
+
+	forEachParallel(len(summaries), opts.Workers, tick, func(idx int) {
+		summary := summaries[idx]
+		results[idx].Summary = summary
+
+		details, err := clc.inspectLB(strings.ToUpper(summary.DataCenter), summary.LBID)
+		if err != nil {
+			results[idx].Err = err
+			results[idx].Error = err.Error()
+		} else {
+			results[idx].Details = details
+		}
+	})
+
+	return results
+}
+
+// forEachParallel calls fn(0) .. fn(n-1) from a pool of workers (0 for INSPECT_DEFAULT_WORKERS), and
+// returns when all are done.  If tick is set, each call waits for a tick before it starts.
+func forEachParallel(n, workers int, tick <-chan time.Time, fn func(idx int)) {
+	if workers <= 0 {
+		workers = INSPECT_DEFAULT_WORKERS
+	} else if workers > INSPECT_MAX_WORKERS {
+		workers = INSPECT_MAX_WORKERS
+	}
+	if workers > n {
+		workers = n
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range next {
+				fn(idx)
+			}
+		}()
+	}
+
+	for idx := 0; idx < n; idx++ {
+		if tick != nil {
+			<-tick
+		}
+		next <- idx
+	}
+	close(next)
+	wg.Wait()
+}
+
+// InspectErrors counts the results that failed
+func InspectErrors(results []InspectResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Err != nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//// an optional read-through cache of GET responses, so listAllDC, listAllLB and inspectLB (and
+//// inspectPool, which is an inspectLB) don't go to the network every time.  Turn it on with
+//// WithCache(NewResponseCache(opts)).  One cache may be shared by clients of the same account.
+////
+//// Within its TTL an entry is used as is.  After that, if the server gave an ETag, the call is sent
+//// with If-None-Match and a 304 renews the entry without a new body.  Any POST, PUT or DELETE to a
+//// host drops everything cached from that host, so a change is never hidden by the cache.  LB changes
+//// are asynchronous though (an LB goes on "building" for a while), so what is fetched within a TTL of
+//// a change is always revalidated rather than served as is.
+
+type CacheOptions struct {
+	DatacenterTTL   time.Duration // /v2/datacenters, which almost never changes
+	LoadBalancerTTL time.Duration // GETs to the LB API host
+	Path            string        // if set, the cache is loaded from and saved to this file
+}
+
+var defaultCacheOptions = CacheOptions{DatacenterTTL: 24 * time.Hour, LoadBalancerTTL: 30 * time.Second}
+
+type CacheStats struct {
+	Entries     int    `json:"entries"`
+	Hits        int    `json:"hits"`        // answered from the cache, no call made
+	Revalidated int    `json:"revalidated"` // 304, the cached body was still good
+	Misses      int    `json:"misses"`
+	Path        string `json:"path,omitempty"`
+}
+
+type cacheEntry struct {
+	Body    string    `json:"body"`
+	ETag    string    `json:"etag,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+type ResponseCache struct {
+	mu      sync.Mutex
+	opts    CacheOptions
+	entries map[string]*cacheEntry // by server + uri
+	mutated map[string]time.Time   // by server, the last POST, PUT or DELETE
+	stats   CacheStats
+}
+
+// NewResponseCache loads opts.Path if it exists, a file that can't be read just means an empty cache.
+// A zero TTL leaves that kind of GET uncached.
+func NewResponseCache(opts CacheOptions) *ResponseCache {
+	c := &ResponseCache{opts: opts, entries: make(map[string]*cacheEntry), mutated: make(map[string]time.Time)}
+
+	if opts.Path != "" {
+		data, err := ioutil.ReadFile(opts.Path)
+		if err == nil {
+			err = json.Unmarshal(data, &c.entries)
+		}
+		if (err != nil) && !os.IsNotExist(err) {
+			sdkLog(fmt.Sprintf("ignoring cache file %s: %s", opts.Path, err.Error()))
+			c.entries = make(map[string]*cacheEntry)
+		}
+	}
+
+	return c
+}
+
+// 0 means this GET isn't cached
+func (c *ResponseCache) ttlFor(cfg *clcConfig, server, uri string) time.Duration {
+	if (server == cfg.serverAPIV2) && strings.HasPrefix(uri, "/v2/datacenters/") {
+		return c.opts.DatacenterTTL
+	}
+
+	if (server == cfg.serverLB) && !strings.HasPrefix(uri, "/v2") { // not v2 calls, when both are one host
+		return c.opts.LoadBalancerTTL
+	}
+
+	return 0
+}
+
+// lookup returns a copy of the entry, and whether it may be used without asking the server
+func (c *ResponseCache) lookup(server, key string, ttl time.Duration) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	ret := *entry
+	settled := entry.Fetched.Sub(c.mutated[server]) >= ttl
+	fresh := settled && (time.Since(entry.Fetched) < ttl)
+	if fresh {
+		c.stats.Hits++
+	}
+	return &ret, fresh
+}
+
+func (c *ResponseCache) store(key, etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &cacheEntry{Body: string(body), ETag: etag, Fetched: time.Now()}
+	c.save()
+}
+
+// a 304 says the cached body is still current
+func (c *ResponseCache) renew(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Revalidated++
+	if entry, found := c.entries[key]; found {
+		entry.Fetched = time.Now()
+		c.save()
+	}
+}
+
+// invalidate drops every entry from server
+func (c *ResponseCache) invalidate(server string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.mutated[server] = time.Now()
+
+	n := len(c.entries)
+	for key := range c.entries {
+		if strings.HasPrefix(key, server+"/") {
+			delete(c.entries, key)
+		}
+	}
+
+	if len(c.entries) != n {
+		c.save()
+	}
+}
+
+func (c *ResponseCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*cacheEntry)
+	c.save()
+}
+
+func (c *ResponseCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ret := c.stats
+	ret.Entries = len(c.entries)
+	ret.Path = c.opts.Path
+	return ret
+}
+
+// called with mu held.  Written to a temporary file and renamed, so a crash leaves the old cache.
+func (c *ResponseCache) save() {
+	if c.opts.Path == "" {
+		return
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err == nil {
+		tmp := c.opts.Path + ".tmp"
+		err = ioutil.WriteFile(tmp, data, 0600)
+		if err == nil {
+			err = os.Rename(tmp, c.opts.Path)
+		}
+	}
+
+	if err != nil {
+		sdkLog(fmt.Sprintf("could not save cache %s: %s", c.opts.Path, err.Error()))
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//// cassettes: a record of HTTP request/response pairs, written by CassetteRecorder during a real session
+//// and served back by CassettePlayer, which never touches the network.  Install either with WithTransport.
+////
+//// Secrets are scrubbed before anything reaches the file:  no request headers are kept (so no
+//// Authorization), and any "password" or "bearerToken" value in a body is replaced.  Replay works
+//// anyway since the client only needs some token, not a particular one.
+
+const CASSETTE_SCRUBBED = "SCRUBBED"
+
+type CassetteRequest struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	URI    string `json:"uri"` // path and query
+	Body   string `json:"body,omitempty"`
+}
+
+type CassetteResponse struct {
+	StatusCode  int    `json:"statusCode"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+type Cassette struct {
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+func scrubCassetteBody(body string) string {
+	return redactJSONSecrets(body, CASSETTE_SCRUBBED)
+}
+
+// reads and replaces the request body, so it can still be sent
+func takeRequestBody(req *http.Request) (string, error) {
+	if req.Body == nil {
+		return "", nil
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cassette{}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return c, nil
+}
+
+// written to a temporary file and renamed, so the cassette on disk is always complete
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp") // another recorder may be saving beside it
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly after the rename
+
+	_, err = tmp.Write(append(data, '\n'))
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+//////////////// recording
+
+type CassetteRecorder struct {
+	mu       sync.Mutex
+	path     string
+	inner    http.RoundTripper
+	cassette Cassette
+}
+
+// NewCassetteRecorder passes each call to inner (nil for the network), and saves the cassette to path after every call
+func NewCassetteRecorder(path string, inner http.RoundTripper) *CassetteRecorder {
+	if inner == nil {
+		inner = networkTransport()
+	}
+
+	return &CassetteRecorder{
+		path:     path,
+		inner:    inner,
+		cassette: Cassette{Interactions: make([]CassetteInteraction, 0)},
+	}
+}
+
+func (r *CassetteRecorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cassette.Interactions)
+}
+
+func (r *CassetteRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := takeRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err // nothing to replay for a failed connection
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, CassetteInteraction{
+		Request: CassetteRequest{
+			Method: req.Method,
+			Host:   req.URL.Host,
+			URI:    req.URL.RequestURI(),
+			Body:   scrubCassetteBody(reqBody),
+		},
+		Response: CassetteResponse{
+			StatusCode:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        scrubCassetteBody(string(respBody)),
+		},
+	})
+
+	err = r.cassette.Save(r.path)
+	if err != nil {
+		sdkLog(fmt.Sprintf("could not save cassette %s: %s", r.path, err.Error()))
+	}
+
+	return resp, nil
+}
+
+//////////////// replay
+
+type CassettePlayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+func NewCassettePlayer(c *Cassette) *CassettePlayer {
+	return &CassettePlayer{
+		cassette: c,
+		used:     make([]bool, len(c.Interactions)),
+	}
+}
+
+// Remaining counts interactions not yet served, normally 0 at the end of a replayed session
+func (p *CassettePlayer) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, used := range p.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// serves the first unused interaction with the same method, URI and (scrubbed) body.  The host is not
+// compared, so a cassette recorded against staging replays whatever the servers are set to.  Each
+// interaction is served once, so a 401 followed by a successful retry replays the same way.
+func (p *CassettePlayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := takeRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	reqBody = scrubCassetteBody(reqBody)
+	uri := req.URL.RequestURI()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for idx := range p.cassette.Interactions {
+		it := &p.cassette.Interactions[idx]
+		if p.used[idx] || (it.Request.Method != req.Method) || (it.Request.URI != uri) || !sameCassetteBody(it.Request.Body, reqBody) {
+			continue
+		}
+
+		p.used[idx] = true
+
+		header := make(http.Header)
+		if it.Response.ContentType != "" {
+			header.Set("Content-Type", it.Response.ContentType)
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", it.Response.StatusCode, http.StatusText(it.Response.StatusCode)),
+			StatusCode:    it.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewBufferString(it.Response.Body)),
+			ContentLength: int64(len(it.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("cassette has no unused interaction for %s %s", req.Method, uri)
+}
+
+// JSON bodies compare by content, so key order and whitespace don't matter
+func sameCassetteBody(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	var va, vb interface{}
+	if (json.Unmarshal([]byte(a), &va) != nil) || (json.Unmarshal([]byte(b), &vb) != nil) {
+		return false
+	}
+
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
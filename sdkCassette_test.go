@@ -0,0 +1,113 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// testdata/lbaas.cassette.json was recorded against the mock server: login, the datacenters, then
+// createPool (which inspects the LB for the new pool), inspectLB and listAllLB
+func TestCassetteReplay(t *testing.T) {
+	cassette, err := LoadCassette("testdata/lbaas.cassette.json")
+	if err != nil {
+		t.Fatalf("LoadCassette: %s", err.Error())
+	}
+	player := NewCassettePlayer(cassette)
+
+	clc, err := ClientLogin("mockuser", "any password", WithTransport(player), WithLogger(NewTextLogger(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("ClientLogin: %s", err.Error())
+	}
+	if clc.getAccountAlias() != "MOCK" {
+		t.Errorf("account alias %q, want MOCK", clc.getAccountAlias())
+	}
+
+	const lbid = "00000000000000000000000000000001"
+	pool, err := clc.createPool("WA1", lbid, &PoolDetails{IncomingPort: 443, Method: "leastconn", Persistence: "none",
+		TimeoutMS: 1000, Mode: "tcp", Nodes: []PoolNode{{TargetIP: "10.0.0.1", TargetPort: 8443}, {TargetIP: "10.0.0.2", TargetPort: 8443}}})
+	if err != nil {
+		t.Fatalf("createPool: %s", err.Error())
+	}
+	if (pool.PoolID != "00000000000000000000000000000002") || (pool.LBID != lbid) || (pool.IncomingPort != 443) ||
+		(pool.Method != "leastconn") || (pool.Mode != "tcp") || (len(pool.Nodes) != 2) {
+		t.Errorf("createPool = %+v", pool)
+	}
+
+	lb, herr := clc.inspectLB("wa1", lbid)
+	if herr != nil {
+		t.Fatalf("inspectLB: %s", herr.Error())
+	}
+	if (lb.LBID != lbid) || (lb.Name != "web") || (lb.Description != "frontends") || (lb.DataCenter != "WA1") ||
+		(lb.PublicIP != "192.0.2.2") || (lb.Status != "ready") || (len(lb.Pools) != 1) {
+		t.Fatalf("inspectLB = %+v", lb)
+	}
+	if node := lb.Pools[0].Nodes[1]; (node.TargetIP != "10.0.0.2") || (node.TargetPort != 8443) {
+		t.Errorf("second node = %+v", node)
+	}
+
+	list, err := clc.listAllLB()
+	if err != nil {
+		t.Fatalf("listAllLB: %s", err.Error())
+	}
+	want := LoadBalancerSummary{LBID: lbid, Name: "web", Description: "frontends", PublicIP: "192.0.2.2", DataCenter: "WA1"}
+	if (len(list) != 1) || (list[0] != want) {
+		t.Errorf("listAllLB = %+v, want [%+v]", list, want)
+	}
+
+	if n := player.Remaining(); n != 0 {
+		t.Errorf("%d interactions not replayed", n)
+	}
+
+	if _, herr := clc.inspectLB("WA1", lbid); herr == nil { // each interaction is served once
+		t.Errorf("inspectLB beyond the cassette succeeded")
+	}
+}
+
+// saves from several recorders at once each leave a whole cassette, and no temporary files
+func TestCassetteSaveConcurrent(t *testing.T) {
+	cassette, err := LoadCassette("testdata/lbaas.cassette.json")
+	if err != nil {
+		t.Fatalf("LoadCassette: %s", err.Error())
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.json")
+	var wg sync.WaitGroup
+	for idx := 0; idx < 8; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cassette.Save(path); err != nil {
+				t.Errorf("Save: %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := LoadCassette(path); err != nil {
+		t.Errorf("LoadCassette after the saves: %s", err.Error())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d files left in the directory, want only the cassette", len(entries))
+	}
+}
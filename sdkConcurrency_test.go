@@ -0,0 +1,93 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// a real client logged in to a MockServer on a local port.  Run with -race.
+func newMockClient(t *testing.T) (CenturyLinkClient, *MockServer) {
+	t.Helper()
+	mock := NewMockServer("TEST", "testuser", "testpassword", DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"})
+	srv, err := mock.StartTLS("")
+	if err != nil {
+		t.Fatalf("StartTLS: %s", err.Error())
+	}
+	t.Cleanup(srv.Close)
+
+	addr := srv.Listener.Addr().String()
+	clc, err := ClientLogin("testuser", "testpassword", WithServers(addr, addr), WithRateLimit(RateLimit{}, RateLimit{}),
+		WithLogger(NewTextLogger(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("ClientLogin: %s", err.Error())
+	}
+
+	return clc, mock
+}
+
+func TestParallelCallsThroughTokenRefresh(t *testing.T) {
+	clc, mock := newMockClient(t)
+
+	info, err := clc.createLB("WA1", "web", "frontends")
+	if err != nil {
+		t.Fatalf("createLB: %s", err.Error())
+	}
+	pool, err := clc.createPool("WA1", info.LBID, &PoolDetails{IncomingPort: 80, Method: "roundrobin", Mode: "tcp",
+		Nodes: []PoolNode{{TargetIP: "10.0.0.1", TargetPort: 8080}}})
+	if err != nil {
+		t.Fatalf("createPool: %s", err.Error())
+	}
+
+	mock.ExpireTokens()
+	logins := mock.Logins()
+
+	const n = 16
+	errs := make(chan error, 2*n)
+	var wg sync.WaitGroup
+	for idx := 0; idx < n; idx++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			if _, err := clc.inspectLB("WA1", info.LBID); err != nil {
+				errs <- fmt.Errorf("inspectLB: %s", err.Error())
+			}
+		}()
+
+		go func(idx int) {
+			defer wg.Done()
+			update := *pool
+			update.Nodes = []PoolNode{{TargetIP: fmt.Sprintf("10.0.0.%d", idx+1), TargetPort: 8080}}
+			if _, err := clc.updatePool("WA1", info.LBID, &update); err != nil { // a PUT, whose body must be sent again
+				errs <- fmt.Errorf("updatePool: %s", err.Error())
+			}
+		}(idx)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := mock.Logins() - logins; got != 1 {
+		t.Errorf("%d logins after the tokens expired, want exactly 1", got)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+//// clcConfig is everything a client's HTTP calls honor.  Each client has its own, so two clients in one
+//// process (e.g. different accounts, one of them replaying a cassette) don't affect each other.
+//// Set it with options at login:  ClientLogin(user, pass, WithDebug(true, false), WithRetry(...))
+
+type clcConfig struct {
+	serverAPIV2 string // hosts, the real ones unless WithServers
+	serverLB    string
+
+	transport        http.RoundTripper // nil means the network
+	logger           Logger
+	level            *slog.LevelVar // of the default logger, follows WithDebug
+	retry            RetryPolicy
+	limits           *hostLimiters  // shared by copies of the config, they count toward the same limits
+	cache            *ResponseCache // nil for no caching
+	jobs             *JobTracker    // long-running changes started through the client, see sdkJobs.go
+	debugRequests    bool           // dumps are logged at Debug level, and may be large
+	debugResponses   bool
+	closeConnections bool
+	userAgent        string // "" leaves Go's default
+}
+
+type ClientOption func(*clcConfig)
+
+// RetryPolicy applies to idempotent calls (GET, PUT, DELETE) that fail to connect or get a 5xx.
+// MaxAttempts counts the first try, so 1 means no retry.  The delay doubles after each attempt.
+// A 429 is different, the call was refused rather than failed, so any call is sent again after the
+// pause the server asks for, up to Throttled times.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	Throttled   int
+}
+
+func newClcConfig(opts ...ClientOption) *clcConfig {
+	cfg := &clcConfig{
+		serverAPIV2:      clcServer_API_V2,
+		serverLB:         clcServer_LB_BETA,
+		level:            new(slog.LevelVar),
+		retry:            RetryPolicy{MaxAttempts: 1, Throttled: 3},
+		limits:           newHostLimiters(defaultRateLimit, defaultRateLimit),
+		closeConnections: true,
+		jobs:             NewJobTracker(defaultJobOptions),
+	}
+	cfg.logger = NewTextLogger(os.Stderr, cfg.level)
+
+	cfg.apply(opts...)
+	return cfg
+}
+
+func (cfg *clcConfig) apply(opts ...ClientOption) {
+	for _, opt := range opts {
+		opt(cfg)
+	}
+}
+
+func (cfg *clcConfig) roundTripper() http.RoundTripper {
+	if cfg.transport == nil {
+		return networkTransport()
+	}
+	return cfg.transport
+}
+
+// the CLC_API_*_SERVER variables, normally unset, for pointing the CLI at a MockServer
+func envServerOptions() ClientOption {
+	return WithServers(os.Getenv("CLC_API_V2_SERVER"), os.Getenv("CLC_API_LB_SERVER"))
+}
+
+//////////////// options
+
+// WithServers points the client at other hosts, e.g. a MockServer.  "" leaves a server unchanged.
+func WithServers(apiV2, lb string) ClientOption {
+	return func(cfg *clcConfig) {
+		if apiV2 != "" {
+			cfg.serverAPIV2 = apiV2
+		}
+		if lb != "" {
+			cfg.serverLB = lb
+		}
+	}
+}
+
+// WithTransport sends calls through rt, e.g. a CassetteRecorder.  nil restores the network.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(cfg *clcConfig) {
+		cfg.transport = rt
+	}
+}
+
+// WithLogger replaces the default text logger on stderr.  nil restores it.
+func WithLogger(l Logger) ClientOption {
+	return func(cfg *clcConfig) {
+		if l == nil {
+			l = NewTextLogger(os.Stderr, cfg.level)
+		}
+		cfg.logger = l
+	}
+}
+
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(cfg *clcConfig) {
+		if policy.MaxAttempts < 1 {
+			policy.MaxAttempts = 1
+		}
+		if policy.Throttled < 0 {
+			policy.Throttled = 0
+		}
+		cfg.retry = policy
+	}
+}
+
+// WithRateLimit sets the limits for the v2 API host and the LB API host, and starts the stats over
+func WithRateLimit(apiV2, lb RateLimit) ClientOption {
+	return func(cfg *clcConfig) {
+		cfg.limits = newHostLimiters(apiV2, lb)
+	}
+}
+
+// WithCache answers GETs from c where it can, see sdkCache.go.  nil turns caching off.
+func WithCache(c *ResponseCache) ClientOption {
+	return func(cfg *clcConfig) {
+		cfg.cache = c
+	}
+}
+
+// WithJobs tracks the client's jobs in t, e.g. one tracker for a whole session of logins.  nil gives
+// the client a tracker of its own.
+func WithJobs(t *JobTracker) ClientOption {
+	return func(cfg *clcConfig) {
+		if t == nil {
+			t = NewJobTracker(defaultJobOptions)
+		}
+		cfg.jobs = t
+	}
+}
+
+// WithDebug turns request and response dumps on or off.  They are logged at Debug level, which the
+// default logger then shows; a logger from WithLogger has to be at Debug level itself.
+func WithDebug(requests, responses bool) ClientOption {
+	return func(cfg *clcConfig) {
+		cfg.debugRequests = requests
+		cfg.debugResponses = responses
+
+		if requests || responses {
+			cfg.level.Set(slog.LevelDebug)
+		} else {
+			cfg.level.Set(slog.LevelInfo)
+		}
+	}
+}
+
+func WithCloseConnections(b bool) ClientOption {
+	return func(cfg *clcConfig) {
+		cfg.closeConnections = b
+	}
+}
+
+func WithUserAgent(ua string) ClientOption {
+	return func(cfg *clcConfig) {
+		cfg.userAgent = ua
+	}
+}
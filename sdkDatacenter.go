@@ -0,0 +1,215 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//// every LB and pool call names a datacenter, and the API wants its ID in upper case ("WA1") while
+//// listings give it in lower case.  Calls normalise what they are given and check it against the
+//// account's datacenters before going out, so a typo fails with HTTP_ERROR_BADDC and a suggestion
+//// rather than an opaque 404, and never after some of a change has been made.
+
+// NormalizeDC is the form the API wants, " wa1" -> "WA1"
+func NormalizeDC(dc string) string {
+	return strings.ToUpper(strings.TrimSpace(dc))
+}
+
+// ValidateDC returns the normalised ID if it is one of known, otherwise an HTTP_ERROR_BADDC error
+// suggesting what may have been meant
+func ValidateDC(dc string, known []DataCenterName) (string, HttpError) {
+	id := NormalizeDC(dc)
+	for _, k := range known {
+		if k.DCID == id {
+			return id, nil
+		}
+	}
+
+	if id == "" {
+		return "", makeError("no datacenter given", HTTP_ERROR_BADDC, nil)
+	}
+
+	msg := fmt.Sprintf("unknown datacenter %q", dc)
+	if suggestions := suggestDCs(dc, known); len(suggestions) > 0 {
+		msg += ", did you mean " + strings.Join(suggestions, " or ") + "?"
+	} else if len(known) > 0 {
+		ids := make([]string, len(known))
+		for idx, k := range known {
+			ids[idx] = k.DCID
+		}
+		msg += ", this account has " + strings.Join(ids, ", ")
+	}
+
+	return "", makeError(msg, HTTP_ERROR_BADDC, nil)
+}
+
+// IDs one edit from dc, and those whose name mentions it ("seattle")
+func suggestDCs(dc string, known []DataCenterName) []string {
+	id := NormalizeDC(dc)
+	ret := make([]string, 0)
+	best := 1 // the closest, if any is that close
+
+	for _, k := range known {
+		d := editDistance(id, k.DCID)
+		if d > best {
+			continue
+		}
+		if d < best {
+			best = d
+			ret = ret[:0]
+		}
+		ret = append(ret, k.DCID)
+	}
+
+	if len(id) >= 3 {
+		for _, k := range known {
+			if strings.Contains(strings.ToUpper(k.Name), id) && !slices.Contains(ret, k.DCID) {
+				ret = append(ret, k.DCID)
+			}
+		}
+	}
+
+	sort.Strings(ret)
+	return ret
+}
+
+// Levenshtein, with a swap of neighbours ("AW1" for "WA1") counting as one edit
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if (i > 1) && (j > 1) && (a[i-1] == b[j-2]) && (a[i-2] == b[j-1]) {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+
+	return prev[len(b)]
+}
+
+//////////////// the account's datacenters, as a client remembers them
+
+// knownDCs is fetched on first use.  A DC that isn't there causes one refetch, in case it was added
+// since, before it is called unknown.
+type knownDCs struct {
+	mu  sync.Mutex
+	dcs []DataCenterName // nil until fetched
+}
+
+func (k *knownDCs) check(dc string, fetch func() ([]DataCenterName, error)) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	fetched := false
+	if k.dcs == nil {
+		dcs, err := fetch()
+		if err != nil {
+			return "", err
+		}
+		k.dcs = dcs
+		fetched = true
+	}
+
+	id, err := ValidateDC(dc, k.dcs)
+	if (err != nil) && !fetched && (NormalizeDC(dc) != "") {
+		dcs, errFetch := fetch()
+		if errFetch != nil {
+			return "", errFetch
+		}
+		k.dcs = dcs
+		id, err = ValidateDC(dc, k.dcs)
+	}
+
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+//////////////// what a datacenter offers
+
+// InspectDataCenter puts together the DC's name, its deployment capabilities, where it has them its
+// bare-metal ones, and whether the LB API is there
+func InspectDataCenter(clc CenturyLinkClient, dc string) (*DataCenterDetails, error) {
+	dcs, err := clc.listAllDC()
+	if err != nil {
+		return nil, err
+	}
+
+	id, errDC := ValidateDC(dc, dcs)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	caps, err := clc.deploymentCapabilities(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &DataCenterDetails{DCID: id, StorageTypes: caps.StorageTypes(), Capabilities: *caps}
+	for _, k := range dcs {
+		if k.DCID == id {
+			ret.Name = k.Name
+		}
+	}
+
+	if caps.SupportsBareMetal {
+		if ret.BareMetal, err = clc.bareMetalCapabilities(id); err != nil {
+			return nil, err
+		}
+	}
+
+	if ret.LoadBalancing, err = clc.lbServiceOffered(id); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func lbServiceError(dc string) HttpError {
+	return makeError(fmt.Sprintf("load balancing is not offered in %s, see \"DC details %s\"", dc, dc), HTTP_ERROR_NOSERVICE, nil)
+}
+
+// checkLBService fails with HTTP_ERROR_NOSERVICE if the LB API says it isn't in the DC.  If the probe
+// fails some other way, that is left for the LB call to find out.
+func checkLBService(clc CenturyLinkClient, dc string) HttpError {
+	offered, err := clc.lbServiceOffered(dc)
+	if (err != nil) || offered {
+		return nil
+	}
+
+	return lbServiceError(NormalizeDC(dc))
+}
@@ -0,0 +1,636 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+//// declarative configuration: a document describes the LBs we want, PlanConfig compares it with
+//// what the API reports, and ApplyPlan makes the calls to get from one to the other.
+////
+//// LBs are identified by datacenter + name, pools within an LB by their incoming port.  LBs that
+//// exist but are not in the document are left alone; pools on a described LB that are not in the
+//// document are deleted.
+
+type ConfigDocument struct {
+	LoadBalancers []LBConfig `json:"loadBalancers"`
+}
+
+type LBConfig struct {
+	DataCenter  string            `json:"dataCenter"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Pools       []PoolConfig      `json:"pools,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"` // informational only, e.g. the lbid and publicIP from an export
+}
+
+// fields left out take the same defaults as "pool create"
+type PoolConfig struct {
+	Port        int                 `json:"port"`
+	Method      string              `json:"method,omitempty"`
+	Persistence string              `json:"persistence,omitempty"`
+	TimeoutMS   int64               `json:"timeoutMS,omitempty"`
+	Mode        string              `json:"mode,omitempty"`
+	Health      *HealthCheckDetails `json:"health,omitempty"`
+	Nodes       []PoolNode          `json:"nodes"`                 // IPs, or server:ID and group:name references
+	Group       string              `json:"group,omitempty"`       // its active servers are nodes as well, looked up at every plan
+	TargetPort  int                 `json:"targetPort,omitempty"`  // for the group's servers, 0 for 8080
+	Annotations map[string]string   `json:"annotations,omitempty"` // informational only
+}
+
+// LoadConfigDocument reads a YAML or JSON config file and checks it for consistency
+func LoadConfigDocument(path string) (*ConfigDocument, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &ConfigDocument{}
+	err = yamlUnmarshal(data, doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	err = doc.validate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return doc, nil
+}
+
+func (doc *ConfigDocument) validate() error {
+	seen := make(map[string]bool)
+
+	for idx, lb := range doc.LoadBalancers {
+		if (lb.DataCenter == "") || (lb.Name == "") {
+			return fmt.Errorf("loadBalancers[%d]: dataCenter and name are required", idx)
+		}
+
+		key := lbConfigKey(lb.DataCenter, lb.Name)
+		if seen[key] {
+			return fmt.Errorf("load balancer %s/%s is described twice", lb.DataCenter, lb.Name)
+		}
+		seen[key] = true
+
+		ports := make(map[int]bool)
+		for _, pool := range lb.Pools {
+			if pool.Port <= 0 {
+				return fmt.Errorf("load balancer %s/%s: every pool needs a port", lb.DataCenter, lb.Name)
+			}
+			if ports[pool.Port] {
+				return fmt.Errorf("load balancer %s/%s: two pools on port %d", lb.DataCenter, lb.Name, pool.Port)
+			}
+			ports[pool.Port] = true
+		}
+	}
+
+	return nil
+}
+
+func lbConfigKey(dc, name string) string {
+	return strings.ToUpper(dc) + "/" + name
+}
+
+// the PoolDetails this config asks for, defaults filled in
+func (pc *PoolConfig) toPoolDetails() *PoolDetails {
+	pool := defaultPoolDetails()
+	pool.IncomingPort = pc.Port
+
+	if pc.Method != "" {
+		pool.Method = pc.Method
+	}
+	if pc.Persistence != "" {
+		pool.Persistence = pc.Persistence
+	}
+	if pc.TimeoutMS != 0 {
+		pool.TimeoutMS = pc.TimeoutMS
+	}
+	if pc.Mode != "" {
+		pool.Mode = pc.Mode
+	}
+
+	pool.Health = pc.Health
+	pool.Nodes = append([]PoolNode{}, pc.Nodes...)
+	return &pool
+}
+
+// pools with a group, or server: and group: nodes, come back with those looked up (see
+// ResolvePoolNodes), the others as they are
+func resolveConfigPools(clc CenturyLinkClient, dc string, pools []PoolConfig) ([]PoolConfig, error) {
+	ret := make([]PoolConfig, len(pools))
+
+	for idx, pc := range pools {
+		ret[idx] = pc
+
+		nodes := append([]PoolNode{}, pc.Nodes...)
+		if pc.Group != "" {
+			port := pc.TargetPort
+			if port == 0 {
+				port = defaultTargetPort
+			}
+			nodes = append(nodes, PoolNode{TargetIP: NODE_REF_GROUP + pc.Group, TargetPort: port})
+		}
+
+		hasRefs := false
+		for _, node := range nodes {
+			hasRefs = hasRefs || isNodeRef(node.TargetIP)
+		}
+		if !hasRefs {
+			continue
+		}
+
+		pool := &PoolDetails{Nodes: nodes}
+		_, err := ResolvePoolNodes(clc, dc, pool)
+		if err != nil {
+			return nil, fmt.Errorf("pool on port %d: %s", pc.Port, err.Error())
+		}
+
+		ret[idx].Nodes = pool.Nodes
+		ret[idx].Group = ""
+		ret[idx].TargetPort = 0
+	}
+
+	return ret, nil
+}
+
+//////////////// export
+
+// ExportLB describes a live LB in config document form.  With annotations, the server-side fields
+// (lbid, publicIP, status, poolID) are kept as annotations, otherwise they are left out.
+func ExportLB(clc CenturyLinkClient, dc, lbid string, annotations bool) (*LBConfig, error) {
+	lb, err := clc.inspectLB(dc, lbid)
+	if err != nil {
+		return nil, err
+	}
+
+	return lbToConfig(lb, dc, annotations), nil
+}
+
+// ExportAll describes every LB in the account
+func ExportAll(clc CenturyLinkClient, annotations bool) (*ConfigDocument, error) {
+	summaries, err := clc.listAllLB()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &ConfigDocument{LoadBalancers: make([]LBConfig, 0, len(summaries))}
+	for _, r := range InspectAll(clc, summaries, InspectOptions{}) {
+		if r.Err != nil {
+			return nil, fmt.Errorf("export %s/%s: %s", r.Summary.DataCenter, r.Summary.LBID, r.Err.Error())
+		}
+
+		doc.LoadBalancers = append(doc.LoadBalancers, *lbToConfig(r.Details, r.Summary.DataCenter, annotations))
+	}
+
+	return doc, nil
+}
+
+func lbToConfig(lb *LoadBalancerDetails, dc string, annotations bool) *LBConfig {
+	if lb.DataCenter != "" {
+		dc = lb.DataCenter
+	}
+
+	lbcfg := &LBConfig{
+		DataCenter:  strings.ToUpper(dc),
+		Name:        lb.Name,
+		Description: lb.Description,
+		Pools:       make([]PoolConfig, 0, len(lb.Pools)),
+	}
+
+	if annotations {
+		lbcfg.Annotations = map[string]string{
+			"lbid":     lb.LBID,
+			"publicIP": lb.PublicIP,
+			"status":   lb.Status,
+		}
+	}
+
+	for _, pool := range lb.Pools {
+		pc := PoolConfig{
+			Port:        pool.IncomingPort,
+			Method:      pool.Method,
+			Persistence: pool.Persistence,
+			TimeoutMS:   pool.TimeoutMS,
+			Mode:        pool.Mode,
+			Health:      pool.Health,
+			Nodes:       append([]PoolNode{}, pool.Nodes...),
+		}
+
+		if annotations {
+			pc.Annotations = map[string]string{"poolID": pool.PoolID}
+		}
+
+		lbcfg.Pools = append(lbcfg.Pools, pc)
+	}
+
+	sort.Slice(lbcfg.Pools, func(i, j int) bool { return lbcfg.Pools[i].Port < lbcfg.Pools[j].Port }) // stable diffs in git
+
+	return lbcfg
+}
+
+// WriteConfigDocument saves a document as JSON if the file name ends in .json, as YAML otherwise
+func WriteConfigDocument(path string, doc *ConfigDocument) error {
+	var data []byte
+	var err error
+
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yamlMarshal(doc)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+//////////////// plan
+
+const (
+	PLAN_CREATE_POOL = "create-pool"
+	PLAN_UPDATE_POOL = "update-pool"
+	PLAN_DELETE_POOL = "delete-pool"
+)
+
+type PlanStep struct {
+	Action  string       `json:"action"`
+	PoolID  string       `json:"poolID,omitempty"` // "" for create-pool
+	Port    int          `json:"port"`
+	Pool    *PoolDetails `json:"pool,omitempty"`    // desired state, for create and update
+	Changes []string     `json:"changes,omitempty"` // update-pool: what differs, "field: live -> desired"
+}
+
+type LBPlan struct {
+	DataCenter  string     `json:"dataCenter"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	LBID        string     `json:"lbid,omitempty"` // "" until the LB exists
+	CreateLB    bool       `json:"createLB"`
+	Steps       []PlanStep `json:"steps"`    // in the order ApplyPlan runs them: deletes, updates, creates
+	Warnings    []string   `json:"warnings"` // differences that no API call can fix
+}
+
+type ConfigPlan struct {
+	LoadBalancers []LBPlan `json:"loadBalancers"`
+}
+
+// HasChanges is false when the live state already matches the document
+func (plan *ConfigPlan) HasChanges() bool {
+	for _, lb := range plan.LoadBalancers {
+		if lb.CreateLB || (len(lb.Steps) > 0) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PlanConfig compares the document with the live LBs and works out the calls needed.  Every datacenter
+// in the document is checked first, so a typo stops the plan rather than an apply halfway through.
+func PlanConfig(clc CenturyLinkClient, doc *ConfigDocument) (*ConfigPlan, error) {
+	dcs, err := clc.listAllDC()
+	if err != nil {
+		return nil, err
+	}
+
+	dcIDs := make([]string, len(doc.LoadBalancers))
+	for idx, lbcfg := range doc.LoadBalancers {
+		dcIDs[idx], err = ValidateDC(lbcfg.DataCenter, dcs)
+		if err != nil {
+			return nil, fmt.Errorf("load balancer %s: %s", lbcfg.Name, err.Error())
+		}
+	}
+
+	live, err := clc.listAllLB()
+	if err != nil {
+		return nil, err
+	}
+
+	liveByKey, err := indexLiveLBs(live, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ConfigPlan{LoadBalancers: make([]LBPlan, 0, len(doc.LoadBalancers))}
+
+	for idx, lbcfg := range doc.LoadBalancers {
+		lbplan := LBPlan{
+			DataCenter:  dcIDs[idx],
+			Name:        lbcfg.Name,
+			Description: lbcfg.Description,
+			Steps:       make([]PlanStep, 0),
+			Warnings:    make([]string, 0),
+		}
+
+		pools, err := resolveConfigPools(clc, lbplan.DataCenter, lbcfg.Pools)
+		if err != nil {
+			return nil, fmt.Errorf("load balancer %s/%s: %s", lbplan.DataCenter, lbcfg.Name, err.Error())
+		}
+
+		summary := liveByKey[lbConfigKey(lbcfg.DataCenter, lbcfg.Name)]
+		if summary == nil {
+			lbplan.CreateLB = true
+			for _, pc := range pools {
+				lbplan.Steps = append(lbplan.Steps, PlanStep{Action: PLAN_CREATE_POOL, Port: pc.Port, Pool: pc.toPoolDetails()})
+			}
+
+			plan.LoadBalancers = append(plan.LoadBalancers, lbplan)
+			continue
+		}
+
+		lbplan.LBID = summary.LBID
+
+		details, err := clc.inspectLB(lbplan.DataCenter, summary.LBID)
+		if err != nil {
+			return nil, err
+		}
+
+		if details.Description != lbcfg.Description {
+			lbplan.Warnings = append(lbplan.Warnings, fmt.Sprintf("description is %q, document says %q (cannot be changed through the API)",
+				details.Description, lbcfg.Description))
+		}
+
+		lbplan.Steps = planPools(pools, details.Pools)
+		plan.LoadBalancers = append(plan.LoadBalancers, lbplan)
+	}
+
+	return plan, nil
+}
+
+// live LBs by datacenter + name, which must be unambiguous for the LBs the document names.  Duplicates
+// it doesn't name are none of its business.
+func indexLiveLBs(live []LoadBalancerSummary, doc *ConfigDocument) (map[string]*LoadBalancerSummary, error) {
+	named := make(map[string]bool)
+	for _, lbcfg := range doc.LoadBalancers {
+		named[lbConfigKey(lbcfg.DataCenter, lbcfg.Name)] = true
+	}
+
+	liveByKey := make(map[string]*LoadBalancerSummary)
+	for idx := range live {
+		key := lbConfigKey(live[idx].DataCenter, live[idx].Name)
+		if !named[key] {
+			continue
+		}
+		if liveByKey[key] != nil {
+			return nil, fmt.Errorf("more than one load balancer is named %s in %s, cannot tell which is meant",
+				live[idx].Name, live[idx].DataCenter)
+		}
+		liveByKey[key] = &live[idx]
+	}
+
+	return liveByKey, nil
+}
+
+// deletes first, so that a port freed by one pool can be taken by another
+func planPools(desired []PoolConfig, live []PoolDetails) []PlanStep {
+	liveByPort := make(map[int]*PoolDetails)
+	for idx := range live {
+		liveByPort[live[idx].IncomingPort] = &live[idx]
+	}
+
+	wantPorts := make(map[int]bool)
+	for _, pc := range desired {
+		wantPorts[pc.Port] = true
+	}
+
+	deletes := make([]PlanStep, 0)
+	updates := make([]PlanStep, 0)
+	creates := make([]PlanStep, 0)
+
+	for _, livePool := range live {
+		if !wantPorts[livePool.IncomingPort] {
+			deletes = append(deletes, PlanStep{Action: PLAN_DELETE_POOL, PoolID: livePool.PoolID, Port: livePool.IncomingPort})
+		}
+	}
+
+	for _, pc := range desired {
+		want := pc.toPoolDetails()
+		livePool := liveByPort[pc.Port]
+
+		if livePool == nil {
+			creates = append(creates, PlanStep{Action: PLAN_CREATE_POOL, Port: pc.Port, Pool: want})
+			continue
+		}
+
+		changes := diffPool(want, livePool)
+		if len(changes) > 0 {
+			want.PoolID = livePool.PoolID
+			want.LBID = livePool.LBID
+			updates = append(updates, PlanStep{Action: PLAN_UPDATE_POOL, PoolID: livePool.PoolID, Port: pc.Port, Pool: want, Changes: changes})
+		}
+	}
+
+	return append(append(deletes, updates...), creates...)
+}
+
+type poolFieldDiff struct {
+	Field   string
+	Live    string
+	Desired string
+}
+
+// the pool settings other than port and nodes that differ
+func comparePoolSettings(desired, live *PoolDetails) []poolFieldDiff {
+	diffs := make([]poolFieldDiff, 0)
+
+	if desired.Method != live.Method {
+		diffs = append(diffs, poolFieldDiff{"method", live.Method, desired.Method})
+	}
+	if desired.Persistence != live.Persistence {
+		diffs = append(diffs, poolFieldDiff{"persistence", live.Persistence, desired.Persistence})
+	}
+	if desired.TimeoutMS != live.TimeoutMS {
+		diffs = append(diffs, poolFieldDiff{"timeout", fmt.Sprint(live.TimeoutMS), fmt.Sprint(desired.TimeoutMS)})
+	}
+	if desired.Mode != live.Mode {
+		diffs = append(diffs, poolFieldDiff{"mode", live.Mode, desired.Mode})
+	}
+	if !sameHealthCheck(desired.Health, live.Health) {
+		diffs = append(diffs, poolFieldDiff{"health", describeHealthCheck(live.Health), describeHealthCheck(desired.Health)})
+	}
+
+	return diffs
+}
+
+// diffPool lists what differs between two pools, as "field: live -> desired".  Node order does not matter.
+func diffPool(desired, live *PoolDetails) []string {
+	changes := make([]string, 0)
+
+	for _, d := range comparePoolSettings(desired, live) {
+		changes = append(changes, fmt.Sprintf("%s: %s -> %s", d.Field, d.Live, d.Desired))
+	}
+
+	added, removed := diffNodes(desired.Nodes, live.Nodes)
+	if (len(added) > 0) || (len(removed) > 0) {
+		parts := make([]string, 0, len(added)+len(removed))
+		for _, node := range added {
+			parts = append(parts, "+"+nodeKey(node))
+		}
+		for _, node := range removed {
+			parts = append(parts, "-"+nodeKey(node))
+		}
+		changes = append(changes, "nodes: "+strings.Join(parts, " "))
+	}
+
+	return changes
+}
+
+func sameHealthCheck(a, b *HealthCheckDetails) bool {
+	if (a == nil) || (b == nil) {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func describeHealthCheck(h *HealthCheckDetails) string {
+	if h == nil {
+		return "none"
+	}
+
+	return fmt.Sprintf("{unhealthy:%d healthy:%d interval:%d targetPort:%d mode:%s}", h.Unhealthy, h.Healthy, h.Interval, h.TargetPort, h.Mode)
+}
+
+func nodeKey(node PoolNode) string {
+	return fmt.Sprintf("%s:%d", node.TargetIP, node.TargetPort)
+}
+
+func nodeListString(nodes []PoolNode) string {
+	parts := make([]string, len(nodes))
+	for idx, node := range nodes {
+		parts[idx] = nodeKey(node)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// nodes in desired but not live, and in live but not desired, each sorted
+func diffNodes(desired, live []PoolNode) (added []PoolNode, removed []PoolNode) {
+	liveSet := make(map[string]bool)
+	for _, node := range live {
+		liveSet[nodeKey(node)] = true
+	}
+
+	wantSet := make(map[string]bool)
+	for _, node := range desired {
+		wantSet[nodeKey(node)] = true
+		if !liveSet[nodeKey(node)] {
+			added = append(added, node)
+		}
+	}
+
+	for _, node := range live {
+		if !wantSet[nodeKey(node)] {
+			removed = append(removed, node)
+		}
+	}
+
+	sort.Slice(added, func(i, j int) bool { return nodeKey(added[i]) < nodeKey(added[j]) })
+	sort.Slice(removed, func(i, j int) bool { return nodeKey(removed[i]) < nodeKey(removed[j]) })
+	return added, removed
+}
+
+//////////////// apply
+
+var lbReadyTimeout = 10 * time.Minute
+
+// ApplyPlan makes the calls in the plan, LB by LB, waiting for each one's request.  progress (may be nil) hears about each call
+// before it is made.  Stops at the first failure, the plan can simply be recomputed and applied again.
+func ApplyPlan(clc CenturyLinkClient, plan *ConfigPlan, progress func(lb *LBPlan, step *PlanStep)) error {
+	for idx := range plan.LoadBalancers {
+		lb := &plan.LoadBalancers[idx]
+
+		if lb.CreateLB {
+			if progress != nil {
+				progress(lb, nil)
+			}
+
+			info, err := clc.createLB(lb.DataCenter, lb.Name, lb.Description)
+			if err != nil {
+				return fmt.Errorf("create load balancer %s/%s: %s", lb.DataCenter, lb.Name, err.Error())
+			}
+
+			lb.LBID = info.LBID
+			lb.CreateLB = false
+
+			err = clc.jobTracker().Wait(TrackLB(clc, lb.DataCenter, lb.LBID, info.Status, lbReadyTimeout))
+			if err != nil {
+				return err
+			}
+		}
+
+		for idxStep := range lb.Steps {
+			step := &lb.Steps[idxStep]
+			if progress != nil {
+				progress(lb, step)
+			}
+
+			var err error
+			var request *LBRequest // each change is waited for, so the next step sees it made
+			if step.Action == PLAN_DELETE_POOL {
+				request, err = clc.deletePool(lb.DataCenter, lb.LBID, step.PoolID)
+
+			} else if step.Action == PLAN_UPDATE_POOL {
+				step.Pool.LBID = lb.LBID
+				var updated *PoolDetails
+				updated, err = clc.updatePool(lb.DataCenter, lb.LBID, step.Pool)
+				if err == nil {
+					request = updated.Request
+				}
+
+			} else if step.Action == PLAN_CREATE_POOL {
+				step.Pool.LBID = lb.LBID
+				var created *PoolDetails
+				created, err = clc.createPool(lb.DataCenter, lb.LBID, step.Pool)
+				if err == nil {
+					step.PoolID = created.PoolID
+					request = created.Request
+				}
+			}
+
+			if (err == nil) && (request != nil) {
+				err = WaitLBRequest(clc, request, lbReadyTimeout)
+			}
+
+			if err != nil {
+				return fmt.Errorf("%s on port %d of %s/%s: %s", step.Action, step.Port, lb.DataCenter, lb.Name, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// LB statuses that mean "still being built".  The API doesn't document the list.
+func lbStatusPending(status string) bool {
+	switch strings.ToLower(status) {
+	case "pending", "provisioning", "creating", "building", "requested", "accepted", "queued", "in_progress":
+		return true
+	}
+
+	return false
+}
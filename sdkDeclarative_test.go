@@ -0,0 +1,80 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// two LBs with one name only matter to a document that names them
+func TestDuplicateLBNamesOnlyForTheDocument(t *testing.T) {
+	f := NewFakeClient("TEST", DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"})
+	for _, name := range []string{"web", "scratch", "scratch"} {
+		if _, err := f.createLB("WA1", name, ""); err != nil {
+			t.Fatalf("createLB: %s", err.Error())
+		}
+	}
+
+	doc := &ConfigDocument{LoadBalancers: []LBConfig{{DataCenter: "wa1", Name: "web"}}}
+	if _, err := PlanConfig(f, doc); err != nil {
+		t.Errorf("PlanConfig with an unrelated duplicate: %s", err.Error())
+	}
+	if _, err := DetectDrift(f, doc); err != nil {
+		t.Errorf("DetectDrift with an unrelated duplicate: %s", err.Error())
+	}
+
+	doc.LoadBalancers = append(doc.LoadBalancers, LBConfig{DataCenter: "WA1", Name: "scratch"})
+	if _, err := PlanConfig(f, doc); (err == nil) || !strings.Contains(err.Error(), "more than one load balancer is named scratch") {
+		t.Errorf("PlanConfig naming a duplicate = %v", err)
+	}
+	if _, err := DetectDrift(f, doc); err == nil {
+		t.Errorf("DetectDrift naming a duplicate succeeded")
+	}
+}
+
+// with --interval a failed check is reported and the next one still runs; the command fails at the end
+func TestDriftIntervalOutlivesAFailure(t *testing.T) {
+	app, f := newFakeApp(t)
+	config := filepath.Join(t.TempDir(), "lb.json")
+	if err := os.WriteFile(config, []byte(`{"loadBalancers": [{"dataCenter": "WA1", "name": "web"}]}`), 0600); err != nil {
+		t.Fatalf("WriteFile: %s", err.Error())
+	}
+
+	f.FailNext("listAllLB", 500)
+	processInputLine(app, "drift --interval 1ms --count 3 "+config)
+	if n := f.CallCount("listAllLB"); n != 3 {
+		t.Errorf("%d drift checks ran, want 3", n)
+	}
+	if (app.lastErr == nil) || !strings.Contains(app.lastErr.Error(), "1 of 3 drift checks failed") {
+		t.Errorf("lastErr = %v", app.lastErr)
+	}
+	if app.vars["drift.drifted"] != "true" { // the LB is missing
+		t.Errorf("the last check was not bound, vars=%v", app.vars)
+	}
+
+	f.FailNext("listAllLB", 500)
+	processInputLine(app, "drift "+config) // a single check stops at its failure
+	if n := f.CallCount("listAllLB"); n != 4 {
+		t.Errorf("CallCount(listAllLB) = %d after a single check, want 4", n)
+	}
+	if app.lastErr == nil {
+		t.Errorf("a single failed check succeeded")
+	}
+}
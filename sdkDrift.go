@@ -0,0 +1,259 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//// drift: how the live LBs differ from a config document.  Where PlanConfig answers "what calls
+//// would fix this", DetectDrift answers "what did somebody change", item by item.
+
+const ( // DriftItem.Kind.  "added" and "removed" are from the point of view of the live state
+	DRIFT_LB_MISSING      = "lb-missing"      // described, but no such LB
+	DRIFT_POOL_MISSING    = "pool-missing"    // described, but no pool on that port
+	DRIFT_POOL_EXTRA      = "pool-extra"      // pool exists that the document doesn't describe
+	DRIFT_PORT_CHANGED    = "port-changed"    // a pool with the described nodes is listening on another port
+	DRIFT_NODE_ADDED      = "node-added"      // node in the live pool only
+	DRIFT_NODE_REMOVED    = "node-removed"    // node in the document only
+	DRIFT_NODE_CHANGED    = "node-changed"    // same node IP, different target port
+	DRIFT_METHOD_CHANGED  = "method-changed"  // loadBalancingMethod
+	DRIFT_HEALTH_CHANGED  = "health-changed"  // health check added, removed or changed
+	DRIFT_SETTING_CHANGED = "setting-changed" // persistence, timeout, mode or description, see Field
+)
+
+type DriftItem struct {
+	Kind       string `json:"kind"`
+	DataCenter string `json:"dataCenter"`
+	LBName     string `json:"lbName"`
+	LBID       string `json:"lbid,omitempty"`
+	Port       int    `json:"port,omitempty"` // the described port, 0 for LB-level items
+	PoolID     string `json:"poolID,omitempty"`
+	Field      string `json:"field,omitempty"`
+	Desired    string `json:"desired,omitempty"`
+	Live       string `json:"live,omitempty"`
+}
+
+type DriftReport struct {
+	CheckedAt string      `json:"checkedAt"` // RFC3339
+	Drifted   bool        `json:"drifted"`
+	Items     []DriftItem `json:"items"`
+}
+
+// DetectDrift compares every LB in the document with its live counterpart
+func DetectDrift(clc CenturyLinkClient, doc *ConfigDocument) (*DriftReport, error) {
+	live, err := clc.listAllLB()
+	if err != nil {
+		return nil, err
+	}
+
+	liveByKey, err := indexLiveLBs(live, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+		Items:     make([]DriftItem, 0),
+	}
+
+	for _, lbcfg := range doc.LoadBalancers {
+		base := DriftItem{DataCenter: strings.ToUpper(lbcfg.DataCenter), LBName: lbcfg.Name}
+
+		summary := liveByKey[lbConfigKey(lbcfg.DataCenter, lbcfg.Name)]
+		if summary == nil {
+			item := base
+			item.Kind = DRIFT_LB_MISSING
+			report.Items = append(report.Items, item)
+			continue
+		}
+
+		base.LBID = summary.LBID
+
+		pools, err := resolveConfigPools(clc, base.DataCenter, lbcfg.Pools)
+		if err != nil {
+			return nil, fmt.Errorf("load balancer %s/%s: %s", base.DataCenter, lbcfg.Name, err.Error())
+		}
+
+		details, err := clc.inspectLB(base.DataCenter, summary.LBID)
+		if err != nil {
+			return nil, err
+		}
+
+		if details.Description != lbcfg.Description {
+			item := base
+			item.Kind = DRIFT_SETTING_CHANGED
+			item.Field = "description"
+			item.Desired = lbcfg.Description
+			item.Live = details.Description
+			report.Items = append(report.Items, item)
+		}
+
+		report.Items = append(report.Items, driftPools(base, pools, details.Pools)...)
+	}
+
+	report.Drifted = (len(report.Items) > 0)
+	return report, nil
+}
+
+func driftPools(base DriftItem, desired []PoolConfig, live []PoolDetails) []DriftItem {
+	items := make([]DriftItem, 0)
+
+	liveByPort := make(map[int]*PoolDetails)
+	for idx := range live {
+		liveByPort[live[idx].IncomingPort] = &live[idx]
+	}
+
+	matched := make(map[string]bool) // live PoolIDs accounted for
+
+	for _, pc := range desired {
+		if livePool := liveByPort[pc.Port]; livePool != nil {
+			matched[livePool.PoolID] = true
+		}
+	}
+
+	extras := make([]*PoolDetails, 0)
+	for idx := range live {
+		if !matched[live[idx].PoolID] {
+			extras = append(extras, &live[idx])
+		}
+	}
+
+	for _, pc := range desired {
+		want := pc.toPoolDetails()
+		livePool := liveByPort[pc.Port]
+
+		if livePool == nil { // moved to another port, if an unclaimed pool has the same node IPs
+			for idx, extra := range extras {
+				if (extra != nil) && sameNodeIPs(want.Nodes, extra.Nodes) {
+					livePool = extra
+					extras[idx] = nil
+
+					item := base
+					item.Kind = DRIFT_PORT_CHANGED
+					item.Port = pc.Port
+					item.PoolID = extra.PoolID
+					item.Field = "port"
+					item.Desired = strconv.Itoa(pc.Port)
+					item.Live = strconv.Itoa(extra.IncomingPort)
+					items = append(items, item)
+					break
+				}
+			}
+		}
+
+		if livePool == nil {
+			item := base
+			item.Kind = DRIFT_POOL_MISSING
+			item.Port = pc.Port
+			items = append(items, item)
+			continue
+		}
+
+		items = append(items, driftPool(base, want, livePool)...)
+	}
+
+	for _, extra := range extras {
+		if extra != nil {
+			item := base
+			item.Kind = DRIFT_POOL_EXTRA
+			item.Port = extra.IncomingPort
+			item.PoolID = extra.PoolID
+			item.Live = nodeListString(extra.Nodes)
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func driftPool(base DriftItem, desired, live *PoolDetails) []DriftItem {
+	items := make([]DriftItem, 0)
+	base.Port = desired.IncomingPort
+	base.PoolID = live.PoolID
+
+	for _, d := range comparePoolSettings(desired, live) {
+		item := base
+		item.Kind = DRIFT_SETTING_CHANGED
+		if d.Field == "method" {
+			item.Kind = DRIFT_METHOD_CHANGED
+		} else if d.Field == "health" {
+			item.Kind = DRIFT_HEALTH_CHANGED
+		}
+		item.Field = d.Field
+		item.Desired = d.Desired
+		item.Live = d.Live
+		items = append(items, item)
+	}
+
+	added, removed := diffNodes(live.Nodes, desired.Nodes) // added = live only
+
+	// the same IP on both sides is one node whose target port changed
+	removedByIP := make(map[string]int)
+	for idx, node := range removed {
+		if _, dup := removedByIP[node.TargetIP]; !dup {
+			removedByIP[node.TargetIP] = idx
+		}
+	}
+	pairedRemoved := make(map[int]bool)
+
+	for _, node := range added {
+		item := base
+		item.Field = "nodes"
+		item.Live = nodeKey(node)
+
+		if idx, found := removedByIP[node.TargetIP]; found && !pairedRemoved[idx] {
+			pairedRemoved[idx] = true
+			item.Kind = DRIFT_NODE_CHANGED
+			item.Desired = nodeKey(removed[idx])
+		} else {
+			item.Kind = DRIFT_NODE_ADDED
+		}
+		items = append(items, item)
+	}
+
+	for idx, node := range removed {
+		if !pairedRemoved[idx] {
+			item := base
+			item.Kind = DRIFT_NODE_REMOVED
+			item.Field = "nodes"
+			item.Desired = nodeKey(node)
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func sameNodeIPs(a, b []PoolNode) bool {
+	ipsA := make([]string, len(a))
+	for idx, node := range a {
+		ipsA[idx] = node.TargetIP
+	}
+	ipsB := make([]string, len(b))
+	for idx, node := range b {
+		ipsB[idx] = node.TargetIP
+	}
+
+	sort.Strings(ipsA)
+	sort.Strings(ipsB)
+	return (len(ipsA) > 0) && (strings.Join(ipsA, " ") == strings.Join(ipsB, " "))
+}
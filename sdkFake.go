@@ -0,0 +1,787 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//// FakeClient is an in-memory CenturyLinkClient, for testing code built on the interface without
+//// the cloud.  It behaves like the LBaaS API as far as we know it: generated IDs, 404s for things
+//// that don't exist, new LBs that sit in a provisioning state for a while.  Failures and latency
+//// can be injected.  Safe for concurrent use.
+
+const (
+	FAKE_STATUS_PROVISIONING = "provisioning"
+	FAKE_STATUS_READY        = "ready"
+)
+
+type FakeClient struct {
+	mu     sync.Mutex
+	saveMu sync.Mutex // one SaveState at a time, so the file ends up with the latest state
+
+	username string
+	account  string
+	loggedIn bool
+
+	dcs    []DataCenterName
+	lbs    map[string]*fakeLB // by LBID
+	lbList []string           // LBIDs in creation order, so listings are stable
+	nextID int
+
+	groups     map[string]*fakeGroup     // by ID, see sdkFakeServers.go
+	groupList  []string                  // creation order
+	groupSeq   int                       // group IDs are numbered apart from LB IDs
+	servers    map[string]*ServerDetails // by ID
+	serverList []string
+	publicIPs  map[string]*PublicIPDetails // by public IP
+	publicSeq  int
+
+	serverUUIDs map[string]string // server ID by the UUID createServer gave it, see sdkFakeProvision.go
+
+	firewalls    map[string]*FirewallPolicy // by PolicyID, see sdkFakeFirewall.go
+	firewallList []string                   // creation order
+	firewallSeq  int
+
+	networks    map[string]*NetworkDetails // by NetworkID, see sdkFakeNetworks.go
+	networkList []string                   // creation order
+	networkSeq  int
+
+	operations     map[string]*fakeOperation // by ID
+	operationSeq   int
+	operationPolls int // new operations, and LB requests, report executing for this many status calls
+
+	lbRequests map[string]*fakeLBRequest // pool changes, by Href
+
+	noLBService map[string]bool // DCs without load balancing, see SetLBService
+
+	latency           time.Duration
+	provisioningPolls int              // new LBs report provisioning for this many inspects/listings
+	failures          map[string][]int // method name (or "*") -> HTTP codes for its next calls
+	calls             map[string]int   // method name -> number of calls
+
+	jobs *JobTracker // the only option that applies, see configure
+}
+
+type fakeLB struct {
+	details      LoadBalancerDetails
+	pendingPolls int // countdown to FAKE_STATUS_READY
+}
+
+// NewFakeClient starts out logged in to the account, with the given datacenters and no LBs.  Each DC
+// has its root group, but no servers.
+func NewFakeClient(account string, dcs ...DataCenterName) *FakeClient {
+	f := &FakeClient{
+		username:    "fakeuser",
+		account:     account,
+		loggedIn:    true,
+		dcs:         append([]DataCenterName{}, dcs...),
+		lbs:         make(map[string]*fakeLB),
+		groups:      make(map[string]*fakeGroup),
+		servers:     make(map[string]*ServerDetails),
+		publicIPs:   make(map[string]*PublicIPDetails),
+		serverUUIDs: make(map[string]string),
+		firewalls:   make(map[string]*FirewallPolicy),
+		networks:    make(map[string]*NetworkDetails),
+		operations:  make(map[string]*fakeOperation),
+		lbRequests:  make(map[string]*fakeLBRequest),
+		failures:    make(map[string][]int),
+		calls:       make(map[string]int),
+		noLBService: make(map[string]bool),
+		jobs:        NewJobTracker(defaultJobOptions),
+	}
+
+	for _, dc := range f.dcs {
+		f.addGroup(&fakeGroup{Name: dc.DCID + " Hardware", DataCenter: dc.DCID, Type: "default"})
+	}
+
+	return f
+}
+
+//// failure injection and inspection
+
+// SetLatency makes every call take at least this long
+func (f *FakeClient) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// SetProvisioningPolls makes LBs created from now on report FAKE_STATUS_PROVISIONING for n
+// inspectLB/listAllLB calls, and refuse pools until then
+func (f *FakeClient) SetProvisioningPolls(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.provisioningPolls = n
+}
+
+// SetLBService says whether the DC offers load balancing.  Where it doesn't, lbServiceOffered says so
+// (the mock answers 404 for the DC's LBs) and createLB fails with HTTP_ERROR_NOSERVICE.
+func (f *FakeClient) SetLBService(dc string, offered bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if offered {
+		delete(f.noLBService, NormalizeDC(dc))
+	} else {
+		f.noLBService[NormalizeDC(dc)] = true
+	}
+}
+
+// FailNext makes the next calls of a method (e.g. "createPool", or "*" for any) fail with these
+// HTTP codes, one call per code
+func (f *FakeClient) FailNext(method string, codes ...int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[method] = append(f.failures[method], codes...)
+}
+
+// CallCount is how many times a method has been called, failed calls included
+func (f *FakeClient) CallCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// every method starts here: counts the call, sleeps, and returns an injected failure if one is due.
+// Returns with f.mu held, unless there is an error.
+func (f *FakeClient) begin(method string) HttpError {
+	f.mu.Lock()
+	f.calls[method]++
+	latency := f.latency
+	f.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	f.mu.Lock()
+
+	for _, key := range []string{method, "*"} {
+		if codes := f.failures[key]; len(codes) > 0 {
+			f.failures[key] = codes[1:]
+			f.mu.Unlock()
+			return makeError("HTTP call failed", codes[0], nil)
+		}
+	}
+
+	if !f.loggedIn {
+		f.mu.Unlock()
+		return makeError("username and/or password not provided", HTTP_ERROR_NOCREDS, nil)
+	}
+
+	return nil
+}
+
+func (f *FakeClient) newID() string { // 32 hex digits like the real ones, but predictable
+	f.nextID++
+	return fmt.Sprintf("%032x", f.nextID)
+}
+
+// as clcImpl checks it.  Called with f.mu held.
+func (f *FakeClient) checkDC(dc string) (string, HttpError) {
+	return ValidateDC(dc, f.dcs)
+}
+
+// the LB, if it exists in that DC.  Called with f.mu held.
+func (f *FakeClient) findLB(dc, lbid string) *fakeLB {
+	lb := f.lbs[lbid]
+	if (lb == nil) || (lb.details.DataCenter != dc) {
+		return nil
+	}
+
+	return lb
+}
+
+func (lb *fakeLB) poll() {
+	if lb.pendingPolls > 0 {
+		lb.pendingPolls--
+		if lb.pendingPolls == 0 {
+			lb.details.Status = FAKE_STATUS_READY
+		}
+	}
+}
+
+func notFound() HttpError {
+	return makeError("HTTP call failed", 404, nil)
+}
+
+func copyPool(src *PoolDetails) *PoolDetails {
+	pool := *src
+	pool.Nodes = append([]PoolNode{}, src.Nodes...)
+	if src.Health != nil {
+		health := *src.Health
+		pool.Health = &health
+	}
+
+	return &pool
+}
+
+func copyLB(src *LoadBalancerDetails) *LoadBalancerDetails {
+	lb := *src
+	lb.Pools = make([]PoolDetails, len(src.Pools))
+	for idx := range src.Pools {
+		lb.Pools[idx] = *copyPool(&src.Pools[idx])
+	}
+
+	return &lb
+}
+
+//// state files, so a fake (and the mock server built on it) can outlive its process
+
+type fakeState struct {
+	NextID        int                   `json:"nextID"`
+	LoadBalancers []LoadBalancerDetails `json:"loadBalancers"` // in creation order
+	GroupSeq      int                   `json:"groupSeq"`
+	Groups        []fakeGroup           `json:"groups,omitempty"` // absent from older files
+	Servers       []ServerDetails       `json:"servers,omitempty"`
+	ServerUUIDs   map[string]string     `json:"serverUUIDs,omitempty"`
+	PublicIPs     []PublicIPDetails     `json:"publicIPs,omitempty"`
+	PublicSeq     int                   `json:"publicSeq"`
+	Firewalls     []FirewallPolicy      `json:"firewalls,omitempty"`
+	FirewallSeq   int                   `json:"firewallSeq"`
+	Networks      []NetworkDetails      `json:"networks"` // absent from older files
+	NetworkSeq    int                   `json:"networkSeq"`
+}
+
+// SaveState writes every LB and pool to a JSON file.  Provisioning countdowns are not kept.
+func (f *FakeClient) SaveState(path string) error {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+
+	f.mu.Lock()
+	state := fakeState{NextID: f.nextID, LoadBalancers: make([]LoadBalancerDetails, 0, len(f.lbList))}
+	for _, lbid := range f.lbList {
+		state.LoadBalancers = append(state.LoadBalancers, *copyLB(&f.lbs[lbid].details))
+	}
+	state.GroupSeq = f.groupSeq
+	for _, id := range f.groupList {
+		state.Groups = append(state.Groups, *f.groups[id])
+	}
+	for _, id := range f.serverList {
+		state.Servers = append(state.Servers, *copyServer(f.servers[id]))
+		for _, publicIP := range f.servers[id].PublicIPs {
+			state.PublicIPs = append(state.PublicIPs, *copyPublicIP(f.publicIPs[publicIP]))
+		}
+	}
+	state.PublicSeq = f.publicSeq
+	state.ServerUUIDs = maps.Clone(f.serverUUIDs)
+	for _, id := range f.firewallList {
+		state.Firewalls = append(state.Firewalls, *copyFirewall(f.firewalls[id]))
+	}
+	state.FirewallSeq = f.firewallSeq
+	state.Networks = make([]NetworkDetails, 0, len(f.networkList))
+	for _, id := range f.networkList {
+		state.Networks = append(state.Networks, *f.networks[id])
+	}
+	state.NetworkSeq = f.networkSeq
+	f.mu.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp") // renamed over path once complete
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly after the rename
+
+	_, err = tmp.Write(append(data, '\n'))
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadState replaces all LBs and firewall policies with those from a SaveState file, and the groups,
+// servers and networks if it has them
+func (f *FakeClient) LoadState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	state := fakeState{}
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID = state.NextID
+	f.lbs = make(map[string]*fakeLB)
+	f.lbList = make([]string, 0, len(state.LoadBalancers))
+	for idx := range state.LoadBalancers {
+		lb := &fakeLB{details: *copyLB(&state.LoadBalancers[idx])}
+		f.lbs[lb.details.LBID] = lb
+		f.lbList = append(f.lbList, lb.details.LBID)
+	}
+
+	if state.Groups != nil {
+		f.groupSeq = state.GroupSeq
+		f.groups = make(map[string]*fakeGroup)
+		f.groupList = make([]string, 0, len(state.Groups))
+		for idx := range state.Groups {
+			group := state.Groups[idx]
+			f.groups[group.ID] = &group
+			f.groupList = append(f.groupList, group.ID)
+		}
+
+		f.servers = make(map[string]*ServerDetails)
+		f.serverList = make([]string, 0, len(state.Servers))
+		for idx := range state.Servers {
+			f.servers[state.Servers[idx].ServerID] = copyServer(&state.Servers[idx])
+			f.serverList = append(f.serverList, state.Servers[idx].ServerID)
+		}
+
+		f.serverUUIDs = make(map[string]string)
+		for uuid, id := range state.ServerUUIDs {
+			f.serverUUIDs[uuid] = id
+		}
+
+		f.publicSeq = state.PublicSeq
+		f.publicIPs = make(map[string]*PublicIPDetails)
+		for idx := range state.PublicIPs {
+			f.publicIPs[state.PublicIPs[idx].PublicIP] = copyPublicIP(&state.PublicIPs[idx])
+		}
+	}
+
+	f.firewallSeq = state.FirewallSeq
+	f.firewalls = make(map[string]*FirewallPolicy)
+	f.firewallList = make([]string, 0, len(state.Firewalls))
+	for idx := range state.Firewalls {
+		f.firewalls[state.Firewalls[idx].PolicyID] = copyFirewall(&state.Firewalls[idx])
+		f.firewallList = append(f.firewallList, state.Firewalls[idx].PolicyID)
+	}
+
+	if state.Networks != nil {
+		f.networkSeq = state.NetworkSeq
+		f.networks = make(map[string]*NetworkDetails)
+		f.networkList = make([]string, 0, len(state.Networks))
+		for idx := range state.Networks {
+			network := state.Networks[idx]
+			f.networks[network.NetworkID] = &network
+			f.networkList = append(f.networkList, network.NetworkID)
+		}
+	}
+
+	return nil
+}
+
+//////////////// CenturyLinkClient methods
+
+func (f *FakeClient) logout() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loggedIn = false
+}
+
+func (f *FakeClient) hasCredentials() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.loggedIn
+}
+
+func (f *FakeClient) getUsername() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.loggedIn {
+		return ""
+	}
+	return f.username
+}
+
+func (f *FakeClient) configure(opts ...ClientOption) {
+	// nothing goes over HTTP, so only the job tracker applies
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cfg := newClcConfig(append([]ClientOption{WithJobs(f.jobs)}, opts...)...)
+	f.jobs = cfg.jobs
+}
+
+func (f *FakeClient) jobTracker() *JobTracker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.jobs
+}
+
+// there is no cache to skip
+func (f *FakeClient) uncached() CenturyLinkClient {
+	return f
+}
+
+func (f *FakeClient) getStats() []HostStats {
+	return nil // no hosts, see CallCount
+}
+
+func (f *FakeClient) exportEnv() string {
+	return "" // nothing outside this process could use a fake login
+}
+
+func (f *FakeClient) getAccountAlias() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.loggedIn {
+		return ""
+	}
+	return f.account
+}
+
+func (f *FakeClient) listAllDC() ([]DataCenterName, error) {
+	if err := f.begin("listAllDC"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	return append([]DataCenterName{}, f.dcs...), nil
+}
+
+func (f *FakeClient) createLB(dc string, name string, description string) (*LoadBalancerCreationInfo, error) {
+	if err := f.begin("createLB"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	if f.noLBService[dc] {
+		return nil, lbServiceError(dc)
+	}
+
+	lb := &fakeLB{
+		details: LoadBalancerDetails{
+			LBID:        f.newID(),
+			Name:        name,
+			Description: description,
+			PublicIP:    fmt.Sprintf("192.0.2.%d", 1+(f.nextID%254)), // TEST-NET-1
+			Pools:       make([]PoolDetails, 0),
+			Status:      FAKE_STATUS_READY,
+			DataCenter:  dc,
+		},
+		pendingPolls: f.provisioningPolls,
+	}
+
+	if lb.pendingPolls > 0 {
+		lb.details.Status = FAKE_STATUS_PROVISIONING
+	}
+
+	f.lbs[lb.details.LBID] = lb
+	f.lbList = append(f.lbList, lb.details.LBID)
+
+	requestStatus := "COMPLETE"
+	if lb.pendingPolls > 0 {
+		requestStatus = "ACCEPTED"
+	}
+
+	return &LoadBalancerCreationInfo{LBID: lb.details.LBID, RequestTime: time.Now().Unix(), Status: requestStatus}, nil
+}
+
+func (f *FakeClient) deleteLB(dc, lbid string) (bool, error) {
+	if err := f.begin("deleteLB"); err != nil {
+		return false, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return false, errDC
+	}
+
+	if f.findLB(dc, lbid) == nil {
+		return false, nil // same as clcImpl: deleting what isn't there succeeds
+	}
+
+	delete(f.lbs, lbid)
+	for idx, id := range f.lbList {
+		if id == lbid {
+			f.lbList = append(f.lbList[:idx], f.lbList[idx+1:]...)
+			break
+		}
+	}
+
+	return true, nil
+}
+
+func (f *FakeClient) inspectLB(dc, lbid string) (*LoadBalancerDetails, HttpError) {
+	if err := f.begin("inspectLB"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	lb := f.findLB(dc, lbid)
+	if lb == nil {
+		return nil, notFound()
+	}
+
+	lb.poll()
+	return copyLB(&lb.details), nil
+}
+
+func (f *FakeClient) listAllLB() ([]LoadBalancerSummary, error) {
+	if err := f.begin("listAllLB"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	ret := make([]LoadBalancerSummary, 0, len(f.lbList))
+	for _, lbid := range f.lbList {
+		lb := f.lbs[lbid]
+		lb.poll()
+		ret = append(ret, LoadBalancerSummary{
+			LBID:        lb.details.LBID,
+			Name:        lb.details.Name,
+			Description: lb.details.Description,
+			PublicIP:    lb.details.PublicIP,
+			DataCenter:  lb.details.DataCenter,
+		})
+	}
+
+	return ret, nil
+}
+
+func (f *FakeClient) lbServiceOffered(dc string) (bool, error) {
+	if err := f.begin("lbServiceOffered"); err != nil {
+		return false, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return false, errDC
+	}
+
+	return !f.noLBService[dc], nil
+}
+
+func (f *FakeClient) inspectPool(dc, lbid, poolid string) (*PoolDetails, error) {
+	if err := f.begin("inspectPool"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	lb := f.findLB(dc, lbid)
+	if lb == nil {
+		return nil, notFound()
+	}
+
+	for idx := range lb.details.Pools {
+		if lb.details.Pools[idx].PoolID == poolid {
+			return copyPool(&lb.details.Pools[idx]), nil
+		}
+	}
+
+	return nil, makeErrorOld("pool not found") // as clcImpl reports it
+}
+
+// pools go to LBs that are ready, on a port no other pool of the LB uses
+func (f *FakeClient) checkPool(lb *fakeLB, pool *PoolDetails) HttpError {
+	if lb.details.Status != FAKE_STATUS_READY {
+		return makeError("HTTP call failed", 409, nil)
+	}
+
+	if (pool.IncomingPort <= 0) || (pool.IncomingPort > 65535) {
+		return makeError("HTTP call failed", 400, nil)
+	}
+
+	for _, other := range lb.details.Pools {
+		if (other.IncomingPort == pool.IncomingPort) && (other.PoolID != pool.PoolID) {
+			return makeError("HTTP call failed", 400, nil)
+		}
+	}
+
+	return nil
+}
+
+func (f *FakeClient) createPool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) {
+	if err := f.begin("createPool"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	lb := f.findLB(dc, lbid)
+	if lb == nil {
+		return nil, notFound()
+	}
+
+	pool := copyPool(newpool)
+	pool.PoolID = ""
+	pool.LBID = lbid
+
+	if err := f.checkPool(lb, pool); err != nil {
+		return nil, err
+	}
+
+	pool.PoolID = f.newID()
+	lb.details.Pools = append(lb.details.Pools, *pool)
+
+	ret := copyPool(pool)
+	ret.Request = f.startLBRequest(dc, "create pool")
+	return ret, nil
+}
+
+func (f *FakeClient) updatePool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) {
+	if err := f.begin("updatePool"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	lb := f.findLB(dc, lbid)
+	if lb == nil {
+		return nil, notFound()
+	}
+
+	for idx := range lb.details.Pools {
+		if lb.details.Pools[idx].PoolID == newpool.PoolID {
+			pool := copyPool(newpool)
+			pool.LBID = lbid
+
+			if err := f.checkPool(lb, pool); err != nil {
+				return nil, err
+			}
+
+			lb.details.Pools[idx] = *pool
+
+			ret := copyPool(pool)
+			ret.Request = f.startLBRequest(dc, "update pool")
+			return ret, nil
+		}
+	}
+
+	return nil, notFound()
+}
+
+func (f *FakeClient) deletePool(dc, lbid string, poolID string) (*LBRequest, error) {
+	if err := f.begin("deletePool"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	lb := f.findLB(dc, lbid)
+	if lb == nil {
+		return nil, notFound()
+	}
+
+	for idx := range lb.details.Pools {
+		if lb.details.Pools[idx].PoolID == poolID {
+			lb.details.Pools = append(lb.details.Pools[:idx], lb.details.Pools[idx+1:]...)
+			return f.startLBRequest(dc, "delete pool"), nil
+		}
+	}
+
+	return nil, notFound()
+}
+
+// pool changes are made at once, but their requests report ACCEPTED for SetOperationPolls polls
+type fakeLBRequest struct { // by Href, which is the mock's path for it
+	request      LBRequest
+	pendingPolls int
+}
+
+// called with f.mu held.  Shares its numbering with operations.
+func (f *FakeClient) startLBRequest(dc, description string) *LBRequest {
+	f.operationSeq++
+	id := fmt.Sprintf("request-%d", f.operationSeq)
+	req := &fakeLBRequest{
+		request: LBRequest{RequestID: id, DataCenter: dc, Status: "COMPLETE", Description: description,
+			Href: fmt.Sprintf("/%s/%s/requests/%s", f.account, dc, id)},
+		pendingPolls: f.operationPolls,
+	}
+	if req.pendingPolls > 0 {
+		req.request.Status = "ACCEPTED"
+	}
+
+	f.lbRequests[req.request.Href] = req
+	ret := req.request
+	return &ret
+}
+
+func (f *FakeClient) inspectLBRequest(request *LBRequest) (*LBRequest, error) {
+	if err := f.begin("inspectLBRequest"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	req := f.lbRequests[request.Href]
+	if req == nil {
+		return nil, notFound()
+	}
+
+	if req.pendingPolls > 0 {
+		req.pendingPolls--
+	} else {
+		req.request.Status = "COMPLETE"
+	}
+
+	ret := req.request
+	return &ret, nil
+}
+
+// for trying out commands and scripts without an account: "auth fake".  UC1 has no load balancing.
+func defaultFakeClient() *FakeClient {
+	f := NewFakeClient("FAKE",
+		DataCenterName{DCID: "CA1", Name: "CA1 - Canada (Vancouver)"},
+		DataCenterName{DCID: "UC1", Name: "UC1 - US West (Santa Clara)"},
+		DataCenterName{DCID: "VA1", Name: "VA1 - US East (Sterling)"},
+		DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"})
+
+	f.SetLBService("UC1", false)
+	f.SeedDemoServers()
+	return f
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+//// the fake's firewall policies.  A policy is active as soon as it is created, as the real ones are
+//// within a few seconds.
+
+func copyFirewall(src *FirewallPolicy) *FirewallPolicy {
+	policy := *src
+	policy.Sources = append([]string{}, src.Sources...)
+	policy.Destinations = append([]string{}, src.Destinations...)
+	policy.Ports = append([]string{}, src.Ports...)
+	return &policy
+}
+
+// the policy, if it exists in that DC and is of that kind.  Called with f.mu held.
+func (f *FakeClient) findFirewall(dc string, crossDC bool, policyID string) *FirewallPolicy {
+	policy := f.firewalls[policyID]
+	if (policy == nil) || (policy.DataCenter != dc) || (policy.CrossDC != crossDC) {
+		return nil
+	}
+
+	return policy
+}
+
+// a copy of the policy, checked the way the API would.  Called with f.mu held.
+func (f *FakeClient) checkFirewall(policy *FirewallPolicy) (*FirewallPolicy, HttpError) {
+	checked := copyFirewall(policy)
+	if ValidateFirewallPolicy(checked) != nil {
+		return nil, makeError("HTTP call failed", 400, nil)
+	}
+
+	if checked.CrossDC {
+		if _, err := f.checkDC(checked.DestinationDC); err != nil {
+			return nil, makeError("HTTP call failed", 400, nil)
+		}
+	}
+
+	return checked, nil
+}
+
+func (f *FakeClient) listFirewallPolicies(dc string, crossDC bool) ([]FirewallPolicy, error) {
+	if err := f.begin("listFirewallPolicies"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	ret := make([]FirewallPolicy, 0)
+	for _, id := range f.firewallList {
+		if policy := f.findFirewall(dc, crossDC, id); policy != nil {
+			ret = append(ret, *copyFirewall(policy))
+		}
+	}
+
+	return ret, nil
+}
+
+func (f *FakeClient) inspectFirewallPolicy(dc string, crossDC bool, policyID string) (*FirewallPolicy, error) {
+	if err := f.begin("inspectFirewallPolicy"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	policy := f.findFirewall(dc, crossDC, policyID)
+	if policy == nil {
+		return nil, notFound()
+	}
+
+	return copyFirewall(policy), nil
+}
+
+func (f *FakeClient) createFirewallPolicy(dc string, policy *FirewallPolicy) (string, error) {
+	if err := f.begin("createFirewallPolicy"); err != nil {
+		return "", err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return "", errDC
+	}
+
+	created, err := f.checkFirewall(policy)
+	if err != nil {
+		return "", err
+	}
+
+	f.firewallSeq++
+	created.PolicyID = fmt.Sprintf("fa%030x", f.firewallSeq)
+	created.DataCenter = dc
+	created.Status = "active"
+	if created.CrossDC && (created.DestinationAccount == "") {
+		created.DestinationAccount = f.account
+	}
+
+	f.firewalls[created.PolicyID] = created
+	f.firewallList = append(f.firewallList, created.PolicyID)
+
+	return created.PolicyID, nil
+}
+
+// as the API does, only Enabled changes on a cross-DC policy, and never the destination account
+func (f *FakeClient) updateFirewallPolicy(dc string, policy *FirewallPolicy) error {
+	if err := f.begin("updateFirewallPolicy"); err != nil {
+		return err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return errDC
+	}
+
+	existing := f.findFirewall(dc, policy.CrossDC, policy.PolicyID)
+	if existing == nil {
+		return notFound()
+	}
+
+	if policy.CrossDC {
+		existing.Enabled = policy.Enabled
+		return nil
+	}
+
+	updated, err := f.checkFirewall(policy)
+	if err != nil {
+		return err
+	}
+
+	existing.Enabled = updated.Enabled
+	existing.Sources = updated.Sources
+	existing.Destinations = updated.Destinations
+	existing.Ports = updated.Ports
+	return nil
+}
+
+func (f *FakeClient) deleteFirewallPolicy(dc string, crossDC bool, policyID string) error {
+	if err := f.begin("deleteFirewallPolicy"); err != nil {
+		return err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return errDC
+	}
+
+	if f.findFirewall(dc, crossDC, policyID) == nil {
+		return notFound()
+	}
+
+	delete(f.firewalls, policyID)
+	for idx, id := range f.firewallList {
+		if id == policyID {
+			f.firewallList = append(f.firewallList[:idx], f.firewallList[idx+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
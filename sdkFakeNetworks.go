@@ -0,0 +1,242 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+//// the fake's networks.  Claimed networks are /24s, named the way CLC names them, and their
+//// addresses are claimed by whichever servers have them.
+
+const fakeNetworkMaxIPs = 1024 // inspectNetwork lists no more addresses than this
+
+// AddNetwork claims a network with the given CIDR.  Returns its ID.
+func (f *FakeClient) AddNetwork(dc, cidr string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dc, err := f.checkDC(dc)
+	if err != nil {
+		return "", err
+	}
+
+	return f.addNetwork(dc, cidr)
+}
+
+// called with f.mu held
+func (f *FakeClient) addNetwork(dc, cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if (err != nil) || !prefix.Addr().Is4() || (prefix.Bits() > 30) {
+		return "", fmt.Errorf("invalid network %q", cidr)
+	}
+	prefix = prefix.Masked()
+
+	f.networkSeq++
+	vlan := 100 + f.networkSeq
+	name := fmt.Sprintf("vlan_%d_%s", vlan, prefix.Addr())
+
+	network := &NetworkDetails{
+		NetworkID:   fmt.Sprintf("fb%030x", f.networkSeq),
+		DataCenter:  dc,
+		Name:        name,
+		Description: name,
+		CIDR:        prefix.String(),
+		Gateway:     prefix.Addr().Next().String(),
+		Netmask:     net.IP(net.CIDRMask(prefix.Bits(), 32)).String(),
+		Type:        "private",
+		VLAN:        vlan,
+	}
+
+	f.networks[network.NetworkID] = network
+	f.networkList = append(f.networkList, network.NetworkID)
+	return network.NetworkID, nil
+}
+
+// the network, if it exists in that DC.  Called with f.mu held.
+func (f *FakeClient) findNetwork(dc, networkID string) *NetworkDetails {
+	network := f.networks[networkID]
+	if (network == nil) || (network.DataCenter != dc) {
+		return nil
+	}
+
+	return network
+}
+
+// the servers' private IPs in the network, and their types.  Called with f.mu held.
+func (f *FakeClient) claimedIPs(network *NetworkDetails) map[string]NetworkIP {
+	mapped := make(map[string]bool) // private IPs with a public IP NAT'd to them
+	for _, ip := range f.publicIPs {
+		mapped[ip.InternalIP] = true
+	}
+
+	ret := make(map[string]NetworkIP)
+	for _, id := range f.serverList {
+		server := f.servers[id]
+		for _, ip := range server.PrivateIPs {
+			if (server.DataCenter == network.DataCenter) && network.Contains(ip) {
+				claimed := NetworkIP{Address: ip, Claimed: true, ServerID: server.ServerID, Type: "private"}
+				if mapped[ip] {
+					claimed.Type = "publicMapped"
+				}
+				ret[ip] = claimed
+			}
+		}
+	}
+
+	return ret
+}
+
+func (f *FakeClient) listNetworks(dc string) ([]NetworkDetails, error) {
+	if err := f.begin("listNetworks"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	ret := make([]NetworkDetails, 0)
+	for _, id := range f.networkList {
+		if network := f.findNetwork(dc, id); network != nil {
+			ret = append(ret, *network)
+		}
+	}
+
+	return ret, nil
+}
+
+// the addresses are those after the gateway, up to but not including the broadcast address
+func (f *FakeClient) inspectNetwork(dc, networkID, ipAddresses string) (*NetworkDetails, error) {
+	if err := f.begin("inspectNetwork"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	network := f.findNetwork(dc, networkID)
+	if network == nil {
+		return nil, notFound()
+	}
+
+	ret := *network
+	if ipAddresses == NETWORK_IPS_NONE {
+		return &ret, nil
+	} else if (ipAddresses != NETWORK_IPS_CLAIMED) && (ipAddresses != NETWORK_IPS_FREE) && (ipAddresses != NETWORK_IPS_ALL) {
+		return nil, makeError("HTTP call failed", 400, nil)
+	}
+
+	claimed := f.claimedIPs(network)
+	prefix := netip.MustParsePrefix(network.CIDR)
+	ret.IPAddresses = make([]NetworkIP, 0)
+
+	for addr := prefix.Addr().Next().Next(); prefix.Contains(addr.Next()) && (len(ret.IPAddresses) < fakeNetworkMaxIPs); addr = addr.Next() {
+		ip, isClaimed := claimed[addr.String()]
+		if !isClaimed {
+			ip = NetworkIP{Address: addr.String(), Type: "private"}
+		}
+
+		if (ipAddresses == NETWORK_IPS_ALL) || ((ipAddresses == NETWORK_IPS_CLAIMED) == isClaimed) {
+			ret.IPAddresses = append(ret.IPAddresses, ip)
+		}
+	}
+
+	return &ret, nil
+}
+
+// the next /24 after the DC's others, 10.200+ for a DC with none
+func (f *FakeClient) claimNetwork(dc string) (string, error) {
+	if err := f.begin("claimNetwork"); err != nil {
+		return "", err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return "", errDC
+	}
+
+	taken := make(map[string]bool)
+	second := ""
+	for _, id := range f.networkList {
+		taken[f.networks[id].CIDR] = true
+		if (second == "") && (f.networks[id].DataCenter == dc) {
+			second = strings.Split(f.networks[id].CIDR, ".")[1]
+		}
+	}
+
+	if second == "" {
+		for idx, known := range f.dcs {
+			if known.DCID == dc {
+				second = fmt.Sprint(200 + idx)
+			}
+		}
+	}
+
+	for third := 0; third < 256; third++ {
+		cidr := fmt.Sprintf("10.%s.%d.0/24", second, third)
+		if !taken[cidr] {
+			if _, err := f.addNetwork(dc, cidr); err != nil {
+				return "", makeError("HTTP call failed", 500, err)
+			}
+			return f.startOperation(dc), nil
+		}
+	}
+
+	return "", makeError("HTTP call failed", 400, nil) // no room left
+}
+
+// a network still in use by a server can't be released
+func (f *FakeClient) releaseNetwork(dc, networkID string) error {
+	if err := f.begin("releaseNetwork"); err != nil {
+		return err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return errDC
+	}
+
+	network := f.findNetwork(dc, networkID)
+	if network == nil {
+		return notFound()
+	}
+
+	if len(f.claimedIPs(network)) > 0 {
+		return makeError("HTTP call failed", 400, nil)
+	}
+
+	delete(f.networks, networkID)
+	for idx, id := range f.networkList {
+		if id == networkID {
+			f.networkList = append(f.networkList[:idx], f.networkList[idx+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
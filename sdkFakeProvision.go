@@ -0,0 +1,231 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+//// the fake's templates and server builds.  Every DC offers the same templates and import OS types,
+//// all but CA1 premium storage, and only VA1 bare metal.  A new server is there at once, its operation
+//// only says so after SetOperationPolls polls.
+
+var fakeTemplates = []ServerTemplate{
+	{Name: "UBUNTU-22-64-TEMPLATE", OSType: "ubuntu22_64Bit", Description: "Ubuntu 22 | 64-bit", StorageSizeGB: 17,
+		Capabilities: []string{"cpuAutoscale"}},
+	{Name: "RHEL-9-64-TEMPLATE", OSType: "redHat9_64Bit", Description: "RedHat Enterprise Linux 9 | 64-bit", StorageSizeGB: 17,
+		Capabilities: []string{"cpuAutoscale"}},
+	{Name: "WIN2022DTC-64", OSType: "windows2022DataCenter_64Bit", Description: "Windows Server 2022 Datacenter Edition | 64-bit",
+		StorageSizeGB: 60, ReservedDrivePaths: []string{"bin", "boot", "dev", "etc", "lib", "proc", "sys", "windows"}},
+}
+
+var fakeImportableOSTypes = []ImportableOSType{
+	{ID: 27, Type: "redHat6_64Bit", Description: "RedHat Enterprise Linux 6 64-bit"},
+	{ID: 36, Type: "ubuntu14_64Bit", Description: "Ubuntu 14 64-bit"},
+	{ID: 41, Type: "windows2012R2DataCenter_64bit", Description: "Windows 2012 R2 DataCenter 64-bit",
+		LabProductCode: "MS-WIN2012R2DC-LAB", PremiumProductCode: "MS-WIN2012R2DC"},
+}
+
+var fakeBareMetal = BareMetalCapabilities{
+	SKUs: []BareMetalSKU{
+		{ID: "529e2592a3e640a7c2617b5e8bc8feaed95eab22", HourlyRate: 0.56, Availability: "high", MemoryGB: 16,
+			Processor: "Intel(R) Xeon(R) CPU E3-1271 v3 @ 3.60GHz", Sockets: 1, CoresPerSocket: 4,
+			Storage: []BareMetalDrive{{CapacityGB: 1000, Type: "Hdd", SpeedRpm: 7200}, {CapacityGB: 1000, Type: "Hdd", SpeedRpm: 7200}}},
+		{ID: "3a2c5a9b1e3f4d6c8b7a9e0d1c2b3a4f5e6d7c8b", HourlyRate: 1.84, Availability: "low", MemoryGB: 128,
+			Processor: "Intel(R) Xeon(R) CPU E5-2670 v3 @ 2.30GHz", Sockets: 2, CoresPerSocket: 12,
+			Storage: []BareMetalDrive{{CapacityGB: 480, Type: "Ssd"}, {CapacityGB: 480, Type: "Ssd"}, {CapacityGB: 2000, Type: "Hdd", SpeedRpm: 7200}}},
+	},
+	OperatingSystems: []BareMetalOS{
+		{Type: "redHat6_64Bit", Description: "RedHat Enterprise Linux 6 64-bit", HourlyRatePerSocket: 0},
+		{Type: "ubuntu14_64Bit", Description: "Ubuntu 14 64-bit", HourlyRatePerSocket: 0},
+		{Type: "windows2012R2Standard_64bit", Description: "Windows 2012 R2 Standard 64-bit", HourlyRatePerSocket: 0.07},
+	},
+}
+
+func (f *FakeClient) deploymentCapabilities(dc string) (*DeploymentCapabilities, error) {
+	if err := f.begin("deploymentCapabilities"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	ret := &DeploymentCapabilities{
+		DataCenter:                 dc,
+		SupportsPremiumStorage:     dc != "CA1",
+		SupportsBareMetal:          dc == "VA1",
+		SupportsSharedLoadBalancer: false, // the fake has no legacy shared LB, see lbServiceOffered
+		Networks:                   make([]DeployableNetwork, 0),
+		Templates:                  make([]ServerTemplate, len(fakeTemplates)),
+		ImportableOSTypes:          append([]ImportableOSType{}, fakeImportableOSTypes...),
+	}
+
+	for _, id := range f.networkList {
+		if n := f.networks[id]; n.DataCenter == dc {
+			ret.Networks = append(ret.Networks, DeployableNetwork{NetworkID: n.NetworkID, Name: n.Name, Type: n.Type, AccountID: f.account})
+		}
+	}
+
+	for idx, t := range fakeTemplates {
+		ret.Templates[idx] = t
+		ret.Templates[idx].Capabilities = append([]string{}, t.Capabilities...)
+		ret.Templates[idx].ReservedDrivePaths = append([]string{}, t.ReservedDrivePaths...)
+	}
+
+	return ret, nil
+}
+
+func (f *FakeClient) bareMetalCapabilities(dc string) (*BareMetalCapabilities, error) {
+	if err := f.begin("bareMetalCapabilities"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+	if dc != "VA1" {
+		return nil, notFound()
+	}
+
+	ret := &BareMetalCapabilities{
+		SKUs:             make([]BareMetalSKU, len(fakeBareMetal.SKUs)),
+		OperatingSystems: append([]BareMetalOS{}, fakeBareMetal.OperatingSystems...),
+	}
+	for idx, sku := range fakeBareMetal.SKUs {
+		ret.SKUs[idx] = sku
+		ret.SKUs[idx].Storage = append([]BareMetalDrive{}, sku.Storage...)
+	}
+
+	return ret, nil
+}
+
+// a free address in the network, after its gateway.  Called with f.mu held.
+func (f *FakeClient) freeIP(network *NetworkDetails) string {
+	prefix, err := netip.ParsePrefix(network.CIDR)
+	if err != nil {
+		return ""
+	}
+
+	claimed := f.claimedIPs(network)
+	for addr := prefix.Addr().Next().Next(); prefix.Contains(addr.Next()); addr = addr.Next() { // not the broadcast address
+		if _, taken := claimed[addr.String()]; !taken {
+			return addr.String()
+		}
+	}
+
+	return ""
+}
+
+// the server is named like the real ones, DC + account + name + the first free number
+func (f *FakeClient) createServer(spec *ServerSpec) (*ServerCreationInfo, error) {
+	if err := f.begin("createServer"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	group := f.groups[spec.GroupID]
+	if group == nil {
+		return nil, makeError("HTTP call failed", 400, nil)
+	}
+	dc := group.DataCenter
+
+	var template *ServerTemplate
+	for idx := range fakeTemplates {
+		if strings.EqualFold(fakeTemplates[idx].Name, spec.Template) {
+			template = &fakeTemplates[idx]
+		}
+	}
+
+	if (template == nil) || (spec.Name == "") || (spec.CPU < 1) || (spec.CPU > 16) || (spec.MemoryGB < 1) || (spec.MemoryGB > 128) {
+		return nil, makeError("HTTP call failed", 400, nil)
+	}
+	if (spec.StorageType == STORAGE_PREMIUM) && (dc == "CA1") {
+		return nil, makeError("HTTP call failed", 400, nil)
+	}
+
+	var network *NetworkDetails
+	if spec.NetworkID != "" {
+		network = f.findNetwork(dc, spec.NetworkID)
+	} else {
+		for _, id := range f.networkList {
+			if f.networks[id].DataCenter == dc {
+				network = f.networks[id]
+				break
+			}
+		}
+	}
+	if network == nil {
+		return nil, makeError("HTTP call failed", 400, nil)
+	}
+
+	ip := spec.IPAddress
+	if ip == "" {
+		ip = f.freeIP(network)
+	} else if _, taken := f.claimedIPs(network)[ip]; taken || !network.Contains(ip) {
+		return nil, makeError("HTTP call failed", 400, nil)
+	}
+	if ip == "" {
+		return nil, makeError("HTTP call failed", 400, nil) // the network is full
+	}
+
+	id := ""
+	for n := 1; (id == "") || (f.servers[id] != nil); n++ {
+		id = fmt.Sprintf("%s%s%s%02d", dc, f.account, strings.ToUpper(spec.Name), n)
+	}
+
+	f.servers[id] = &ServerDetails{
+		ServerID:    id,
+		Description: spec.Description,
+		DataCenter:  dc,
+		GroupID:     group.ID,
+		Status:      "active",
+		PowerState:  "started",
+		OSType:      template.Description,
+		CPU:         spec.CPU,
+		MemoryMB:    spec.MemoryGB * 1024,
+		StorageGB:   template.StorageSizeGB,
+		PrivateIPs:  []string{ip},
+		PublicIPs:   []string{},
+	}
+	f.serverList = append(f.serverList, id)
+
+	uuid := fmt.Sprintf("fc%030x", len(f.serverUUIDs)+1)
+	f.serverUUIDs[uuid] = id
+
+	return &ServerCreationInfo{Name: spec.Name, UUID: uuid, OperationID: f.startOperation(dc)}, nil
+}
+
+func (f *FakeClient) inspectServerUUID(uuid string) (*ServerDetails, error) {
+	if err := f.begin("inspectServerUUID"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	server := f.servers[f.serverUUIDs[uuid]]
+	if server == nil {
+		return nil, notFound()
+	}
+
+	return copyServer(server), nil
+}
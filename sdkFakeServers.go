@@ -0,0 +1,510 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//// the FakeClient's servers and groups.  Groups are kept flat, with parent IDs, and assembled into
+//// trees when asked for.  Servers don't do anything, they just have a group, IPs and a power state.
+
+type fakeGroup struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	DataCenter  string `json:"dataCenter"`
+	ParentID    string `json:"parentID"` // "" for a DC's root group
+	Type        string `json:"type"`
+}
+
+// called with f.mu held, or before f is shared
+func (f *FakeClient) addGroup(group *fakeGroup) string {
+	f.groupSeq++
+	group.ID = fmt.Sprintf("ff%030x", f.groupSeq) // 32 hex digits, and no clash with LB IDs
+	f.groups[group.ID] = group
+	f.groupList = append(f.groupList, group.ID)
+	return group.ID
+}
+
+// AddGroup makes a group under parentID, or under the DC's root group if parentID is "".  Returns its ID.
+func (f *FakeClient) AddGroup(dc, parentID, name, description string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dc, err := f.checkDC(dc)
+	if err != nil {
+		return "", err
+	}
+
+	if parentID == "" {
+		parentID = f.findRootGroup(dc)
+	}
+
+	parent := f.groups[parentID]
+	if (parent == nil) || (parent.DataCenter != dc) {
+		return "", fmt.Errorf("no group %s in %s", parentID, dc)
+	}
+
+	return f.addGroup(&fakeGroup{Name: name, Description: description, DataCenter: dc, ParentID: parentID, Type: "default"}), nil
+}
+
+// AddServer puts a server in an existing group.  Its DC is the group's, and a blank status or power
+// state means an active, started server.
+func (f *FakeClient) AddServer(server ServerDetails) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	server.ServerID = strings.ToUpper(server.ServerID)
+	if f.servers[server.ServerID] != nil {
+		return fmt.Errorf("server %s already exists", server.ServerID)
+	}
+
+	group := f.groups[server.GroupID]
+	if group == nil {
+		return fmt.Errorf("no group %s", server.GroupID)
+	}
+
+	server.DataCenter = group.DataCenter
+	if server.Status == "" {
+		server.Status = "active"
+	}
+	if server.PowerState == "" {
+		server.PowerState = "started"
+	}
+
+	f.servers[server.ServerID] = copyServer(&server)
+	f.serverList = append(f.serverList, server.ServerID)
+	return nil
+}
+
+// FindGroup is the ID of the first group in dc with this name, "" if there is none
+func (f *FakeClient) FindGroup(dc, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range f.groupList {
+		if (f.groups[id].DataCenter == NormalizeDC(dc)) && (f.groups[id].Name == name) {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// SeedDemoServers adds a few groups and servers in WA1 and VA1, named the way CLC names them
+// (DC, account, name, number), and the networks they are on, for "auth fake" and the mock server
+func (f *FakeClient) SeedDemoServers() {
+	demo := []struct {
+		dc, group, name, ip, power string
+		cpu, memoryGB              int
+	}{
+		{"WA1", "Default Group", "UTIL01", "10.0.0.10", "started", 1, 2},
+		{"WA1", "web-tier", "WEB01", "10.0.1.11", "started", 2, 4},
+		{"WA1", "web-tier", "WEB02", "10.0.1.12", "started", 2, 4},
+		{"WA1", "db-tier", "DB01", "10.0.2.21", "stopped", 4, 16},
+		{"VA1", "Default Group", "WEB01", "10.1.1.11", "started", 2, 4},
+	}
+
+	for _, d := range demo {
+		if !f.hasDC(d.dc) {
+			continue
+		}
+
+		groupID := f.FindGroup(d.dc, d.group)
+		if groupID == "" {
+			groupID, _ = f.AddGroup(d.dc, "", d.group, "")
+		}
+
+		f.AddServer(ServerDetails{
+			ServerID:   d.dc + f.account + d.name,
+			GroupID:    groupID,
+			PowerState: d.power,
+			OSType:     "Ubuntu 14 64-bit",
+			CPU:        d.cpu,
+			MemoryMB:   d.memoryGB * 1024,
+			StorageGB:  17,
+			PrivateIPs: []string{d.ip},
+			PublicIPs:  []string{},
+		})
+	}
+
+	for _, n := range []struct{ dc, cidr string }{
+		{"WA1", "10.0.0.0/24"}, {"WA1", "10.0.1.0/24"}, {"WA1", "10.0.2.0/24"}, {"VA1", "10.1.1.0/24"},
+	} {
+		if f.hasDC(n.dc) {
+			f.AddNetwork(n.dc, n.cidr)
+		}
+	}
+}
+
+func (f *FakeClient) hasDC(dc string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, err := f.checkDC(dc)
+	return err == nil
+}
+
+// called with f.mu held
+func (f *FakeClient) findRootGroup(dc string) string {
+	for _, id := range f.groupList {
+		if (f.groups[id].DataCenter == dc) && (f.groups[id].ParentID == "") {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// called with f.mu held
+func (f *FakeClient) groupTree(id string) GroupDetails {
+	group := f.groups[id]
+	ret := GroupDetails{
+		GroupID:     group.ID,
+		Name:        group.Name,
+		Description: group.Description,
+		DataCenter:  group.DataCenter,
+		ParentID:    group.ParentID,
+		Type:        group.Type,
+		ServerIDs:   make([]string, 0),
+		Groups:      make([]GroupDetails, 0),
+	}
+
+	for _, serverID := range f.serverList {
+		if f.servers[serverID].GroupID == id {
+			ret.ServerIDs = append(ret.ServerIDs, serverID)
+		}
+	}
+
+	for _, childID := range f.groupList {
+		if f.groups[childID].ParentID == id {
+			ret.Groups = append(ret.Groups, f.groupTree(childID))
+		}
+	}
+
+	return ret
+}
+
+func copyServer(src *ServerDetails) *ServerDetails {
+	server := *src
+	server.PrivateIPs = append([]string{}, src.PrivateIPs...)
+	server.PublicIPs = append([]string{}, src.PublicIPs...)
+	return &server
+}
+
+//////////////// CenturyLinkClient methods
+
+func (f *FakeClient) inspectServer(serverID string) (*ServerDetails, error) {
+	if err := f.begin("inspectServer"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	server := f.servers[strings.ToUpper(serverID)]
+	if server == nil {
+		return nil, notFound()
+	}
+
+	return copyServer(server), nil
+}
+
+func (f *FakeClient) listServers(dc string) ([]ServerDetails, error) {
+	if err := f.begin("listServers"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	if dc != "" {
+		var errDC HttpError
+		dc, errDC = f.checkDC(dc)
+		if errDC != nil {
+			return nil, errDC
+		}
+	}
+
+	ret := make([]ServerDetails, 0)
+	for _, id := range f.serverList {
+		if (dc == "") || (f.servers[id].DataCenter == dc) {
+			ret = append(ret, *copyServer(f.servers[id]))
+		}
+	}
+
+	return ret, nil
+}
+
+func (f *FakeClient) rootGroupID(dc string) (string, error) {
+	if err := f.begin("rootGroupID"); err != nil {
+		return "", err
+	}
+	defer f.mu.Unlock()
+
+	dc, errDC := f.checkDC(dc)
+	if errDC != nil {
+		return "", errDC
+	}
+
+	id := f.findRootGroup(dc)
+	if id == "" {
+		return "", notFound()
+	}
+
+	return id, nil
+}
+
+func (f *FakeClient) inspectGroup(groupID string) (*GroupDetails, error) {
+	if err := f.begin("inspectGroup"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	if f.groups[groupID] == nil {
+		return nil, notFound()
+	}
+
+	group := f.groupTree(groupID)
+	return &group, nil
+}
+
+//////////////// public IPs and operations
+
+// operations the fake queues go from executing to succeeded; the change itself is made at once
+type fakeOperation struct {
+	pendingPolls int
+}
+
+// SetOperationPolls makes operations, and pool change requests, started from now on report executing for
+// n status calls
+func (f *FakeClient) SetOperationPolls(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.operationPolls = n
+}
+
+// called with f.mu held.  IDs look like the real ones, "wa1-123".
+func (f *FakeClient) startOperation(dc string) string {
+	f.operationSeq++
+	id := fmt.Sprintf("%s-%d", strings.ToLower(dc), f.operationSeq)
+	f.operations[id] = &fakeOperation{pendingPolls: f.operationPolls}
+	return id
+}
+
+func copyPublicIP(src *PublicIPDetails) *PublicIPDetails {
+	ip := *src
+	ip.Ports = append([]PublicIPPort{}, src.Ports...)
+	ip.SourceRestrictions = append([]string{}, src.SourceRestrictions...)
+	return &ip
+}
+
+// the server, if it has this public IP.  Called with f.mu held.
+func (f *FakeClient) findPublicIP(serverID, publicIP string) (*ServerDetails, *PublicIPDetails) {
+	server := f.servers[strings.ToUpper(serverID)]
+	if (server == nil) || !slices.Contains(server.PublicIPs, publicIP) {
+		return nil, nil
+	}
+
+	return server, f.publicIPs[publicIP]
+}
+
+// ports are required, and the internal IP has to be the server's.  Called with f.mu held.
+func checkPublicIP(server *ServerDetails, ip *PublicIPDetails) HttpError {
+	if len(ip.Ports) == 0 {
+		return makeError("HTTP call failed", 400, nil)
+	}
+
+	if (ip.InternalIP != "") && !slices.Contains(server.PrivateIPs, ip.InternalIP) {
+		return makeError("HTTP call failed", 400, nil)
+	}
+
+	return nil
+}
+
+func (f *FakeClient) operationStatus(operationID string) (string, error) {
+	if err := f.begin("operationStatus"); err != nil {
+		return "", err
+	}
+	defer f.mu.Unlock()
+
+	op := f.operations[operationID]
+	if op == nil {
+		return "", notFound()
+	}
+
+	if op.pendingPolls > 0 {
+		op.pendingPolls--
+		return OPERATION_EXECUTING, nil
+	}
+
+	return OPERATION_SUCCEEDED, nil
+}
+
+func (f *FakeClient) inspectPublicIP(serverID, publicIP string) (*PublicIPDetails, error) {
+	if err := f.begin("inspectPublicIP"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	_, ip := f.findPublicIP(serverID, publicIP)
+	if ip == nil {
+		return nil, notFound()
+	}
+
+	return copyPublicIP(ip), nil
+}
+
+// a blank InternalIP gets the server's first private IP
+func (f *FakeClient) addPublicIP(serverID string, ip *PublicIPDetails) (string, error) {
+	if err := f.begin("addPublicIP"); err != nil {
+		return "", err
+	}
+	defer f.mu.Unlock()
+
+	server := f.servers[strings.ToUpper(serverID)]
+	if server == nil {
+		return "", notFound()
+	}
+
+	if err := checkPublicIP(server, ip); err != nil {
+		return "", err
+	}
+
+	publicIP := f.freePublicIP()
+	if publicIP == "" {
+		return "", makeError("HTTP call failed", 400, nil)
+	}
+
+	added := copyPublicIP(ip)
+	added.PublicIP = publicIP
+	added.ServerID = server.ServerID
+	if added.InternalIP == "" {
+		added.InternalIP = server.PrivateIP()
+	}
+
+	f.publicIPs[added.PublicIP] = added
+	server.PublicIPs = append(server.PublicIPs, added.PublicIP)
+
+	return f.startOperation(server.DataCenter), nil
+}
+
+// the next unused address in TEST-NET-3, or "" when all 254 are in use.  Called with f.mu held.
+func (f *FakeClient) freePublicIP() string {
+	for tries := 0; tries < 254; tries++ {
+		f.publicSeq++
+		addr := fmt.Sprintf("203.0.113.%d", 1+(f.publicSeq%254))
+		if _, taken := f.publicIPs[addr]; !taken {
+			return addr
+		}
+	}
+
+	return ""
+}
+
+func (f *FakeClient) updatePublicIP(serverID string, ip *PublicIPDetails) (string, error) {
+	if err := f.begin("updatePublicIP"); err != nil {
+		return "", err
+	}
+	defer f.mu.Unlock()
+
+	server, existing := f.findPublicIP(serverID, ip.PublicIP)
+	if existing == nil {
+		return "", notFound()
+	}
+
+	if err := checkPublicIP(server, ip); err != nil {
+		return "", err
+	}
+
+	updated := copyPublicIP(ip)
+	updated.ServerID = server.ServerID
+	if updated.InternalIP == "" {
+		updated.InternalIP = existing.InternalIP
+	}
+	f.publicIPs[ip.PublicIP] = updated
+
+	return f.startOperation(server.DataCenter), nil
+}
+
+func (f *FakeClient) deletePublicIP(serverID, publicIP string) (string, error) {
+	if err := f.begin("deletePublicIP"); err != nil {
+		return "", err
+	}
+	defer f.mu.Unlock()
+
+	server, existing := f.findPublicIP(serverID, publicIP)
+	if existing == nil {
+		return "", notFound()
+	}
+
+	delete(f.publicIPs, publicIP)
+	server.PublicIPs = slices.DeleteFunc(server.PublicIPs, func(s string) bool { return s == publicIP })
+
+	return f.startOperation(server.DataCenter), nil
+}
+
+// the change is made when the operation is queued.  As the API does, a server that is stopped can't
+// be rebooted or paused, and one that is paused can only be powered on.
+func (f *FakeClient) serverOperation(operation string, serverIDs []string) ([]ServerOperationResult, error) {
+	if err := f.begin("serverOperation"); err != nil {
+		return nil, err
+	}
+	defer f.mu.Unlock()
+
+	ret := make([]ServerOperationResult, len(serverIDs))
+	for idx, id := range serverIDs {
+		result := &ret[idx]
+		result.ServerID = strings.ToUpper(id)
+
+		server := f.servers[result.ServerID]
+		if server == nil {
+			result.Error = "The operation cannot be queued because the server cannot be found or it is not in a valid state."
+			continue
+		}
+
+		power := server.PowerState
+		valid := true
+		switch operation {
+		case SERVER_OP_POWER_ON:
+			power = "started"
+		case SERVER_OP_POWER_OFF, SERVER_OP_SHUT_DOWN:
+			valid = (power != "paused")
+			power = "stopped"
+		case SERVER_OP_REBOOT:
+			valid = (power == "started")
+		case SERVER_OP_PAUSE:
+			valid = (power == "started")
+			power = "paused"
+		case SERVER_OP_START_MAINTENANCE, SERVER_OP_STOP_MAINTENANCE:
+			server.InMaintenance = (operation == SERVER_OP_START_MAINTENANCE)
+		default:
+			return nil, notFound()
+		}
+
+		if !valid {
+			result.Error = "The operation cannot be queued because the server cannot be found or it is not in a valid state."
+			continue
+		}
+
+		server.PowerState = power
+		result.Queued = true
+		result.OperationID = f.startOperation(server.DataCenter)
+		result.Status = OPERATION_NOT_STARTED
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func newFakeApp(t *testing.T) (*AppState, *FakeClient) {
+	t.Helper()
+	f := NewFakeClient("TEST", DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"})
+	return &AppState{clc: f, vars: make(map[string]string)}, f
+}
+
+// runs a REPL line, which must succeed
+func mustRun(t *testing.T, app *AppState, line string) {
+	t.Helper()
+	processInputLine(app, line)
+	if app.lastErr != nil {
+		t.Fatalf("%s: %s", line, app.lastErr.Error())
+	}
+}
+
+func TestFakeLBAndPoolCommands(t *testing.T) {
+	app, f := newFakeApp(t)
+
+	mustRun(t, app, "LB create wa1 web frontends")
+	lbid := app.vars["lb.lbid"]
+	if lbid == "" {
+		t.Fatalf("LB create bound no lb.lbid, vars=%v", app.vars)
+	}
+
+	mustRun(t, app, "pool create WA1 $last port=80 method=roundRobin target=8080 nodes=10.0.0.1,10.0.0.2")
+	poolID := app.vars["pool.poolid"]
+	if app.vars["pool.nodes.count"] != "2" {
+		t.Errorf("pool.nodes.count = %q, want 2", app.vars["pool.nodes.count"])
+	}
+	if app.vars["pool.nodes.1.targetport"] != "8080" {
+		t.Errorf("pool.nodes.1.targetport = %q, want 8080", app.vars["pool.nodes.1.targetport"])
+	}
+
+	mustRun(t, app, "pool update WA1 ${lb.lbid} "+poolID+" port=80 nodes=10.0.0.3")
+	mustRun(t, app, "LB details WA1 "+lbid)
+	mustRun(t, app, "assert lb.pools.count == 1")
+	mustRun(t, app, "assert lb.pools.0.nodes.0.targetip == 10.0.0.3")
+
+	mustRun(t, app, "pool delete WA1 "+lbid+" "+poolID)
+	lb, err := f.inspectLB("WA1", lbid)
+	if err != nil {
+		t.Fatalf("inspectLB: %s", err.Error())
+	}
+	if len(lb.Pools) != 0 {
+		t.Errorf("%d pools left after pool delete", len(lb.Pools))
+	}
+
+	mustRun(t, app, "LB delete WA1 "+lbid)
+	mustRun(t, app, "LB list")
+	if app.vars["lbs.count"] != "0" {
+		t.Errorf("lbs.count = %q after LB delete", app.vars["lbs.count"])
+	}
+}
+
+func TestFakeInjectedFailure(t *testing.T) {
+	app, f := newFakeApp(t)
+	mustRun(t, app, "LB create WA1 web frontends")
+	lbid := app.vars["lb.lbid"]
+
+	f.FailNext("createPool", 503)
+	processInputLine(app, "pool create WA1 "+lbid+" port=80 nodes=10.0.0.1")
+	if (app.lastErr == nil) || !strings.Contains(app.lastErr.Error(), "remote call failed") {
+		t.Fatalf("pool create with an injected 503: lastErr=%v", app.lastErr)
+	}
+
+	lb, _ := f.inspectLB("WA1", lbid)
+	if len(lb.Pools) != 0 {
+		t.Errorf("the failed create left %d pools", len(lb.Pools))
+	}
+	if n := f.CallCount("createPool"); n != 1 {
+		t.Errorf("CallCount(createPool) = %d, want 1", n)
+	}
+
+	mustRun(t, app, "pool create WA1 "+lbid+" port=80 nodes=10.0.0.1") // the failure was for one call only
+
+	f.FailNext("*", 500)
+	processInputLine(app, "LB details WA1 "+lbid)
+	if app.lastErr == nil {
+		t.Fatalf("LB details with an injected 500 succeeded")
+	}
+}
+
+// 203.0.113.0/24 has 254 addresses to give out, none of them twice
+func TestFakePublicIPsNotReused(t *testing.T) {
+	_, f := newFakeApp(t)
+	groupID, _ := f.AddGroup("WA1", "", "web", "")
+	if err := f.AddServer(ServerDetails{ServerID: "WA1TESTWEB01", GroupID: groupID, PrivateIPs: []string{"10.0.0.11"}}); err != nil {
+		t.Fatalf("AddServer: %s", err.Error())
+	}
+
+	ip := &PublicIPDetails{Ports: []PublicIPPort{{Protocol: "TCP", Port: 443}}}
+	seen := make(map[string]bool)
+	for idx := 0; idx < 254; idx++ {
+		if _, err := f.addPublicIP("WA1TESTWEB01", ip); err != nil {
+			t.Fatalf("addPublicIP #%d: %s", idx+1, err.Error())
+		}
+		server, _ := f.inspectServer("WA1TESTWEB01")
+		added := server.PublicIPs[len(server.PublicIPs)-1]
+		if seen[added] {
+			t.Fatalf("addPublicIP #%d gave out %s again", idx+1, added)
+		}
+		seen[added] = true
+	}
+
+	if _, err := f.addPublicIP("WA1TESTWEB01", ip); err == nil {
+		t.Fatalf("addPublicIP succeeded with every address in use")
+	}
+
+	if _, err := f.deletePublicIP("WA1TESTWEB01", "203.0.113.7"); err != nil {
+		t.Fatalf("deletePublicIP: %s", err.Error())
+	}
+	if _, err := f.addPublicIP("WA1TESTWEB01", ip); err != nil {
+		t.Fatalf("addPublicIP after a delete: %s", err.Error())
+	}
+	if added, _ := f.inspectPublicIP("WA1TESTWEB01", "203.0.113.7"); added == nil {
+		t.Errorf("the freed address was not given out again")
+	}
+}
+
+// --drain of two servers in one pool: one update, waited for before either server is powered off
+func TestFakeDrainServers(t *testing.T) {
+	app, f := newFakeApp(t)
+	f.configure(WithJobs(NewJobTracker(testJobOptions)))
+	groupID, _ := f.AddGroup("WA1", "", "web", "")
+	for idx, ip := range []string{"10.0.0.11", "10.0.0.12"} {
+		server := ServerDetails{ServerID: fmt.Sprintf("WA1TESTWEB%02d", idx+1), GroupID: groupID, PrivateIPs: []string{ip}}
+		if err := f.AddServer(server); err != nil {
+			t.Fatalf("AddServer: %s", err.Error())
+		}
+	}
+
+	mustRun(t, app, "LB create WA1 web frontends")
+	lbid := app.vars["lb.lbid"]
+	mustRun(t, app, "pool create WA1 $last port=80 nodes=10.0.0.11,10.0.0.12,10.0.0.99")
+
+	f.SetOperationPolls(1)
+	mustRun(t, app, "server poweroff WA1TESTWEB01 WA1TESTWEB02 --drain")
+
+	if n := f.CallCount("updatePool"); n != 1 {
+		t.Errorf("CallCount(updatePool) = %d, want 1", n)
+	}
+	lb, _ := f.inspectLB("WA1", lbid)
+	if (len(lb.Pools[0].Nodes) != 1) || (lb.Pools[0].Nodes[0].TargetIP != "10.0.0.99") {
+		t.Errorf("pool nodes after the drain = %v", lb.Pools[0].Nodes)
+	}
+
+	var drained *Job
+	jobs := f.jobTracker().List(true)
+	for idx := range jobs {
+		if (jobs[idx].Kind == JOB_LB_REQUEST) && strings.HasPrefix(jobs[idx].Description, "update pool") {
+			drained = &jobs[idx]
+		}
+	}
+	if (drained == nil) || (drained.State != JOB_SUCCEEDED) {
+		t.Fatalf("the pool update was not waited for, jobs = %+v", jobs)
+	}
+	for _, job := range jobs {
+		if (job.Kind == JOB_OPERATION) && job.Started.Before(*drained.Finished) {
+			t.Errorf("%s started before the pool update was over", job.Description)
+		}
+	}
+}
+
+// the networks are looked up only for a pool with nodes, and a failed lookup doesn't stop the pool
+func TestPoolNetworkCheck(t *testing.T) {
+	app, f := newFakeApp(t)
+	mustRun(t, app, "LB create WA1 web frontends")
+	lbid := app.vars["lb.lbid"]
+
+	mustRun(t, app, "pool create WA1 "+lbid+" port=80")
+	if n := f.CallCount("listNetworks"); n != 0 {
+		t.Errorf("CallCount(listNetworks) = %d for a pool without nodes, want 0", n)
+	}
+
+	f.FailNext("listNetworks", 500)
+	mustRun(t, app, "pool create WA1 "+lbid+" port=443 nodes=10.0.0.1")
+	if n := f.CallCount("listNetworks"); n != 1 {
+		t.Errorf("CallCount(listNetworks) = %d, want 1", n)
+	}
+
+	lb, _ := f.inspectLB("WA1", lbid)
+	if len(lb.Pools) != 2 {
+		t.Errorf("%d pools, want 2", len(lb.Pools))
+	}
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+//// firewall policies, /v2-experimental/firewallPolicies/{acct}/{dc} for those within a datacenter
+//// and /v2-experimental/crossDcFirewallPolicies/{acct}/{dc} for those between two.  Changes take
+//// effect at once, nothing to wait for.
+
+type firewallPolicyJSON struct {
+	ID                 string   `json:"id,omitempty"`
+	Status             string   `json:"status,omitempty"`
+	Enabled            bool     `json:"enabled"`
+	Source             []string `json:"source"`
+	Destination        []string `json:"destination"`
+	DestinationAccount string   `json:"destinationAccount,omitempty"`
+	Ports              []string `json:"ports"`
+	Links              v2Links  `json:"links,omitempty"`
+}
+
+// the API names the far side destinationAccountId/destinationLocationId in requests, and
+// destinationAccount/destinationLocation in what it returns
+type crossDCFirewallPolicyJSON struct {
+	ID                    string  `json:"id,omitempty"`
+	Status                string  `json:"status,omitempty"`
+	Enabled               bool    `json:"enabled"`
+	SourceCIDR            string  `json:"sourceCidr"`
+	SourceAccount         string  `json:"sourceAccount,omitempty"`
+	SourceLocation        string  `json:"sourceLocation,omitempty"`
+	DestinationCIDR       string  `json:"destinationCidr"`
+	DestinationAccount    string  `json:"destinationAccount,omitempty"`
+	DestinationLocation   string  `json:"destinationLocation,omitempty"`
+	DestinationAccountID  string  `json:"destinationAccountId,omitempty"`
+	DestinationLocationID string  `json:"destinationLocationId,omitempty"`
+	Links                 v2Links `json:"links,omitempty"`
+}
+
+func firewallToJSON(policy *FirewallPolicy) *firewallPolicyJSON {
+	return &firewallPolicyJSON{
+		Enabled:            policy.Enabled,
+		Source:             policy.Sources,
+		Destination:        policy.Destinations,
+		DestinationAccount: policy.DestinationAccount,
+		Ports:              policy.Ports,
+	}
+}
+
+func firewallFromJSON(src *firewallPolicyJSON, dc string) *FirewallPolicy {
+	return &FirewallPolicy{
+		PolicyID:           src.ID,
+		DataCenter:         dc,
+		Status:             src.Status,
+		Enabled:            src.Enabled,
+		Sources:            append([]string{}, src.Source...),
+		Destinations:       append([]string{}, src.Destination...),
+		DestinationAccount: src.DestinationAccount,
+		Ports:              append([]string{}, src.Ports...),
+	}
+}
+
+func crossDCFirewallToJSON(policy *FirewallPolicy) *crossDCFirewallPolicyJSON {
+	ret := &crossDCFirewallPolicyJSON{
+		Enabled:               policy.Enabled,
+		DestinationAccountID:  policy.DestinationAccount,
+		DestinationLocationID: policy.DestinationDC,
+	}
+	if len(policy.Sources) > 0 {
+		ret.SourceCIDR = policy.Sources[0]
+	}
+	if len(policy.Destinations) > 0 {
+		ret.DestinationCIDR = policy.Destinations[0]
+	}
+	return ret
+}
+
+func crossDCFirewallFromJSON(src *crossDCFirewallPolicyJSON, dc string) *FirewallPolicy {
+	return &FirewallPolicy{
+		PolicyID:           src.ID,
+		DataCenter:         dc,
+		CrossDC:            true,
+		Status:             src.Status,
+		Enabled:            src.Enabled,
+		Sources:            []string{src.SourceCIDR},
+		Destinations:       []string{src.DestinationCIDR},
+		DestinationAccount: strings.ToUpper(src.DestinationAccount),
+		DestinationDC:      strings.ToUpper(src.DestinationLocation),
+		Ports:              make([]string, 0),
+	}
+}
+
+func (clc *clcImpl) firewallURI(dc string, crossDC bool, policyID string) string {
+	resource := "firewallPolicies"
+	if crossDC {
+		resource = "crossDcFirewallPolicies"
+	}
+
+	uri := fmt.Sprintf("/v2-experimental/%s/%s/%s", resource, clc.creds.GetAccount(), dc)
+	if policyID != "" {
+		uri += "/" + policyID
+	}
+	return uri
+}
+
+//////////////// clc method: listFirewallPolicies()
+
+func (clc *clcImpl) listFirewallPolicies(dc string, crossDC bool) ([]FirewallPolicy, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	cfg := clc.config()
+	ret := make([]FirewallPolicy, 0)
+
+	if crossDC {
+		apiret := make([]crossDCFirewallPolicyJSON, 0)
+		err := simpleGET(cfg, cfg.serverAPIV2, clc.firewallURI(dc, true, ""), clc.creds, &apiret)
+		if err != nil {
+			return nil, err
+		}
+
+		for idx := range apiret {
+			ret = append(ret, *crossDCFirewallFromJSON(&apiret[idx], dc))
+		}
+		return ret, nil
+	}
+
+	apiret := make([]firewallPolicyJSON, 0)
+	err := simpleGET(cfg, cfg.serverAPIV2, clc.firewallURI(dc, false, ""), clc.creds, &apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range apiret {
+		ret = append(ret, *firewallFromJSON(&apiret[idx], dc))
+	}
+	return ret, nil
+}
+
+//////////////// clc method: inspectFirewallPolicy()
+
+func (clc *clcImpl) inspectFirewallPolicy(dc string, crossDC bool, policyID string) (*FirewallPolicy, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	cfg := clc.config()
+
+	if crossDC {
+		apiret := &crossDCFirewallPolicyJSON{}
+		err := simpleGET(cfg, cfg.serverAPIV2, clc.firewallURI(dc, true, policyID), clc.creds, apiret)
+		if err != nil {
+			return nil, err
+		}
+		return crossDCFirewallFromJSON(apiret, dc), nil
+	}
+
+	apiret := &firewallPolicyJSON{}
+	err := simpleGET(cfg, cfg.serverAPIV2, clc.firewallURI(dc, false, policyID), clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+	return firewallFromJSON(apiret, dc), nil
+}
+
+//////////////// clc method: createFirewallPolicy()
+
+// an intra-DC create answers with just a link to the new policy, a cross-DC one with the policy
+func (clc *clcImpl) createFirewallPolicy(dc string, policy *FirewallPolicy) (string, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return "", errDC
+	}
+
+	if err := ValidateFirewallPolicy(policy); err != nil {
+		return "", err
+	}
+
+	cfg := clc.config()
+
+	if policy.CrossDC {
+		if policy.DestinationDC, errDC = clc.checkDC(policy.DestinationDC); errDC != nil {
+			return "", errDC
+		}
+
+		apiret := &crossDCFirewallPolicyJSON{}
+		err := marshalledPOST(cfg, cfg.serverAPIV2, clc.firewallURI(dc, true, ""), clc.creds, crossDCFirewallToJSON(policy), apiret)
+		if err != nil {
+			return "", err
+		}
+		return apiret.ID, nil
+	}
+
+	apiret := &firewallPolicyJSON{}
+	err := marshalledPOST(cfg, cfg.serverAPIV2, clc.firewallURI(dc, false, ""), clc.creds, firewallToJSON(policy), apiret)
+	if err != nil {
+		return "", err
+	}
+
+	for _, link := range apiret.Links {
+		if link.Rel == "self" {
+			return path.Base(link.Href), nil
+		}
+	}
+	return "", makeErrorOld("firewall policy created, but the response has no link to it")
+}
+
+//////////////// clc method: updateFirewallPolicy()
+
+func (clc *clcImpl) updateFirewallPolicy(dc string, policy *FirewallPolicy) error {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return errDC
+	}
+
+	if err := ValidateFirewallPolicy(policy); err != nil {
+		return err
+	}
+
+	cfg := clc.config()
+
+	if policy.CrossDC {
+		uri := fmt.Sprintf("%s?enabled=%t", clc.firewallURI(dc, true, policy.PolicyID), policy.Enabled)
+		return invokeHTTP(cfg, "PUT", cfg.serverAPIV2, uri, clc.creds, nil, nil)
+	}
+
+	return marshalledPUT(cfg, cfg.serverAPIV2, clc.firewallURI(dc, false, policy.PolicyID), clc.creds, firewallToJSON(policy), nil)
+}
+
+//////////////// clc method: deleteFirewallPolicy()
+
+func (clc *clcImpl) deleteFirewallPolicy(dc string, crossDC bool, policyID string) error {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return errDC
+	}
+
+	cfg := clc.config()
+	return simpleDELETE(cfg, cfg.serverAPIV2, clc.firewallURI(dc, crossDC, policyID), clc.creds, nil)
+}
+
+//////////////// helpers
+
+// ValidateFirewallPolicy checks the CIDRs and ports, and normalises the ports to the API's form
+// ("TCP/80" -> "tcp/80").  The API answers a bad policy with a bare 400, this says what is wrong.
+func ValidateFirewallPolicy(policy *FirewallPolicy) error {
+	if (len(policy.Sources) == 0) || (len(policy.Destinations) == 0) {
+		return fmt.Errorf("a firewall policy needs sources and destinations")
+	}
+
+	for _, cidr := range append(append([]string{}, policy.Sources...), policy.Destinations...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR %q", cidr)
+		}
+	}
+
+	if policy.CrossDC {
+		if (len(policy.Sources) != 1) || (len(policy.Destinations) != 1) {
+			return fmt.Errorf("a cross-DC firewall policy has one source and one destination CIDR")
+		} else if len(policy.Ports) > 0 {
+			return fmt.Errorf("a cross-DC firewall policy has no ports, it allows all traffic")
+		}
+
+		policy.DestinationDC = NormalizeDC(policy.DestinationDC)
+		if policy.DestinationDC == "" {
+			return fmt.Errorf("a cross-DC firewall policy needs a destination datacenter")
+		}
+		return nil
+	}
+
+	if len(policy.Ports) == 0 {
+		return fmt.Errorf("a firewall policy needs ports, e.g. any or tcp/80,tcp/443")
+	}
+
+	for idx, spec := range policy.Ports {
+		port, err := NormalizeFirewallPort(spec)
+		if err != nil {
+			return err
+		}
+		policy.Ports[idx] = port
+	}
+
+	return nil
+}
+
+// NormalizeFirewallPort takes "any" or a ParsePortSpec spec, and returns it as the API wants it
+func NormalizeFirewallPort(spec string) (string, error) {
+	if strings.EqualFold(strings.TrimSpace(spec), "any") {
+		return "any", nil
+	}
+
+	port, err := ParsePortSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(port.String()), nil
+}
@@ -23,29 +23,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
+	"sync"
+	"time"
 )
 
-//// requests honor this state, no need to pass in with every call
-var bCloseConnections = true
-var bDebugRequests = true
-var bDebugResponses = true
-
-func SetCloseConnectionMode(b bool) {
-	bCloseConnections = b
-}
-
-func SetDebugRequestMode(b bool) {
-	bDebugRequests = b
-}
-
-func SetDebugResponseMode(b bool) {
-	bDebugResponses = b
-}
+// every call goes out through the client's transport (see clcConfig), this one unless told otherwise
+func networkTransport() http.RoundTripper {
+	// this should be the normal code
+	//	return http.DefaultTransport
 
-func sdkLog(s string) { 	// formerly the gateway to glog.Info
-	fmt.Println(s)
+	// instead, we have this which tolerates bad certs
+	tlscfg := &tls.Config{InsecureSkipVerify: true} // true means to skip the verification
+	return &http.Transport{TLSClientConfig: tlscfg}
+	// end of tolerating bad certs.  Do not keep this code - it allows MITM etc. attacks
 }
 
 //// most funcs here return HttpError, which is an error
@@ -56,6 +49,8 @@ const ( // HttpError codes when the error occurred here, not in the remote call.
 	HTTP_ERROR_CLIENT    = 2
 	HTTP_ERROR_NOREQUEST = 3
 	HTTP_ERROR_JSON      = 4
+	HTTP_ERROR_BADDC     = 5 // not one of the account's datacenters, see ValidateDC
+	HTTP_ERROR_NOSERVICE = 6 // the datacenter doesn't offer the service, see checkLBService
 )
 
 type HttpError interface {
@@ -99,34 +94,66 @@ func makeError(msg string, code int, chain error) HttpError {
 	}
 }
 
-//// Credentials is returned from the login func, and used by everything else
+//// Credentials is returned from the login func, and used by everything else.  Safe for concurrent
+//// use through its methods; the fields are only set directly when creating one.
 type Credentials struct {
 	Username      string
 	Password      string // kept because we need reauth, especially when a token expires
 	AccountAlias  string
 	LocationAlias string // do we need this?
 	BearerToken   string
+
+	mu       sync.Mutex // guards the fields above
+	reauthMu sync.Mutex // one reauth at a time, see refresh()
 }
 
 func (obj *Credentials) GetUsername() string {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
 	return obj.Username
 }
 
 func (obj *Credentials) GetAccount() string {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
 	return obj.AccountAlias
 }
 
 func (obj *Credentials) GetLocation() string {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
 	return obj.LocationAlias
 }
 
 func (obj *Credentials) IsValid() bool {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
 	return (obj.AccountAlias != "") && (obj.BearerToken != "")
 }
 
-// and no GetBearerToken or GetPassword - keep them private within this file
+func (obj *Credentials) bearerToken() string {
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	return obj.BearerToken
+}
+
+// and no GetBearerToken or GetPassword - keep them private within this file.  This is the only
+// way a token leaves, and only when asked for ("auth token"), never through the logs.
+func (obj *Credentials) exportEnv() string {
+	if !obj.IsValid() {
+		return ""
+	}
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+	return fmt.Sprintf("export CLC_API_TOKEN=%s\nexport CLC_API_USERNAME=%s\nexport CLC_API_ACCOUNT=%s\nexport CLC_API_LOCATION=%s\n",
+		obj.BearerToken, obj.Username, obj.AccountAlias, obj.LocationAlias)
+}
 
 func (obj *Credentials) ClearCredentials() { // creds object is useless after this
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
 	obj.Username = ""
 	obj.Password = ""
 	obj.AccountAlias = ""
@@ -145,7 +172,7 @@ func makeErrorOld(content string) error {
 var dummyCreds = Credentials{Username: "dummy object passed by login proc and not used", Password: "no password here",
 	AccountAlias: "invalid", LocationAlias: "invalid", BearerToken: "invalid"} // note dummyCreds.IsValid() is true
 
-func GetCredentials(server, uri string, username, password string) (*Credentials, HttpError) {
+func GetCredentials(cfg *clcConfig, server, uri string, username, password string) (*Credentials, HttpError) {
 	if (username == "") || (password == "") {
 		return nil, makeError("username and/or password not provided", HTTP_ERROR_NOCREDS, nil)
 	}
@@ -155,14 +182,13 @@ func GetCredentials(server, uri string, username, password string) (*Credentials
 
 	authresp := AuthLoginResponseJSON{}
 
-	err := invokeHTTP("POST", server, uri, &dummyCreds, b, &authresp)
+	err := invokeHTTP(cfg, "POST", server, uri, &dummyCreds, b, &authresp)
 	if err != nil {
-		sdkLog("CLC failed to log in")
+		cfg.logger.Warn("CLC failed to log in", "username", username, "err", err.Error())
 		return nil, err
 	}
 
-	sdkLog(fmt.Sprintf("assigning new token, do this:  export CLC_API_TOKEN=%s\n", authresp.BearerToken))
-	sdkLog(fmt.Sprintf("also CLC_API_USERNAME=%s  CLC_API_ACCOUNT=%s  CLC_API_LOCATION=%s\n", authresp.Username, authresp.AccountAlias, authresp.LocationAlias))
+	cfg.logger.Info("logged in", "username", authresp.Username, "account", authresp.AccountAlias, "location", authresp.LocationAlias)
 
 	return &Credentials{
 		Username:      authresp.Username,
@@ -173,26 +199,47 @@ func GetCredentials(server, uri string, username, password string) (*Credentials
 	}, nil
 }
 
-func ReauthCredentials(creds *Credentials, server, uri string) error {
-	creds.AccountAlias = ""
-	creds.LocationAlias = ""
-	creds.BearerToken = ""
+// logs in again whatever the current token is
+func ReauthCredentials(cfg *clcConfig, creds *Credentials, server, uri string) error {
+	return creds.refresh(cfg, server, uri, creds.bearerToken())
+}
+
+// refresh logs in again if the token is still staleToken.  When many calls get 401 together they all
+// come here, the first does the login and the others find a new token already in place.
+func (obj *Credentials) refresh(cfg *clcConfig, server, uri string, staleToken string) error {
+	obj.reauthMu.Lock()
+	defer obj.reauthMu.Unlock()
 
-	body := fmt.Sprintf("{\"username\":\"%s\",\"password\":\"%s\"}", creds.Username, creds.Password)
+	obj.mu.Lock()
+	current, username, password := obj.BearerToken, obj.Username, obj.Password
+	obj.mu.Unlock()
+
+	if (current != staleToken) || (password == "") { // already renewed, or logged out, or came from a CLC_API_TOKEN
+		return nil
+	}
+
+	body := fmt.Sprintf("{\"username\":\"%s\",\"password\":\"%s\"}", username, password)
 	b := bytes.NewBufferString(body)
 
 	authresp := AuthLoginResponseJSON{}
 
-	err := invokeHTTP("POST", server, uri, &dummyCreds, b, &authresp)
-	if err != nil {
+	err := invokeHTTP(cfg, "POST", server, uri, &dummyCreds, b, &authresp)
+
+	obj.mu.Lock()
+	defer obj.mu.Unlock()
+
+	if err != nil { // the old token is no good either, so creds are invalid from now on
+		obj.AccountAlias = ""
+		obj.LocationAlias = ""
+		obj.BearerToken = ""
 		return err
 	}
 
-	sdkLog(fmt.Sprintf("assigning new token, do this:  export CLC_API_TOKEN=%s\n", authresp.BearerToken))
+	cfg.logger.Info("token renewed", "username", username, "account", authresp.AccountAlias)
 
-	creds.AccountAlias = authresp.AccountAlias
-	creds.LocationAlias = authresp.LocationAlias
-	creds.BearerToken = authresp.BearerToken
+	obj.AccountAlias = authresp.AccountAlias
+	obj.LocationAlias = authresp.LocationAlias
+	obj.BearerToken = authresp.BearerToken
 
 	return nil
 }
@@ -211,50 +258,50 @@ type AuthLoginResponseJSON struct {
 }
 
 // no request message body sent.  Response body returned if ret is not nil
-func simpleGET(server, uri string, creds *Credentials, ret interface{}) HttpError {
-	return invokeHTTP("GET", server, uri, creds, nil, ret)
+func simpleGET(cfg *clcConfig, server, uri string, creds *Credentials, ret interface{}) HttpError {
+	return invokeHTTP(cfg, "GET", server, uri, creds, nil, ret)
 }
 
 // no request message body sent.  Response body returned if ret is not nil
-func simpleDELETE(server, uri string, creds *Credentials, ret interface{}) HttpError {
-	return invokeHTTP("DELETE", server, uri, creds, nil, ret)
+func simpleDELETE(cfg *clcConfig, server, uri string, creds *Credentials, ret interface{}) HttpError {
+	return invokeHTTP(cfg, "DELETE", server, uri, creds, nil, ret)
 }
 
 // body must be a json-annotated struct, and is marshalled into the request body
-func marshalledPOST(server, uri string, creds *Credentials, body interface{}, ret interface{}) HttpError {
+func marshalledPOST(cfg *clcConfig, server, uri string, creds *Credentials, body interface{}, ret interface{}) HttpError {
 	b := new(bytes.Buffer)
 	err := json.NewEncoder(b).Encode(body)
 	if err != nil {
 		return makeError("JSON marshalling failed", HTTP_ERROR_JSON, err)
 	}
 
-	return invokeHTTP("POST", server, uri, creds, b, ret)
+	return invokeHTTP(cfg, "POST", server, uri, creds, b, ret)
 }
 
 
 // body must be a json-annotated struct, and is marshalled into the request body
-func marshalledPUT(server, uri string, creds *Credentials, body interface{}, ret interface{}) HttpError {
+func marshalledPUT(cfg *clcConfig, server, uri string, creds *Credentials, body interface{}, ret interface{}) HttpError {
 	b := new(bytes.Buffer)
 	err := json.NewEncoder(b).Encode(body)
 	if err != nil {
 		return makeError("JSON marshalling failed", HTTP_ERROR_JSON, err)
 	}
 
-	return invokeHTTP("PUT", server, uri, creds, b, ret)
+	return invokeHTTP(cfg, "PUT", server, uri, creds, b, ret)
 }
 
 // body is a JSON string, sent directly as the request body
-func simplePOST(server, uri string, creds *Credentials, body string, ret interface{}) HttpError {
+func simplePOST(cfg *clcConfig, server, uri string, creds *Credentials, body string, ret interface{}) HttpError {
 	b := bytes.NewBufferString(body)
-	return invokeHTTP("POST", server, uri, creds, b, ret)
+	return invokeHTTP(cfg, "POST", server, uri, creds, b, ret)
 }
 
 // method to be "GET", "POST", etc.
-// server name "api.ctl.io" or "api.loadbalancer.ctl.io"
+// server name "api.ctl.io" or "api.loadbalancer.ctl.io" (cfg.serverAPIV2 or cfg.serverLB)
 // uri always starts with /   (we assemble https://<server><uri>)
 // creds required for anything except the login call
 // body may be be nil
-func invokeHTTP(method, server, uri string, creds *Credentials, body io.Reader, ret interface{}) HttpError {
+func invokeHTTP(cfg *clcConfig, method, server, uri string, creds *Credentials, body io.Reader, ret interface{}) HttpError {
 	if (creds == nil) || !creds.IsValid() {
 		return makeError("username and/or password not provided", HTTP_ERROR_NOCREDS, nil)
 	}
@@ -270,80 +317,181 @@ func invokeHTTP(method, server, uri string, creds *Credentials, body io.Reader,
 	req.Header.Add("Host", server) // the reason we take server and uri separately
 	req.Header.Add("Accept", "application/json")
 
+	if cfg.userAgent != "" {
+		req.Header.Set("User-Agent", cfg.userAgent)
+	}
+
 	isAuth := (creds == &dummyCreds)
+	token := creds.bearerToken() // the one this call sends, if it gets 401 then it's stale
 	if !isAuth { // the login proc itself doesn't send an auth header
-		req.Header.Add("Authorization", ("Bearer " + creds.BearerToken))
+		req.Header.Add("Authorization", ("Bearer " + token))
 	}
 
-	if bCloseConnections {
+	if cfg.closeConnections {
 		req.Header.Add("Connection", "close")
 	}
 
-	if bDebugRequests {
-		if isAuth {	// avoid writing username/password to the log
-			sdkLog(fmt.Sprintf("auth request: %s", full_url))
-		} else {
-			v, _ := httputil.DumpRequestOut(req, true)
-			sdkLog(string(v))
+	cacheKey, cached := "", (*cacheEntry)(nil)
+	if (cfg.cache != nil) && (method == "GET") {
+		if ttl := cfg.cache.ttlFor(cfg, server, uri); ttl > 0 {
+			cacheKey = server + uri
+
+			entry, fresh := cfg.cache.lookup(server, cacheKey, ttl)
+			if fresh {
+				return decodeResponse(cfg, method, full_url, []byte(entry.Body), ret)
+			}
+			if (entry != nil) && (entry.ETag != "") {
+				cached = entry
+				req.Header.Set("If-None-Match", entry.ETag)
+			}
 		}
+	} else if (cfg.cache != nil) && !isAuth {
+		defer cfg.cache.invalidate(server) // after the change, whether or not it worked
 	}
 
-	// this should be the normal code
-	//	resp,err := http.DefaultClient.Do(req)	// execute the call
-
-	// instead, we have this which tolerates bad certs
-	tlscfg := &tls.Config{InsecureSkipVerify: true} // true means to skip the verification
-	transp := &http.Transport{TLSClientConfig: tlscfg}
-	client := &http.Client{Transport: transp}
-	resp, err := client.Do(req)
-	// end of tolerating bad certs.  Do not keep this code - it allows MITM etc. attacks
-
-	if bDebugResponses {
-		vv, _ := httputil.DumpResponse(resp, true)
-		sdkLog(string(vv))
-	}
+	client := &http.Client{Transport: cfg.roundTripper()}
 
-	if err != nil { // failed HTTP call
-		return makeError("HTTP call failed", HTTP_ERROR_CLIENT, err) // chain the err
+	resp, herr := sendWithRetry(cfg, client, req, isAuth)
+	if herr != nil {
+		return herr
 	}
+	defer func() { // whichever resp we end up with, none if the re-send failed
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}()
 
-	if resp.StatusCode == 401 { // Unauthorized.  Not a failure yet, perhaps we can reauth
+	if resp.StatusCode == 401 && !isAuth { // Unauthorized.  Not a failure yet, perhaps we can reauth
 
 		// nyi where to store auth server/uri?   In the creds object ?
-		ReauthCredentials(creds, "api.ctl.io", "/v2/authentication/login")
-		if creds.IsValid() {
+		creds.refresh(cfg, cfg.serverAPIV2, "/v2/authentication/login", token)
+		if creds.IsValid() && (creds.bearerToken() != token) {
+			token = creds.bearerToken()
 			req.Header.Del("Authorization")
-			req.Header.Add("Authorization", ("Bearer " + creds.BearerToken))
-
-			resp, err = client.Do(req) // not :=
+			req.Header.Add("Authorization", ("Bearer " + token))
+			resp.Body.Close()
+			if req.GetBody != nil { // the first send consumed the body
+				req.Body, _ = req.GetBody()
+			}
+
+			resp, herr = sendWithRetry(cfg, client, req, isAuth) // not :=
+			if herr != nil {
+				return herr
+			}
 		}
 	}
 
+	if (resp.StatusCode == 304) && (cached != nil) { // Not Modified, the cached body is current
+		cfg.cache.renew(cacheKey)
+		return decodeResponse(cfg, method, full_url, []byte(cached.Body), ret)
+	}
+
 	if (resp.StatusCode < 200) || (resp.StatusCode >= 300) { // Q: do we care to distinguish the various 200-series codes?
-		// stat := fmt.Sprintf("received HTTP response code %d\n", resp.StatusCode)
+		attrs := []any{"method", method, "url", full_url, "status", resp.StatusCode}
 
-		if !bDebugRequests {
-			sdkLog("dumping this request, after the fact")
+		if !cfg.debugRequests && !isAuth { // dumping this request, after the fact
+			if req.GetBody != nil { // the send consumed the body
+				req.Body, _ = req.GetBody()
+			}
 			v, _ := httputil.DumpRequestOut(req, true)
-			sdkLog(string(v))
+			attrs = append(attrs, "request", redactSecrets(string(v)))
 		}
 
-		if !bDebugResponses {
+		if !cfg.debugResponses {
 			vv, _ := httputil.DumpResponse(resp, true)
-			sdkLog(string(vv))
+			attrs = append(attrs, "response", redactSecrets(string(vv)))
 		}
 
+		cfg.logger.Warn("HTTP call failed", attrs...) // dumps redacted here, whatever handler the logger has
+
 		return makeError("HTTP call failed", resp.StatusCode, nil)
 	}
 
-	if ret != nil { // permit methods without a response body, or calls that ignore the body and just look for status
-		err = json.NewDecoder(resp.Body).Decode(ret)
+	if (ret == nil) && (cacheKey == "") { // permit methods without a response body, or calls that ignore the body and just look for status
+		return nil
+	}
 
-		if err != nil {
-			fmt.Println(fmt.Sprintf("JSON decode failed, err=%s", err.Error()))
-			return makeError("JSON decode failed", HTTP_ERROR_JSON, err)
-		}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return makeError("HTTP call failed", HTTP_ERROR_CLIENT, err)
+	}
+
+	if cacheKey != "" {
+		cfg.cache.store(cacheKey, resp.Header.Get("ETag"), data)
+	}
+
+	return decodeResponse(cfg, method, full_url, data, ret)
+}
+
+func decodeResponse(cfg *clcConfig, method, full_url string, data []byte, ret interface{}) HttpError {
+	if ret == nil {
+		return nil
+	}
+
+	err := json.Unmarshal(data, ret)
+	if err != nil {
+		cfg.logger.Error("JSON decode failed", "method", method, "url", full_url, "err", err.Error())
+		return makeError("JSON decode failed", HTTP_ERROR_JSON, err)
 	}
 
 	return nil // success
 }
+
+// sends req, again per cfg.retry if it is idempotent and fails to connect or gets a 5xx, or if it gets
+// a 429.  Every attempt waits its turn with the host's rate limiter.  The response returned is open,
+// earlier ones are closed.
+func sendWithRetry(cfg *clcConfig, client *http.Client, req *http.Request, isAuth bool) (*http.Response, HttpError) {
+	idempotent := (req.Method == "GET") || (req.Method == "PUT") || (req.Method == "DELETE")
+	delay := cfg.retry.Backoff
+	limiter := cfg.limits.forHost(cfg, req.URL.Host)
+	attempt, throttled := 1, 0
+
+	for sent := 0; ; sent++ {
+		if (sent > 0) && (req.GetBody != nil) { // an earlier attempt consumed the body
+			req.Body, _ = req.GetBody()
+		}
+
+		if cfg.debugRequests {
+			if isAuth { // avoid writing username/password to the log, even redacted
+				cfg.logger.Debug("auth request", "url", req.URL.String())
+			} else {
+				v, _ := httputil.DumpRequestOut(req, true)
+				cfg.logger.Debug("HTTP request", "method", req.Method, "url", req.URL.String(), "dump", redactSecrets(string(v)))
+			}
+		}
+
+		limiter.wait()
+		resp, err := client.Do(req)
+
+		if (err == nil) && cfg.debugResponses {
+			vv, _ := httputil.DumpResponse(resp, true)
+			cfg.logger.Debug("HTTP response", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "dump", redactSecrets(string(vv)))
+		}
+
+		if (err == nil) && limiter.observe(resp) && (throttled < cfg.retry.Throttled) {
+			throttled++
+			cfg.logger.Warn("HTTP call throttled, waiting", "method", req.Method, "url", req.URL.String(), "retryAfter", resp.Header.Get("Retry-After"))
+			resp.Body.Close()
+			continue // limiter.wait() does the waiting
+		}
+
+		retryable := (err != nil) || (resp.StatusCode >= 500)
+		if !idempotent || !retryable || (attempt >= cfg.retry.MaxAttempts) {
+			if err != nil { // failed HTTP call
+				return nil, makeError("HTTP call failed", HTTP_ERROR_CLIENT, err) // chain the err
+			}
+			return resp, nil
+		}
+
+		if err != nil {
+			cfg.logger.Warn("HTTP call failed, retrying", "method", req.Method, "url", req.URL.String(), "attempt", attempt, "err", err.Error())
+		} else {
+			cfg.logger.Warn("HTTP call failed, retrying", "method", req.Method, "url", req.URL.String(), "attempt", attempt, "status", resp.StatusCode)
+			resp.Body.Close()
+		}
+
+		attempt++
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
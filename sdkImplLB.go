@@ -18,29 +18,33 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 )
 
-//// api use involves calls to both addresses
+//// api use involves calls to both addresses.  These are the defaults, see WithServers
 var clcServer_API_V2 string = "api.ctl.io"               // URL form  https://api.ctl.io/v2/<resource>/<accountAlias>
 var clcServer_LB_BETA string = "api.loadbalancer.ctl.io" // URL form  https://api.loadbalancer.ctl.io/<accountAlias>/<datacenter>/loadbalancers
 
 //// auth methods
-func implClientLogin(username, password string) (CenturyLinkClient, error) {
+func implClientLogin(username, password string, opts ...ClientOption) (CenturyLinkClient, error) {
+	cfg := newClcConfig(append([]ClientOption{envServerOptions()}, opts...)...)
 
-	newcreds, err := GetCredentials(clcServer_API_V2, "/v2/authentication/login", username, password)
+	newcreds, err := GetCredentials(cfg, cfg.serverAPIV2, "/v2/authentication/login", username, password)
 	if err != nil {
 		return nil, err
 	}
 
-	return clcImpl{
+	return &clcImpl{
+		cfg:   cfg,
 		creds: newcreds,
+		dcs:   &knownDCs{},
 	}, nil
 }
 
-func implClientFromEnv() (CenturyLinkClient, error) {
-
+func implClientFromEnv(opts ...ClientOption) (CenturyLinkClient, error) {
 	envUsername := os.Getenv("CLC_API_USERNAME")
 	envAccount := os.Getenv("CLC_API_ACCOUNT")
 	envLocation := os.Getenv("CLC_API_LOCATION")
@@ -49,31 +53,75 @@ func implClientFromEnv() (CenturyLinkClient, error) {
 	if (envUsername == "") || (envAccount == "") || (envLocation == "") || (envToken == "") {
 		envPassword := os.Getenv("CLC_API_PASSWORD")
 		if (envPassword == "") || (envUsername == "") {
-			fmt.Printf("user=%s, pass=%s, acct=%s, loc=%s, token=%s\n", envUsername, envPassword, envAccount, envLocation, envToken)
+			fmt.Printf("user=%s, acct=%s, loc=%s, password set=%t, token set=%t\n", envUsername, envAccount, envLocation, envPassword != "", envToken != "")
 			return nil, makeErrorOld("CLC auth not set in env")
 		}
 
-		return implClientLogin(envUsername, envPassword)
+		return implClientLogin(envUsername, envPassword, opts...)
 	}
 
+	cfg := newClcConfig(append([]ClientOption{envServerOptions()}, opts...)...)
 	newcreds := &Credentials{Username: envUsername, AccountAlias: envAccount, LocationAlias: envLocation, BearerToken: envToken}
-	return clcImpl{creds: newcreds}, nil
+	return &clcImpl{cfg: cfg, creds: newcreds, dcs: &knownDCs{}}, nil
 }
 
 //// clcImpl is the internal layer that knows what HTTP calls to make
 
+//// safe for concurrent use:  cfg is never changed in place, configure() swaps in a new one and calls
+//// already running keep the one they started with.  Credentials does its own locking, including a
+//// single login when many calls get 401 at once.
 type clcImpl struct { // implements CenturyLinkClient
+	mu    sync.RWMutex // guards cfg
+	cfg   *clcConfig
 	creds *Credentials
+	dcs   *knownDCs // shared with the uncached() copy
+}
+
+// checkDC normalises dc and makes sure the account has it, see sdkDatacenter.go
+func (clc *clcImpl) checkDC(dc string) (string, HttpError) {
+	id, err := clc.dcs.check(dc, clc.listAllDC)
+	if err != nil {
+		if httpErr, ok := err.(HttpError); ok {
+			return "", httpErr
+		}
+		return "", makeError(err.Error(), HTTP_ERROR_UNKNOWN, err)
+	}
+
+	return id, nil
+}
+
+func (clc *clcImpl) config() *clcConfig {
+	clc.mu.RLock()
+	defer clc.mu.RUnlock()
+	return clc.cfg
+}
+
+func (clc *clcImpl) uncached() CenturyLinkClient {
+	cfg := *clc.config()
+	cfg.cache = nil
+	return &clcImpl{cfg: &cfg, creds: clc.creds, dcs: clc.dcs}
 }
 
-func (clc clcImpl) logout() {
+func (clc *clcImpl) getStats() []HostStats {
+	return clc.config().limits.stats()
+}
+
+func (clc *clcImpl) configure(opts ...ClientOption) {
+	clc.mu.Lock()
+	defer clc.mu.Unlock()
+
+	cfg := *clc.cfg
+	cfg.apply(opts...)
+	clc.cfg = &cfg
+}
+
+func (clc *clcImpl) logout() {
 	if clc.creds != nil {
-		clc.creds.ClearCredentials()
-		clc.creds = nil
+		clc.creds.ClearCredentials() // calls still running, or made later, fail with HTTP_ERROR_NOCREDS
 	}
 }
 
-func (clc clcImpl) hasCredentials() bool {
+func (clc *clcImpl) hasCredentials() bool {
 	if clc.creds != nil {
 		return clc.creds.IsValid()
 	}
@@ -81,8 +129,16 @@ func (clc clcImpl) hasCredentials() bool {
 	return false
 }
 
+func (clc *clcImpl) exportEnv() string {
+	if clc.creds != nil {
+		return clc.creds.exportEnv()
+	}
+
+	return ""
+}
+
 // inconsistent style - are methods supposed to start with capital or not?
-func (clc clcImpl) getUsername() string {
+func (clc *clcImpl) getUsername() string {
 	if clc.creds != nil {
 		return clc.creds.GetUsername()
 	}
@@ -90,7 +146,7 @@ func (clc clcImpl) getUsername() string {
 	return ""
 }
 
-func (clc clcImpl) getAccountAlias() string {
+func (clc *clcImpl) getAccountAlias() string {
 	if clc.creds != nil {
 		return clc.creds.GetAccount()
 	}
@@ -105,12 +161,13 @@ type dcNamesJSON struct {
 	Name string `json:"name"` // NB: capitalizing Name is required in Go
 }
 
-func (clc clcImpl) listAllDC() ([]DataCenterName, error) {
+func (clc *clcImpl) listAllDC() ([]DataCenterName, error) {
 
 	uri := fmt.Sprintf("/v2/datacenters/%s", clc.creds.GetAccount())
 	dcret := make([]*dcNamesJSON, 0)
 
-	err := simpleGET(clcServer_API_V2, uri, clc.creds, &dcret)
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, &dcret)
 	if err != nil {
 		return nil, err
 	}
@@ -142,12 +199,13 @@ type lbListingWrapperJSON struct {
 	Values []lbListingDetailsJSON `json:"values"`
 }
 
-func (clc clcImpl) listAllLB() ([]LoadBalancerSummary, error) {
+func (clc *clcImpl) listAllLB() ([]LoadBalancerSummary, error) {
 
 	uri := fmt.Sprintf("/%s/loadbalancers", clc.creds.GetAccount())
 	apiret := &lbListingWrapperJSON{}
 
-	err := simpleGET(clcServer_LB_BETA, uri, clc.creds, &apiret)
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverLB, uri, clc.creds, &apiret)
 	if err != nil {
 		return nil, err
 	}
@@ -166,6 +224,28 @@ func (clc clcImpl) listAllLB() ([]LoadBalancerSummary, error) {
 	return ret, nil
 }
 
+//////////////// clc method: lbServiceOffered()
+// the v2 capabilities only know the legacy shared LB.  The LB API itself answers 404 in a DC it isn't in.
+
+func (clc *clcImpl) lbServiceOffered(dc string) (bool, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return false, errDC
+	}
+
+	uri := fmt.Sprintf("/%s/%s/loadbalancers", clc.creds.GetAccount(), dc)
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverLB, uri, clc.creds, nil)
+	if err == nil {
+		return true, nil
+	} else if err.Code() == 404 {
+		return false, nil
+	}
+
+	return false, err
+}
+
 //////////////// clc method: createLB()
 
 type LinkJSON struct {
@@ -185,14 +265,23 @@ type lbCreateRequestJSON struct {
 	Links          ApiLinks `json:"links"`
 }
 
-func (clc clcImpl) createLB(dc string, lbname string, desc string) (*LoadBalancerCreationInfo, error) {
+func (clc *clcImpl) createLB(dc string, lbname string, desc string) (*LoadBalancerCreationInfo, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	if err := checkLBService(clc, dc); err != nil {
+		return nil, err
+	}
 
 	uri := fmt.Sprintf("/%s/%s/loadbalancers", clc.creds.GetAccount(), dc)
 	apiret := &lbCreateRequestJSON{}
 
 	body := fmt.Sprintf("{ \"name\":\"%s\", \"description\":\"%s\" }", lbname, desc)
 
-	err := simplePOST(clcServer_LB_BETA, uri, clc.creds, body, apiret)
+	cfg := clc.config()
+	err := simplePOST(cfg, cfg.serverLB, uri, clc.creds, body, apiret)
 
 	if err != nil {
 		return nil, err
@@ -201,6 +290,7 @@ func (clc clcImpl) createLB(dc string, lbname string, desc string) (*LoadBalance
 	return &LoadBalancerCreationInfo{
 		LBID:        findLinkLB(&apiret.Links, "loadbalancer"),
 		RequestTime: apiret.RequestDate,
+		Status:      apiret.Status,
 	}, nil
 }
 
@@ -244,12 +334,17 @@ type lbDetailsJSON struct {
 	Pools       ApiPools `json:"pools"`
 }
 
-func (clc clcImpl) inspectLB(dc, lbid string) (*LoadBalancerDetails, HttpError) {
+func (clc *clcImpl) inspectLB(dc, lbid string) (*LoadBalancerDetails, HttpError) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
 
 	uri := fmt.Sprintf("/%s/%s/loadbalancers/%s", clc.creds.GetAccount(), dc, lbid)
 	apiret := &lbDetailsJSON{}
 
-	err := simpleGET(clcServer_LB_BETA, uri, clc.creds, apiret)
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverLB, uri, clc.creds, apiret)
 	if err != nil {
 		return nil, err
 	}
@@ -335,12 +430,17 @@ func findLinkLB(links *ApiLinks, rel string) string {
 	return "" // not found, consider returning err?
 }
 
-func (clc clcImpl) deleteLB(dc, lbid string) (bool, error) {
+func (clc *clcImpl) deleteLB(dc, lbid string) (bool, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return false, errDC
+	}
 
 	uri := fmt.Sprintf("/%s/%s/loadbalancers/%s", clc.creds.GetAccount(), dc, lbid)
 	apiret := &lbDeleteJSON{}
 
-	err := simpleDELETE(clcServer_LB_BETA, uri, clc.creds, apiret)
+	cfg := clc.config()
+	err := simpleDELETE(cfg, cfg.serverLB, uri, clc.creds, apiret)
 	if err == nil { // ordinary success, LB was deleted
 		return true, nil
 	}
@@ -366,6 +466,7 @@ type PoolEntityJSON struct {
 	Persistence  string           `json:"persistence"`
 	TimeoutMS    int64            `json:"idleTimeout"`
 	Mode         string           `json:"loadBalancingMode"`
+	Health       *HealthCheckJSON `json:"healthCheck,omitempty"`
 	Nodes        []NodeEntityJSON `json:"nodes"`
 }
 
@@ -385,6 +486,17 @@ func pool_to_json(pool *PoolDetails) *PoolEntityJSON {
 		json_nodes = make([]NodeEntityJSON, 0, 0)
 	}
 
+	var json_health *HealthCheckJSON = nil
+	if pool.Health != nil {
+		json_health = &HealthCheckJSON{
+			UnhealthyThreshold: pool.Health.Unhealthy,
+			HealthyThreshold:   pool.Health.Healthy,
+			IntervalSeconds:    pool.Health.Interval,
+			TargetPort:         pool.Health.TargetPort,
+			Mode:               pool.Health.Mode,
+		}
+	}
+
 	return &PoolEntityJSON{
 		PoolID:       pool.PoolID,
 		IncomingPort: pool.IncomingPort,
@@ -392,6 +504,7 @@ func pool_to_json(pool *PoolDetails) *PoolEntityJSON {
 		Persistence:  pool.Persistence,
 		TimeoutMS:    pool.TimeoutMS,
 		Mode:         pool.Mode,
+		Health:       json_health,
 		Nodes:        json_nodes,
 	}
 }
@@ -405,13 +518,18 @@ type CreatePoolResponseJSON struct {
 	Links          ApiLinks `json:"links"` // Q: does the marshaling work if all we include is this one field?  All we need is links[rel="pool"].resourceID
 }
 
-func (clc clcImpl) createPool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) {
+func (clc *clcImpl) createPool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
 
 	uri := fmt.Sprintf("/%s/%s/loadbalancers/%s/pools", clc.creds.GetAccount(), dc, lbid)
 	pool_req := pool_to_json(newpool)
 
 	pool_resp := &CreatePoolResponseJSON{}
-	err := marshalledPOST(clcServer_LB_BETA, uri, clc.creds, pool_req, pool_resp)
+	cfg := clc.config()
+	err := marshalledPOST(cfg, cfg.serverLB, uri, clc.creds, pool_req, pool_resp)
 	if err != nil {
 		return nil, err
 	}
@@ -421,38 +539,120 @@ func (clc clcImpl) createPool(dc, lbid string, newpool *PoolDetails) (*PoolDetai
 		return nil, makeErrorOld("could not determine ID of new pool")
 	}
 
-	return clc.inspectPool(dc, lbid, poolID)
+	pool, errPool := clc.inspectPool(dc, lbid, poolID)
+	if errPool != nil {
+		return nil, errPool
+	}
+
+	pool.Request = &LBRequest{RequestID: pool_resp.RequestID, DataCenter: dc, Status: pool_resp.Status, Description: pool_resp.Description,
+		Href: findLinkHrefLB(&pool_resp.Links, "status", "self")}
+	return pool, nil
 }
 
 //////////////// clc method: updatePool()
-func (clc clcImpl) updatePool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) {
+func (clc *clcImpl) updatePool(dc, lbid string, newpool *PoolDetails) (*PoolDetails, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
 
 	uri := fmt.Sprintf("/%s/%s/loadbalancers/%s/pools/%s", clc.creds.GetAccount(),
 		dc, lbid, newpool.PoolID)
 
-	update_req := pool_to_json(newpool) // and ignore async-request return object
-	err := marshalledPUT(clcServer_LB_BETA, uri, clc.creds, update_req, nil)
+	update_req := pool_to_json(newpool)
+	apiret := &lbCreateRequestJSON{}
+	cfg := clc.config()
+	err := marshalledPUT(cfg, cfg.serverLB, uri, clc.creds, update_req, apiret)
 	if err != nil {
 		return nil, err
 	}
 
-	return clc.inspectPool(dc, lbid, newpool.PoolID)
+	pool, errPool := clc.inspectPool(dc, lbid, newpool.PoolID) // may not show the change until the request is COMPLETE
+	if errPool != nil {
+		return nil, errPool
+	}
+
+	pool.Request = lbRequestFromJSON(dc, apiret)
+	return pool, nil
 }
 
 //////////////// clc method: deletePool()
-func (clc clcImpl) deletePool(dc, lbid string, poolID string) error {
+func (clc *clcImpl) deletePool(dc, lbid string, poolID string) (*LBRequest, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
 
 	uri := fmt.Sprintf("/%s/%s/loadbalancers/%s/pools/%s", clc.creds.GetAccount(),
 		dc, lbid, poolID)
 
-	err := simpleDELETE(clcServer_LB_BETA, uri, clc.creds, nil)
-	return err // no other return body
+	apiret := &lbCreateRequestJSON{}
+	cfg := clc.config()
+	err := simpleDELETE(cfg, cfg.serverLB, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	return lbRequestFromJSON(dc, apiret), nil
+}
+
+//////////////// clc method: inspectLBRequest()
+// the docs show no path for a request of its own, so it is polled only where its links say.  Just the
+// link's path is used, always on the LB server, so that the token can't be sent anywhere else.
+
+func (clc *clcImpl) inspectLBRequest(req *LBRequest) (*LBRequest, error) {
+	if req.Href == "" {
+		return nil, makeError("request "+req.RequestID+" has no link to poll", HTTP_ERROR_NOREQUEST, nil)
+	}
+
+	uri := req.Href
+	if parsed, err := url.Parse(req.Href); (err == nil) && parsed.IsAbs() {
+		uri = parsed.RequestURI()
+	}
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri
+	}
+
+	apiret := &lbCreateRequestJSON{}
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverLB, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := lbRequestFromJSON(req.DataCenter, apiret)
+	if ret.RequestID == "" {
+		ret.RequestID = req.RequestID
+	}
+	if ret.Href == "" {
+		ret.Href = req.Href
+	}
+	return ret, nil
+}
+
+// the same request object comes back from every LB API change, whatever it changes.  Its id is the request's.
+func lbRequestFromJSON(dc string, apiret *lbCreateRequestJSON) *LBRequest {
+	return &LBRequest{RequestID: apiret.LBID, DataCenter: dc, Status: apiret.Status, Description: apiret.Description,
+		Href: findLinkHrefLB(&apiret.Links, "status", "self")}
+}
+
+// the href of the first of rels there is a link for, "" if none
+func findLinkHrefLB(links *ApiLinks, rels ...string) string {
+	for _, rel := range rels {
+		for _, link := range *links {
+			if (link.Rel == rel) && (link.Href != "") {
+				return link.Href
+			}
+		}
+	}
+
+	return ""
 }
 
 //////////////// clc method: inspectPool()
 // not actually part of the LBAAS interface at this time.  Synthesized by returning just part of the inspectLB response
 
-func (clc clcImpl) inspectPool(dc, lbid, poolid string) (*PoolDetails, error) {
+func (clc *clcImpl) inspectPool(dc, lbid, poolid string) (*PoolDetails, error) {
 
 	lbDetails, err := clc.inspectLB(dc, lbid)
 	if err != nil {
@@ -0,0 +1,230 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+//// networks, /v2-experimental/networks/{acct}/{dc}.  Claiming one is queued like the v2 server
+//// changes, releasing one is not.
+
+const ( // which of a network's addresses inspectNetwork returns
+	NETWORK_IPS_NONE    = "none"
+	NETWORK_IPS_CLAIMED = "claimed"
+	NETWORK_IPS_FREE    = "free"
+	NETWORK_IPS_ALL     = "all"
+)
+
+type networkIPJSON struct {
+	Address string `json:"address"`
+	Claimed bool   `json:"claimed"`
+	Server  string `json:"server,omitempty"`
+	Type    string `json:"type"`
+}
+
+type networkJSON struct {
+	ID          string          `json:"id"`
+	CIDR        string          `json:"cidr"`
+	Description string          `json:"description"`
+	Gateway     string          `json:"gateway"`
+	Name        string          `json:"name"`
+	Netmask     string          `json:"netmask"`
+	Type        string          `json:"type"`
+	VLAN        int             `json:"vlan"`
+	IPAddresses []networkIPJSON `json:"ipAddresses,omitempty"`
+	Links       v2Links         `json:"links"`
+}
+
+// what a claim answers with
+type networkClaimJSON struct {
+	OperationID string `json:"operationId"`
+	URI         string `json:"uri"`
+}
+
+func networkFromJSON(src *networkJSON, dc string) *NetworkDetails {
+	ret := &NetworkDetails{
+		NetworkID:   src.ID,
+		DataCenter:  dc,
+		Name:        src.Name,
+		Description: src.Description,
+		CIDR:        src.CIDR,
+		Gateway:     src.Gateway,
+		Netmask:     src.Netmask,
+		Type:        src.Type,
+		VLAN:        src.VLAN,
+	}
+
+	for _, ip := range src.IPAddresses {
+		ret.IPAddresses = append(ret.IPAddresses, NetworkIP{Address: ip.Address, Claimed: ip.Claimed, ServerID: ip.Server, Type: ip.Type})
+	}
+
+	return ret
+}
+
+func (clc *clcImpl) networkURI(dc, networkID string) string {
+	uri := fmt.Sprintf("/v2-experimental/networks/%s/%s", clc.creds.GetAccount(), dc)
+	if networkID != "" {
+		uri += "/" + networkID
+	}
+	return uri
+}
+
+//////////////// clc method: listNetworks()
+
+func (clc *clcImpl) listNetworks(dc string) ([]NetworkDetails, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	apiret := make([]networkJSON, 0)
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, clc.networkURI(dc, ""), clc.creds, &apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]NetworkDetails, len(apiret))
+	for idx := range apiret {
+		ret[idx] = *networkFromJSON(&apiret[idx], dc)
+	}
+
+	return ret, nil
+}
+
+//////////////// clc method: inspectNetwork()
+
+func (clc *clcImpl) inspectNetwork(dc, networkID, ipAddresses string) (*NetworkDetails, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	uri := fmt.Sprintf("%s?ipAddresses=%s", clc.networkURI(dc, networkID), ipAddresses)
+	apiret := &networkJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	return networkFromJSON(apiret, dc), nil
+}
+
+//////////////// clc method: claimNetwork()
+
+func (clc *clcImpl) claimNetwork(dc string) (string, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return "", errDC
+	}
+
+	apiret := &networkClaimJSON{}
+
+	cfg := clc.config()
+	err := simplePOST(cfg, cfg.serverAPIV2, clc.networkURI(dc, "claim"), clc.creds, "", apiret)
+	if err != nil {
+		return "", err
+	}
+
+	return apiret.OperationID, nil
+}
+
+//////////////// clc method: releaseNetwork()
+
+func (clc *clcImpl) releaseNetwork(dc, networkID string) error {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return errDC
+	}
+
+	cfg := clc.config()
+	return simplePOST(cfg, cfg.serverAPIV2, clc.networkURI(dc, networkID)+"/release", clc.creds, "", nil)
+}
+
+//////////////// helpers over the interface
+
+// ClaimNetworkAndWait claims a network and waits for it.  As with public IPs the operation doesn't
+// say what it made, so the new network is the one that wasn't listed before.
+func ClaimNetworkAndWait(clc CenturyLinkClient, dc string) (*NetworkDetails, error) {
+	before, err := clc.listNetworks(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, n := range before {
+		known[n.NetworkID] = true
+	}
+
+	operationID, err := clc.claimNetwork(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WaitForOperation(clc, operationID, "claim a network in "+strings.ToUpper(dc), operationTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := clc.listNetworks(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range after {
+		if !known[after[idx].NetworkID] {
+			return &after[idx], nil
+		}
+	}
+
+	return nil, fmt.Errorf("operation %s succeeded, but %s has no new network", operationID, strings.ToUpper(dc))
+}
+
+// NodeNetworkWarnings names the nodes whose IP is in none of networks.  Nodes that are still
+// server: or group: references are skipped, and so is everything if networks is empty, which is
+// more likely a DC without the networks API than one without networks.
+func NodeNetworkWarnings(nodes []PoolNode, networks []NetworkDetails) []string {
+	ret := make([]string, 0)
+	if len(networks) == 0 {
+		return ret
+	}
+
+	for _, node := range nodes {
+		if isNodeRef(node.TargetIP) {
+			continue
+		}
+
+		found := false
+		for idx := range networks {
+			if networks[idx].Contains(node.TargetIP) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			ret = append(ret, fmt.Sprintf("node %s is not in any of the account's networks in %s", node.TargetIP, networks[0].DataCenter))
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,462 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+//// building servers.  A DC's deployment capabilities say which templates and networks it offers;
+//// POST /v2/servers/{acct} queues the build and answers with the operation to wait for and a UUID,
+//// which is the only way to find the server until the build has given it a name.
+
+const ( // ServerSpec.Type
+	SERVER_TYPE_STANDARD   = "standard"
+	SERVER_TYPE_HYPERSCALE = "hyperscale"
+)
+
+const ( // ServerSpec.StorageType
+	STORAGE_STANDARD   = "standard"
+	STORAGE_PREMIUM    = "premium"
+	STORAGE_HYPERSCALE = "hyperscale"
+)
+
+var serverCreateTimeout = 30 * time.Minute
+
+//////////////// wire format
+
+type deployableNetworkJSON struct {
+	Name      string `json:"name"`
+	NetworkID string `json:"networkId"`
+	Type      string `json:"type"`
+	AccountID string `json:"accountID"`
+}
+
+type templateJSON struct {
+	Name               string   `json:"name"`
+	OSType             string   `json:"osType"`
+	Description        string   `json:"description"`
+	StorageSizeGB      int      `json:"storageSizeGB"`
+	Capabilities       []string `json:"capabilities"`
+	ReservedDrivePaths []string `json:"reservedDrivePaths"`
+}
+
+type importableOSTypeJSON struct {
+	ID                 int    `json:"id"`
+	Description        string `json:"description"`
+	LabProductCode     string `json:"labProductCode"`
+	PremiumProductCode string `json:"premiumProductCode"`
+	Type               string `json:"type"`
+}
+
+type deploymentCapabilitiesJSON struct {
+	DataCenter                 string                  `json:"dataCenter"`
+	SupportsPremiumStorage     bool                    `json:"supportsPremiumStorage"`
+	SupportsSharedLoadBalancer bool                    `json:"supportsSharedLoadBalancer"` // the legacy shared LB, says nothing of the LB API
+	SupportsBareMetalServers   bool                    `json:"supportsBareMetalServers"`
+	DeployableNetworks         []deployableNetworkJSON `json:"deployableNetworks"`
+	Templates                  []templateJSON          `json:"templates"`
+	ImportableOSTypes          []importableOSTypeJSON  `json:"importableOSTypes"`
+}
+
+type bareMetalMemoryJSON struct {
+	CapacityGB int `json:"capacityGB"`
+}
+
+type bareMetalProcessorJSON struct {
+	CoresPerSocket int    `json:"coresPerSocket"`
+	Description    string `json:"description"`
+	Sockets        int    `json:"sockets"`
+}
+
+type bareMetalSKUJSON struct {
+	ID           string                 `json:"id"`
+	HourlyRate   float64                `json:"hourlyRate"`
+	Availability string                 `json:"availability"`
+	Memory       []bareMetalMemoryJSON  `json:"memory"`
+	Processor    bareMetalProcessorJSON `json:"processor"`
+	Storage      []BareMetalDrive       `json:"storage"` // same field names
+}
+
+type bareMetalCapabilitiesJSON struct {
+	SKUs             []bareMetalSKUJSON `json:"skus"`
+	OperatingSystems []BareMetalOS      `json:"operatingSystems"` // same field names
+}
+
+type customFieldJSON struct {
+	ID    string `json:"id"`
+	Value string `json:"value"`
+}
+
+type serverCreateJSON struct {
+	Name           string            `json:"name"`
+	Description    string            `json:"description,omitempty"`
+	GroupID        string            `json:"groupId"`
+	SourceServerID string            `json:"sourceServerId"`
+	IsManagedOS    bool              `json:"isManagedOS"`
+	NetworkID      string            `json:"networkId,omitempty"`
+	IPAddress      string            `json:"ipAddress,omitempty"`
+	Password       string            `json:"password,omitempty"`
+	CPU            int               `json:"cpu"`
+	MemoryGB       int               `json:"memoryGB"`
+	Type           string            `json:"type"`
+	StorageType    string            `json:"storageType"`
+	CustomFields   []customFieldJSON `json:"customFields,omitempty"`
+}
+
+type serverCreateResponseJSON struct {
+	Server   string  `json:"server"`
+	IsQueued bool    `json:"isQueued"`
+	Links    v2Links `json:"links"` // "status" is the operation, "self" the server by UUID
+}
+
+func capabilitiesFromJSON(dc string, src *deploymentCapabilitiesJSON) *DeploymentCapabilities {
+	ret := &DeploymentCapabilities{
+		DataCenter:                 dc,
+		SupportsPremiumStorage:     src.SupportsPremiumStorage,
+		SupportsBareMetal:          src.SupportsBareMetalServers,
+		SupportsSharedLoadBalancer: src.SupportsSharedLoadBalancer,
+		Networks:                   make([]DeployableNetwork, len(src.DeployableNetworks)),
+		Templates:                  make([]ServerTemplate, len(src.Templates)),
+		ImportableOSTypes:          make([]ImportableOSType, len(src.ImportableOSTypes)),
+	}
+
+	for idx, n := range src.DeployableNetworks {
+		ret.Networks[idx] = DeployableNetwork{NetworkID: n.NetworkID, Name: n.Name, Type: n.Type, AccountID: n.AccountID}
+	}
+
+	for idx, t := range src.Templates {
+		ret.Templates[idx] = ServerTemplate{
+			Name:               t.Name,
+			OSType:             t.OSType,
+			Description:        t.Description,
+			StorageSizeGB:      t.StorageSizeGB,
+			Capabilities:       append([]string{}, t.Capabilities...),
+			ReservedDrivePaths: append([]string{}, t.ReservedDrivePaths...),
+		}
+	}
+
+	for idx, o := range src.ImportableOSTypes {
+		ret.ImportableOSTypes[idx] = ImportableOSType{ID: o.ID, Type: o.Type, Description: o.Description,
+			LabProductCode: o.LabProductCode, PremiumProductCode: o.PremiumProductCode}
+	}
+
+	return ret
+}
+
+func bareMetalFromJSON(src *bareMetalCapabilitiesJSON) *BareMetalCapabilities {
+	ret := &BareMetalCapabilities{
+		SKUs:             make([]BareMetalSKU, len(src.SKUs)),
+		OperatingSystems: append([]BareMetalOS{}, src.OperatingSystems...),
+	}
+
+	for idx, sku := range src.SKUs {
+		ret.SKUs[idx] = BareMetalSKU{
+			ID:             sku.ID,
+			HourlyRate:     sku.HourlyRate,
+			Availability:   sku.Availability,
+			Processor:      sku.Processor.Description,
+			Sockets:        sku.Processor.Sockets,
+			CoresPerSocket: sku.Processor.CoresPerSocket,
+			Storage:        append([]BareMetalDrive{}, sku.Storage...),
+		}
+		for _, m := range sku.Memory {
+			ret.SKUs[idx].MemoryGB += m.CapacityGB
+		}
+	}
+
+	return ret
+}
+
+func serverSpecToJSON(spec *ServerSpec) *serverCreateJSON {
+	ret := &serverCreateJSON{
+		Name:           spec.Name,
+		Description:    spec.Description,
+		GroupID:        spec.GroupID,
+		SourceServerID: spec.Template,
+		NetworkID:      spec.NetworkID,
+		IPAddress:      spec.IPAddress,
+		Password:       spec.Password,
+		CPU:            spec.CPU,
+		MemoryGB:       spec.MemoryGB,
+		Type:           spec.Type,
+		StorageType:    spec.StorageType,
+	}
+
+	for id, value := range spec.CustomFields {
+		ret.CustomFields = append(ret.CustomFields, customFieldJSON{ID: id, Value: value})
+	}
+	sort.Slice(ret.CustomFields, func(i, j int) bool { return ret.CustomFields[i].ID < ret.CustomFields[j].ID })
+
+	return ret
+}
+
+func serverSpecFromJSON(src *serverCreateJSON) *ServerSpec {
+	ret := &ServerSpec{
+		Name:        src.Name,
+		Description: src.Description,
+		GroupID:     src.GroupID,
+		Template:    src.SourceServerID,
+		NetworkID:   src.NetworkID,
+		IPAddress:   src.IPAddress,
+		Password:    src.Password,
+		CPU:         src.CPU,
+		MemoryGB:    src.MemoryGB,
+		Type:        src.Type,
+		StorageType: src.StorageType,
+	}
+
+	if len(src.CustomFields) > 0 {
+		ret.CustomFields = make(map[string]string)
+		for _, field := range src.CustomFields {
+			ret.CustomFields[field.ID] = field.Value
+		}
+	}
+
+	return ret
+}
+
+//////////////// clc method: deploymentCapabilities()
+
+func (clc *clcImpl) deploymentCapabilities(dc string) (*DeploymentCapabilities, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	uri := fmt.Sprintf("/v2/datacenters/%s/%s/deploymentCapabilities", clc.creds.GetAccount(), dc)
+	apiret := &deploymentCapabilitiesJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	return capabilitiesFromJSON(dc, apiret), nil
+}
+
+//////////////// clc method: bareMetalCapabilities()
+
+func (clc *clcImpl) bareMetalCapabilities(dc string) (*BareMetalCapabilities, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return nil, errDC
+	}
+
+	uri := fmt.Sprintf("/v2/datacenters/%s/%s/bareMetalCapabilities", clc.creds.GetAccount(), dc)
+	apiret := &bareMetalCapabilitiesJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	return bareMetalFromJSON(apiret), nil
+}
+
+//////////////// clc method: createServer()
+
+func (clc *clcImpl) createServer(spec *ServerSpec) (*ServerCreationInfo, error) {
+	uri := fmt.Sprintf("/v2/servers/%s", clc.creds.GetAccount())
+	apiret := &serverCreateResponseJSON{}
+
+	cfg := clc.config()
+	err := marshalledPOST(cfg, cfg.serverAPIV2, uri, clc.creds, serverSpecToJSON(spec), apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !apiret.IsQueued {
+		return nil, makeErrorOld("server " + spec.Name + " was not queued for creation")
+	}
+
+	return &ServerCreationInfo{
+		Name:        apiret.Server,
+		UUID:        findLinkV2(apiret.Links, "self"),
+		OperationID: findLinkV2(apiret.Links, "status"),
+	}, nil
+}
+
+//////////////// clc method: inspectServerUUID()
+
+func (clc *clcImpl) inspectServerUUID(uuid string) (*ServerDetails, error) {
+	uri := fmt.Sprintf("/v2/servers/%s/%s?uuid=true", clc.creds.GetAccount(), uuid)
+	apiret := &serverJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	return serverFromJSON(apiret), nil
+}
+
+//////////////// helpers over the interface
+
+// ValidateServerSpec checks spec before anything is sent, filling in the default type and storage and
+// upper-casing the name.  The API wants the account alias and name together in 10 characters.  With
+// caps (may be nil) the template, network (ID or name) and storage type are checked against what the
+// DC offers, and the template and network are given as the API names them.
+func ValidateServerSpec(spec *ServerSpec, accountAlias string, caps *DeploymentCapabilities) error {
+	spec.Name = strings.ToUpper(strings.TrimSpace(spec.Name))
+	if (len(spec.Name) < 1) || (len(spec.Name) > 8) || (len(accountAlias)+len(spec.Name) > 10) {
+		return fmt.Errorf("server name %q must be 1 to %d characters", spec.Name, min(8, 10-len(accountAlias)))
+	}
+	for _, c := range spec.Name {
+		if !(((c >= 'A') && (c <= 'Z')) || ((c >= '0') && (c <= '9')) || (c == '-')) {
+			return fmt.Errorf("server name %q may only have letters, digits and dashes", spec.Name)
+		}
+	}
+
+	if spec.GroupID == "" {
+		return fmt.Errorf("a new server needs a group")
+	}
+	if spec.Template == "" {
+		return fmt.Errorf("a new server needs a template, see the DC's templates")
+	}
+	if (spec.CPU < 1) || (spec.CPU > 16) {
+		return fmt.Errorf("cpu %d is not 1 to 16", spec.CPU)
+	}
+	if (spec.MemoryGB < 1) || (spec.MemoryGB > 128) {
+		return fmt.Errorf("memory %dGB is not 1 to 128", spec.MemoryGB)
+	}
+
+	if spec.Type == "" {
+		spec.Type = SERVER_TYPE_STANDARD
+	}
+	if spec.StorageType == "" {
+		spec.StorageType = STORAGE_STANDARD
+		if spec.Type == SERVER_TYPE_HYPERSCALE {
+			spec.StorageType = STORAGE_HYPERSCALE
+		}
+	}
+
+	if (spec.Type != SERVER_TYPE_STANDARD) && (spec.Type != SERVER_TYPE_HYPERSCALE) {
+		return fmt.Errorf("server type %q is not %s or %s", spec.Type, SERVER_TYPE_STANDARD, SERVER_TYPE_HYPERSCALE)
+	}
+	if (spec.Type == SERVER_TYPE_HYPERSCALE) != (spec.StorageType == STORAGE_HYPERSCALE) {
+		return fmt.Errorf("%s storage is for, and only for, %s servers", STORAGE_HYPERSCALE, SERVER_TYPE_HYPERSCALE)
+	}
+	if (spec.StorageType != STORAGE_STANDARD) && (spec.StorageType != STORAGE_PREMIUM) && (spec.StorageType != STORAGE_HYPERSCALE) {
+		return fmt.Errorf("storage type %q is not %s, %s or %s", spec.StorageType, STORAGE_STANDARD, STORAGE_PREMIUM, STORAGE_HYPERSCALE)
+	}
+
+	if (spec.IPAddress != "") && (net.ParseIP(spec.IPAddress) == nil) {
+		return fmt.Errorf("invalid IP address %q", spec.IPAddress)
+	}
+	for id := range spec.CustomFields {
+		if id == "" {
+			return fmt.Errorf("a custom field needs its ID")
+		}
+	}
+
+	if caps == nil {
+		return nil
+	}
+
+	template, err := FindTemplate(caps, spec.Template)
+	if err != nil {
+		return err
+	}
+	spec.Template = template.Name
+
+	if (spec.StorageType == STORAGE_PREMIUM) && !caps.SupportsPremiumStorage {
+		return fmt.Errorf("%s does not offer %s storage", caps.DataCenter, STORAGE_PREMIUM)
+	}
+
+	if spec.NetworkID != "" { // by ID or name
+		found := false
+		for _, n := range caps.Networks {
+			if (n.NetworkID == spec.NetworkID) || strings.EqualFold(n.Name, spec.NetworkID) {
+				spec.NetworkID = n.NetworkID
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s has no network %s for new servers", caps.DataCenter, spec.NetworkID)
+		}
+	}
+
+	return nil
+}
+
+// FindTemplate finds a template by name, ignoring case.  Otherwise the error suggests those whose
+// name or description mentions it.
+func FindTemplate(caps *DeploymentCapabilities, name string) (*ServerTemplate, error) {
+	for idx := range caps.Templates {
+		if strings.EqualFold(caps.Templates[idx].Name, name) {
+			return &caps.Templates[idx], nil
+		}
+	}
+
+	suggestions := make([]string, 0)
+	for _, t := range caps.Templates {
+		if strings.Contains(strings.ToUpper(t.Name+" "+t.Description), strings.ToUpper(name)) {
+			suggestions = append(suggestions, t.Name)
+		}
+	}
+
+	msg := fmt.Sprintf("%s has no template %q", caps.DataCenter, name)
+	if len(suggestions) > 0 {
+		msg += ", did you mean " + strings.Join(suggestions, " or ") + "?"
+	}
+	return nil, fmt.Errorf("%s", msg)
+}
+
+// TrackServerCreate starts a job for a server being built.  Its result is the new server's ID and IPs.
+func TrackServerCreate(clc CenturyLinkClient, info *ServerCreationInfo, timeout time.Duration) string {
+	poll := pollOperation(clc, info.OperationID)
+
+	return clc.jobTracker().Start(JOB_OPERATION, info.OperationID, "create server "+info.Name, OPERATION_NOT_STARTED, timeout,
+		func() (JobPoll, error) {
+			polled, err := poll()
+			if (err != nil) || (polled.State != JOB_SUCCEEDED) {
+				return polled, err
+			}
+
+			server, err := clc.inspectServerUUID(info.UUID)
+			if err != nil {
+				return JobPoll{}, err
+			}
+
+			polled.Result = strings.TrimSpace(server.ServerID + " " + strings.Join(server.PrivateIPs, ","))
+			return polled, nil
+		})
+}
+
+// CreateServerAndWait creates a server, waits for it to be built and returns it
+func CreateServerAndWait(clc CenturyLinkClient, spec *ServerSpec) (*ServerDetails, error) {
+	info, err := clc.createServer(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := clc.jobTracker()
+	if err = tracker.Wait(TrackServerCreate(clc, info, serverCreateTimeout)); err != nil {
+		return nil, err
+	}
+
+	return clc.inspectServerUUID(info.UUID)
+}
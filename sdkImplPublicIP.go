@@ -0,0 +1,245 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//// server public IPs, /v2/servers/{acct}/{server}/publicIPAddresses.  There is no listing call,
+//// a server's public IPs are in its details (see ListPublicIPs).
+
+type publicIPPortJSON struct {
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+	PortTo   int    `json:"portTo,omitempty"`
+}
+
+type sourceRestrictionJSON struct {
+	CIDR string `json:"cidr"`
+}
+
+type publicIPJSON struct {
+	InternalIPAddress  string                  `json:"internalIPAddress,omitempty"`
+	Ports              []publicIPPortJSON      `json:"ports"`
+	SourceRestrictions []sourceRestrictionJSON `json:"sourceRestrictions,omitempty"`
+}
+
+func publicIPToJSON(ip *PublicIPDetails) *publicIPJSON {
+	ret := &publicIPJSON{
+		InternalIPAddress:  ip.InternalIP,
+		Ports:              make([]publicIPPortJSON, len(ip.Ports)),
+		SourceRestrictions: make([]sourceRestrictionJSON, len(ip.SourceRestrictions)),
+	}
+
+	for idx, port := range ip.Ports {
+		ret.Ports[idx] = publicIPPortJSON{Protocol: port.Protocol, Port: port.Port, PortTo: port.PortTo}
+	}
+	for idx, cidr := range ip.SourceRestrictions {
+		ret.SourceRestrictions[idx] = sourceRestrictionJSON{CIDR: cidr}
+	}
+
+	return ret
+}
+
+func publicIPFromJSON(src *publicIPJSON, serverID, publicIP string) *PublicIPDetails {
+	ret := &PublicIPDetails{
+		PublicIP:           publicIP,
+		ServerID:           serverID,
+		InternalIP:         src.InternalIPAddress,
+		Ports:              make([]PublicIPPort, len(src.Ports)),
+		SourceRestrictions: make([]string, len(src.SourceRestrictions)),
+	}
+
+	for idx, port := range src.Ports {
+		ret.Ports[idx] = PublicIPPort{Protocol: port.Protocol, Port: port.Port, PortTo: port.PortTo}
+	}
+	for idx, sr := range src.SourceRestrictions {
+		ret.SourceRestrictions[idx] = sr.CIDR
+	}
+
+	return ret
+}
+
+func (clc *clcImpl) publicIPURI(serverID, publicIP string) string {
+	uri := fmt.Sprintf("/v2/servers/%s/%s/publicIPAddresses", clc.creds.GetAccount(), strings.ToUpper(serverID))
+	if publicIP != "" {
+		uri += "/" + publicIP
+	}
+	return uri
+}
+
+//////////////// clc method: inspectPublicIP()
+
+func (clc *clcImpl) inspectPublicIP(serverID, publicIP string) (*PublicIPDetails, error) {
+	apiret := &publicIPJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, clc.publicIPURI(serverID, publicIP), clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	return publicIPFromJSON(apiret, strings.ToUpper(serverID), publicIP), nil
+}
+
+//////////////// clc method: addPublicIP()
+
+func (clc *clcImpl) addPublicIP(serverID string, ip *PublicIPDetails) (string, error) {
+	apiret := &v2LinkJSON{}
+
+	cfg := clc.config()
+	err := marshalledPOST(cfg, cfg.serverAPIV2, clc.publicIPURI(serverID, ""), clc.creds, publicIPToJSON(ip), apiret)
+	if err != nil {
+		return "", err
+	}
+
+	return apiret.ID, nil
+}
+
+//////////////// clc method: updatePublicIP()
+
+func (clc *clcImpl) updatePublicIP(serverID string, ip *PublicIPDetails) (string, error) {
+	apiret := &v2LinkJSON{}
+
+	cfg := clc.config()
+	err := marshalledPUT(cfg, cfg.serverAPIV2, clc.publicIPURI(serverID, ip.PublicIP), clc.creds, publicIPToJSON(ip), apiret)
+	if err != nil {
+		return "", err
+	}
+
+	return apiret.ID, nil
+}
+
+//////////////// clc method: deletePublicIP()
+
+func (clc *clcImpl) deletePublicIP(serverID, publicIP string) (string, error) {
+	apiret := &v2LinkJSON{}
+
+	cfg := clc.config()
+	err := simpleDELETE(cfg, cfg.serverAPIV2, clc.publicIPURI(serverID, publicIP), clc.creds, apiret)
+	if err != nil {
+		return "", err
+	}
+
+	return apiret.ID, nil
+}
+
+//////////////// helpers over the interface
+
+// ListPublicIPs has the details of each of the server's public IPs
+func ListPublicIPs(clc CenturyLinkClient, serverID string) ([]PublicIPDetails, error) {
+	server, err := clc.inspectServer(serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]PublicIPDetails, 0, len(server.PublicIPs))
+	for _, publicIP := range server.PublicIPs {
+		ip, err := clc.inspectPublicIP(server.ServerID, publicIP)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", publicIP, err.Error())
+		}
+		ret = append(ret, *ip)
+	}
+
+	return ret, nil
+}
+
+// AddPublicIPAndWait adds a public IP and waits for it.  The operation doesn't say which IP it was
+// given, so that is found by comparing the server's public IPs before and after.
+func AddPublicIPAndWait(clc CenturyLinkClient, serverID string, ip *PublicIPDetails) (*PublicIPDetails, error) {
+	before, err := clc.inspectServer(serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	operationID, err := clc.addPublicIP(serverID, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WaitForOperation(clc, operationID, "add a public IP to "+before.ServerID, operationTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := clc.inspectServer(serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, publicIP := range after.PublicIPs {
+		if !slices.Contains(before.PublicIPs, publicIP) {
+			return clc.inspectPublicIP(after.ServerID, publicIP)
+		}
+	}
+
+	return nil, fmt.Errorf("operation %s succeeded, but server %s has no new public IP", operationID, after.ServerID)
+}
+
+// ParsePortSpec takes "tcp/80", "udp/8000-8010" or "icmp"
+func ParsePortSpec(spec string) (PublicIPPort, error) {
+	protocol, ports, hasPorts := strings.Cut(strings.ToUpper(strings.TrimSpace(spec)), "/")
+
+	if protocol == "ICMP" {
+		if hasPorts {
+			return PublicIPPort{}, fmt.Errorf("invalid port %q, ICMP has no port numbers", spec)
+		}
+		return PublicIPPort{Protocol: protocol}, nil
+	}
+
+	if ((protocol != "TCP") && (protocol != "UDP")) || !hasPorts {
+		return PublicIPPort{}, fmt.Errorf("invalid port %q, want tcp/PORT, udp/FROM-TO or icmp", spec)
+	}
+
+	from, to, isRange := strings.Cut(ports, "-")
+	ret := PublicIPPort{Protocol: protocol}
+	var err error
+
+	ret.Port, err = strconv.Atoi(from)
+	if (err == nil) && isRange {
+		ret.PortTo, err = strconv.Atoi(to)
+	}
+
+	if (err != nil) || (ret.Port < 1) || (ret.Port > 65535) || (isRange && ((ret.PortTo <= ret.Port) || (ret.PortTo > 65535))) {
+		return PublicIPPort{}, fmt.Errorf("invalid port %q", spec)
+	}
+
+	return ret, nil
+}
+
+// ParsePortSpecs takes a comma separated list of ParsePortSpec specs
+func ParsePortSpecs(list string) ([]PublicIPPort, error) {
+	ret := make([]PublicIPPort, 0)
+	for _, spec := range strings.Split(list, ",") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		port, err := ParsePortSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, port)
+	}
+
+	return ret, nil
+}
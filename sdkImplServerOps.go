@@ -0,0 +1,199 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+//// power and maintenance operations, POST /v2/operations/{acct}/servers/{operation} with a list of
+//// server IDs.  The answer says, server by server, whether it was queued and as which operation.
+
+const ( // serverOperation operations, as the API names them
+	SERVER_OP_POWER_ON          = "powerOn"
+	SERVER_OP_POWER_OFF         = "powerOff"
+	SERVER_OP_REBOOT            = "reboot"
+	SERVER_OP_SHUT_DOWN         = "shutDown"
+	SERVER_OP_PAUSE             = "pause"
+	SERVER_OP_START_MAINTENANCE = "startMaintenance"
+	SERVER_OP_STOP_MAINTENANCE  = "stopMaintenance"
+)
+
+type serverOperationJSON struct {
+	Server       string  `json:"server"`
+	IsQueued     bool    `json:"isQueued"`
+	ErrorMessage string  `json:"errorMessage,omitempty"`
+	Links        v2Links `json:"links,omitempty"`
+}
+
+//////////////// clc method: serverOperation()
+
+func (clc *clcImpl) serverOperation(operation string, serverIDs []string) ([]ServerOperationResult, error) {
+	ids := make([]string, len(serverIDs))
+	for idx, id := range serverIDs {
+		ids[idx] = strings.ToUpper(id)
+	}
+
+	uri := fmt.Sprintf("/v2/operations/%s/servers/%s", clc.creds.GetAccount(), operation)
+	apiret := make([]serverOperationJSON, 0)
+
+	cfg := clc.config()
+	err := marshalledPOST(cfg, cfg.serverAPIV2, uri, clc.creds, ids, &apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]ServerOperationResult, len(apiret))
+	for idx, src := range apiret {
+		ret[idx] = ServerOperationResult{ServerID: src.Server, Queued: src.IsQueued, Error: src.ErrorMessage}
+		if src.IsQueued {
+			ret[idx].OperationID = findLinkV2(src.Links, "status")
+			ret[idx].Status = OPERATION_NOT_STARTED
+		}
+	}
+
+	return ret, nil
+}
+
+//////////////// helpers over the interface
+
+// TrackServerOperations starts a job for every queued operation in results, filling in JobID
+func TrackServerOperations(clc CenturyLinkClient, operation string, results []ServerOperationResult, timeout time.Duration) {
+	for idx := range results {
+		result := &results[idx]
+		if result.Queued {
+			result.JobID = TrackOperation(clc, result.OperationID, operation+" "+result.ServerID, timeout)
+		}
+	}
+}
+
+// WaitForServerOperations waits for every queued operation in results, at once, filling in JobID, Status
+// and Error.  done, if set, is called as each finishes.  Returns how many didn't succeed, counting
+// those that weren't queued.
+func WaitForServerOperations(clc CenturyLinkClient, operation string, results []ServerOperationResult,
+	timeout time.Duration, done func(result *ServerOperationResult)) int {
+
+	TrackServerOperations(clc, operation, results, timeout)
+	forEachParallel(len(results), INSPECT_MAX_WORKERS, nil, func(idx int) {
+		result := &results[idx]
+		if !result.Queued {
+			return
+		}
+
+		err := clc.jobTracker().Wait(result.JobID)
+		if err == nil {
+			result.Status = OPERATION_SUCCEEDED
+		} else {
+			result.Status = OPERATION_FAILED
+			result.Error = err.Error()
+		}
+
+		if done != nil {
+			done(result)
+		}
+	})
+
+	failed := 0
+	for _, result := range results {
+		if result.Status != OPERATION_SUCCEEDED {
+			failed++
+		}
+	}
+	return failed
+}
+
+// a node taken out of a pool by DrainServers
+type DrainedNode struct {
+	ServerID   string   `json:"serverID"` // whose private IP the node was
+	DataCenter string   `json:"dataCenter"`
+	LBID       string   `json:"lbid"`
+	PoolID     string   `json:"poolID"`
+	Node       PoolNode `json:"node"`
+}
+
+// DrainServers takes the servers' private IPs out of every pool in their DCs, so they can be stopped
+// without the LBs sending them traffic.  The pools are read once, past any cache, and each pool that has
+// some of the servers is updated once; it returns when every update's request is over.  With dryRun it
+// only says what it would take out.
+func DrainServers(clc CenturyLinkClient, servers []*ServerDetails, dryRun bool) ([]DrainedNode, error) {
+	serverByIP := make(map[string]string) // "DC/IP" -> ServerID, IPs can repeat in other DCs
+	for _, server := range servers {
+		for _, ip := range server.PrivateIPs {
+			serverByIP[strings.ToUpper(server.DataCenter)+"/"+ip] = server.ServerID
+		}
+	}
+
+	lbs, err := clc.listAllLB()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]DrainedNode, 0)
+	requests := make([]*LBRequest, 0)
+	for _, summary := range lbs {
+		dc := strings.ToUpper(summary.DataCenter)
+		if !slices.ContainsFunc(servers, func(server *ServerDetails) bool { return strings.EqualFold(server.DataCenter, dc) }) {
+			continue
+		}
+
+		lb, err := clc.uncached().inspectLB(summary.DataCenter, summary.LBID) // a cached node list could put a drained node back
+		if err != nil {
+			return ret, err
+		}
+
+		for _, pool := range lb.Pools {
+			kept := make([]PoolNode, 0, len(pool.Nodes))
+			drained := make([]DrainedNode, 0)
+			for _, node := range pool.Nodes {
+				if serverID, found := serverByIP[dc+"/"+node.TargetIP]; found {
+					drained = append(drained, DrainedNode{ServerID: serverID, DataCenter: lb.DataCenter, LBID: lb.LBID, PoolID: pool.PoolID, Node: node})
+				} else {
+					kept = append(kept, node)
+				}
+			}
+
+			if len(drained) == 0 {
+				continue
+			}
+
+			if !dryRun {
+				updated := copyPool(&pool)
+				updated.LBID = lb.LBID
+				updated.Nodes = kept
+				result, err := clc.updatePool(lb.DataCenter, lb.LBID, updated)
+				if err != nil {
+					return ret, fmt.Errorf("pool %s on %s: %s", pool.PoolID, lb.LBID, err.Error())
+				}
+				if result.Request != nil {
+					requests = append(requests, result.Request)
+				}
+			}
+			ret = append(ret, drained...)
+		}
+	}
+
+	for _, request := range requests {
+		if err := WaitLBRequest(clc, request, lbReadyTimeout); err != nil {
+			return ret, err
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//// servers and groups, from the v2 API.  There is no call listing a datacenter's servers:  the DC
+//// links to its root group, GET on a group returns all of its subgroups with links to their servers,
+//// and each server is then one more GET.
+
+type v2LinkJSON struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href,omitempty"`
+	ID   string `json:"id,omitempty"` // LBaaS calls this resourceId, see LinkJSON
+	Name string `json:"name,omitempty"`
+}
+
+type v2Links []v2LinkJSON
+
+func findLinkV2(links v2Links, rel string) string {
+	for _, link := range links {
+		if link.Rel == rel {
+			return link.ID
+		}
+	}
+
+	return ""
+}
+
+type dcDetailsJSON struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Links v2Links `json:"links"`
+}
+
+type serverIPJSON struct {
+	Internal string `json:"internal,omitempty"`
+	Public   string `json:"public,omitempty"`
+}
+
+type serverHardwareJSON struct {
+	IPAddresses       []serverIPJSON `json:"ipAddresses"`
+	CPU               int            `json:"cpu"`
+	MemoryMB          int            `json:"memoryMB"`
+	StorageGB         int            `json:"storageGB"`
+	PowerState        string         `json:"powerState"`
+	InMaintenanceMode bool           `json:"inMaintenanceMode"`
+}
+
+type serverJSON struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	GroupID     string             `json:"groupId"`
+	LocationID  string             `json:"locationId"`
+	OSType      string             `json:"osType"`
+	Status      string             `json:"status"`
+	Details     serverHardwareJSON `json:"details"`
+	Links       v2Links            `json:"links"`
+}
+
+type groupJSON struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	Description  string      `json:"description"`
+	LocationID   string      `json:"locationId"`
+	Type         string      `json:"type"`
+	Status       string      `json:"status"`
+	ServersCount int         `json:"serversCount"`
+	Groups       []groupJSON `json:"groups"`
+	Links        v2Links     `json:"links"`
+}
+
+func serverFromJSON(src *serverJSON) *ServerDetails {
+	ret := &ServerDetails{
+		ServerID:      src.ID,
+		Description:   src.Description,
+		DataCenter:    strings.ToUpper(src.LocationID),
+		GroupID:       src.GroupID,
+		Status:        src.Status,
+		PowerState:    src.Details.PowerState,
+		InMaintenance: src.Details.InMaintenanceMode,
+		OSType:        src.OSType,
+		CPU:           src.Details.CPU,
+		MemoryMB:      src.Details.MemoryMB,
+		StorageGB:     src.Details.StorageGB,
+		PrivateIPs:    make([]string, 0),
+		PublicIPs:     make([]string, 0),
+	}
+
+	for _, ip := range src.Details.IPAddresses { // a private IP is listed again for each public IP NAT'd to it
+		if (ip.Internal != "") && !slices.Contains(ret.PrivateIPs, ip.Internal) {
+			ret.PrivateIPs = append(ret.PrivateIPs, ip.Internal)
+		}
+		if ip.Public != "" {
+			ret.PublicIPs = append(ret.PublicIPs, ip.Public)
+		}
+	}
+
+	return ret
+}
+
+// parentID is for the subgroups, whose own parentGroup links are left implied
+func groupFromJSON(src *groupJSON, parentID string) GroupDetails {
+	if parentID == "" {
+		parentID = findLinkV2(src.Links, "parentGroup")
+	}
+
+	ret := GroupDetails{
+		GroupID:     src.ID,
+		Name:        src.Name,
+		Description: src.Description,
+		DataCenter:  strings.ToUpper(src.LocationID),
+		ParentID:    parentID,
+		Type:        src.Type,
+		ServerIDs:   make([]string, 0),
+		Groups:      make([]GroupDetails, len(src.Groups)),
+	}
+
+	for _, link := range src.Links {
+		if link.Rel == "server" {
+			ret.ServerIDs = append(ret.ServerIDs, link.ID)
+		}
+	}
+
+	for idx := range src.Groups {
+		ret.Groups[idx] = groupFromJSON(&src.Groups[idx], src.ID)
+	}
+
+	return ret
+}
+
+//////////////// clc method: inspectServer()
+
+func (clc *clcImpl) inspectServer(serverID string) (*ServerDetails, error) {
+
+	uri := fmt.Sprintf("/v2/servers/%s/%s", clc.creds.GetAccount(), strings.ToUpper(serverID))
+	apiret := &serverJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	return serverFromJSON(apiret), nil
+}
+
+//////////////// clc method: rootGroupID()
+
+func (clc *clcImpl) rootGroupID(dc string) (string, error) {
+	dc, errDC := clc.checkDC(dc)
+	if errDC != nil {
+		return "", errDC
+	}
+
+	uri := fmt.Sprintf("/v2/datacenters/%s/%s?groupLinks=true", clc.creds.GetAccount(), dc)
+	apiret := &dcDetailsJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return "", err
+	}
+
+	groupID := findLinkV2(apiret.Links, "group")
+	if groupID == "" {
+		return "", makeErrorOld("datacenter " + dc + " has no root group")
+	}
+
+	return groupID, nil
+}
+
+//////////////// clc method: inspectGroup()
+
+func (clc *clcImpl) inspectGroup(groupID string) (*GroupDetails, error) {
+
+	uri := fmt.Sprintf("/v2/groups/%s/%s", clc.creds.GetAccount(), groupID)
+	apiret := &groupJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return nil, err
+	}
+
+	group := groupFromJSON(apiret, "")
+	return &group, nil
+}
+
+//////////////// clc method: listServers()
+
+func (clc *clcImpl) listServers(dc string) ([]ServerDetails, error) {
+	dcs := []string{dc}
+	if dc == "" {
+		all, err := clc.listAllDC()
+		if err != nil {
+			return nil, err
+		}
+
+		dcs = make([]string, len(all))
+		for idx, known := range all {
+			dcs[idx] = known.DCID
+		}
+	}
+
+	ret := make([]ServerDetails, 0)
+	for _, dc := range dcs {
+		rootID, err := clc.rootGroupID(dc)
+		if err != nil {
+			return nil, err
+		}
+
+		root, err := clc.inspectGroup(rootID)
+		if err != nil {
+			return nil, err
+		}
+
+		servers, err := InspectServers(clc, root.AllServerIDs())
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, servers...)
+	}
+
+	return ret, nil
+}
+
+// InspectServers gets the details of many servers at once, in the order given.  A server that is
+// gone by the time it is asked about (404) is left out, any other failure fails the lot.
+func InspectServers(clc CenturyLinkClient, ids []string) ([]ServerDetails, error) {
+	servers := make([]*ServerDetails, len(ids))
+	errs := make([]error, len(ids))
+
+	forEachParallel(len(ids), INSPECT_DEFAULT_WORKERS, nil, func(idx int) {
+		servers[idx], errs[idx] = clc.inspectServer(ids[idx])
+	})
+
+	ret := make([]ServerDetails, 0, len(ids))
+	for idx, err := range errs {
+		if err != nil {
+			if herr, ok := err.(HttpError); ok && (herr.Code() == 404) {
+				continue
+			}
+			return nil, fmt.Errorf("server %s: %s", ids[idx], err.Error())
+		}
+		ret = append(ret, *servers[idx])
+	}
+
+	return ret, nil
+}
+
+// FilterServers keeps the servers whose ID, description or any IP contains text, ignoring case
+func FilterServers(servers []ServerDetails, text string) []ServerDetails {
+	text = strings.ToLower(text)
+	ret := make([]ServerDetails, 0)
+
+	for _, server := range servers {
+		fields := append([]string{server.ServerID, server.Description}, server.PrivateIPs...)
+		fields = append(fields, server.PublicIPs...)
+
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), text) {
+				ret = append(ret, server)
+				break
+			}
+		}
+	}
+
+	return ret
+}
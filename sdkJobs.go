@@ -0,0 +1,426 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//// long-running changes, tracked as jobs.  A v2 change returns a status link to an operation, an LB API
+//// change returns a request object whose links say what it changes; either way somebody has to poll.
+//// A JobTracker does that polling for everyone, backing off as it goes, tells listeners how each job is
+//// getting on, and remembers the jobs so that a session can list what it still has outstanding.
+
+const ( // what a job tracks, and so what Job.Ref is
+	JOB_OPERATION  = "operation"    // a v2 operation, by ID
+	JOB_LB         = "loadbalancer" // an LB API request on a load balancer, by LBID
+	JOB_LB_REQUEST = "lbRequest"    // any other LB API request, e.g. a pool change, by RequestID
+)
+
+const ( // Job.State
+	JOB_RUNNING   = "running"
+	JOB_SUCCEEDED = "succeeded"
+	JOB_FAILED    = "failed"
+	JOB_TIMED_OUT = "timedOut" // gave up waiting, the change itself may still happen
+)
+
+const ( // JobEvent.Type
+	JOB_EVENT_STARTED = "started"
+	JOB_EVENT_STATUS  = "status" // the API reported a new status, the job is still running
+	JOB_EVENT_DONE    = "done"   // succeeded, failed or timed out
+)
+
+type Job struct {
+	JobID       string     `json:"jobID"`
+	Kind        string     `json:"kind"` // one of the JOB_ kinds
+	Ref         string     `json:"ref"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	Status      string     `json:"status"` // as the API last reported it
+	Error       string     `json:"error,omitempty"`
+	Result      string     `json:"result,omitempty"` // what it made, once it has succeeded, e.g. a new server's ID and IP
+	Polls       int        `json:"polls"`
+	Started     time.Time  `json:"started"`
+	Finished    *time.Time `json:"finished,omitempty"`
+
+	timeout time.Duration
+	poll    JobPoller
+}
+
+// Outstanding is true until the job has succeeded or failed.  A timed out job is outstanding, the
+// tracker stopped waiting for it but the API didn't say it was over.
+func (job *Job) Outstanding() bool {
+	return (job.State == JOB_RUNNING) || (job.State == JOB_TIMED_OUT)
+}
+
+// Elapsed is how long the job ran, or has been running
+func (job *Job) Elapsed() time.Duration {
+	if job.Finished != nil {
+		return job.Finished.Sub(job.Started)
+	}
+	return time.Since(job.Started)
+}
+
+// JobPoller asks the API once how a job stands.  err is for a call that failed, which leaves the job as
+// it was:  Wait polls again until its deadline, unless the API answered 4xx.
+type JobPoller func() (JobPoll, error)
+
+type JobPoll struct {
+	Status string // as the API says, "" for no news
+	State  string // JOB_RUNNING, JOB_SUCCEEDED or JOB_FAILED
+	Result string // for JOB_SUCCEEDED, optional
+}
+
+type JobEvent struct {
+	Type string
+	Job  Job // a copy, as of the event
+}
+
+// JobOptions set the pause between polls, which starts at Interval and doubles up to MaxInterval
+type JobOptions struct {
+	Interval    time.Duration
+	MaxInterval time.Duration
+}
+
+var defaultJobOptions = JobOptions{Interval: 1 * time.Second, MaxInterval: 15 * time.Second}
+
+// a JobTracker is safe for concurrent use.  Jobs are numbered job-1, job-2... in the order started.
+type JobTracker struct {
+	mu        sync.Mutex
+	opts      JobOptions
+	seq       int
+	jobs      []*Job
+	listeners []func(JobEvent)
+}
+
+func NewJobTracker(opts JobOptions) *JobTracker {
+	return &JobTracker{opts: opts, jobs: make([]*Job, 0)}
+}
+
+// Listen has fn called on every event from now on.  fn may be called from several goroutines at once.
+func (t *JobTracker) Listen(fn func(JobEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+func (t *JobTracker) emit(eventType string, job Job) {
+	t.mu.Lock()
+	listeners := t.listeners
+	t.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(JobEvent{Type: eventType, Job: job})
+	}
+}
+
+// Start begins tracking, but not polling, a job.  status is what the API said when the change was
+// made.  timeout applies to each Wait.  Something still outstanding under the same kind and ref keeps
+// its job, whose ID is returned again.  A job that is over, or has no ref to tell it apart, never is.
+func (t *JobTracker) Start(kind, ref, description, status string, timeout time.Duration, poll JobPoller) string {
+	t.mu.Lock()
+	for _, job := range t.jobs {
+		if (ref != "") && (job.Kind == kind) && (job.Ref == ref) && job.Outstanding() {
+			t.mu.Unlock()
+			return job.JobID
+		}
+	}
+
+	t.seq++
+	job := &Job{
+		JobID:       fmt.Sprintf("job-%d", t.seq),
+		Kind:        kind,
+		Ref:         ref,
+		Description: description,
+		State:       JOB_RUNNING,
+		Status:      status,
+		Started:     time.Now(),
+		timeout:     timeout,
+		poll:        poll,
+	}
+	t.jobs = append(t.jobs, job)
+	copied := *job
+	t.mu.Unlock()
+
+	t.emit(JOB_EVENT_STARTED, copied)
+	return job.JobID
+}
+
+func (t *JobTracker) find(jobID string) *Job {
+	for _, job := range t.jobs {
+		if job.JobID == jobID {
+			return job
+		}
+	}
+	return nil
+}
+
+// Get returns a copy of the job
+func (t *JobTracker) Get(jobID string) (Job, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if job := t.find(jobID); job != nil {
+		return *job, true
+	}
+	return Job{}, false
+}
+
+// List returns copies of the outstanding jobs, or with all of every job, in the order started
+func (t *JobTracker) List(all bool) []Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ret := make([]Job, 0)
+	for _, job := range t.jobs {
+		if all || job.Outstanding() {
+			ret = append(ret, *job)
+		}
+	}
+	return ret
+}
+
+// pollOnce asks the API about a job and records the answer.  Returns whether the job is over, or the
+// call's error.  Only a job that is over gets an event, unless quiet is false.
+func (t *JobTracker) pollOnce(jobID string, quiet bool) (bool, error) {
+	t.mu.Lock()
+	job := t.find(jobID)
+	if (job == nil) || !job.Outstanding() {
+		t.mu.Unlock()
+		return true, nil
+	}
+	poll := job.poll
+	t.mu.Unlock()
+
+	polled, err := poll() // no lock, this is a call to the API
+	status, state := polled.Status, polled.State
+
+	t.mu.Lock()
+	job.Polls++
+	if err != nil {
+		job.Error = err.Error()
+		t.mu.Unlock()
+		return false, err
+	}
+
+	changed := (status != "") && (status != job.Status)
+	if status != "" {
+		job.Status = status
+	}
+
+	job.Error = ""
+	if state == JOB_FAILED {
+		job.State = JOB_FAILED
+		job.Error = fmt.Sprintf("%s %s failed", job.Kind, job.Ref)
+		if !strings.EqualFold(job.Status, "failed") {
+			job.Error += ", status " + job.Status
+		}
+	} else if state == JOB_SUCCEEDED {
+		job.State = JOB_SUCCEEDED
+		job.Result = polled.Result
+	}
+
+	if !job.Outstanding() {
+		now := time.Now()
+		job.Finished = &now
+	}
+	copied := *job
+	t.mu.Unlock()
+
+	if !copied.Outstanding() {
+		t.emit(JOB_EVENT_DONE, copied)
+		return true, nil
+	}
+	if changed && !quiet {
+		t.emit(JOB_EVENT_STATUS, copied)
+	}
+	return false, nil
+}
+
+// Wait polls the job until it succeeds (nil), fails, runs out of time or gets a 4xx.  A poll that
+// fails otherwise (a 5xx, no connection) is tried again at the next interval.  A job that is already
+// over returns how it ended, a timed out one is waited for again.
+func (t *JobTracker) Wait(jobID string) error {
+	t.mu.Lock()
+	job := t.find(jobID)
+	if job == nil {
+		t.mu.Unlock()
+		return fmt.Errorf("no job %s", jobID)
+	}
+	if job.State == JOB_TIMED_OUT {
+		job.State = JOB_RUNNING
+		job.Error = ""
+	}
+	deadline := time.Now().Add(job.timeout)
+	interval := t.opts.Interval
+	t.mu.Unlock()
+
+	for {
+		over, err := t.pollOnce(jobID, false)
+		if over {
+			return t.result(jobID)
+		}
+		if herr, ok := err.(HttpError); ok && (herr.Code() >= 400) && (herr.Code() < 500) {
+			return err // asking again won't change the answer
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return t.timedOut(jobID)
+		}
+
+		time.Sleep(min(interval, remaining)) // so the last poll is at the deadline
+		interval = min(2*interval, t.opts.MaxInterval)
+	}
+}
+
+func (t *JobTracker) timedOut(jobID string) error {
+	t.mu.Lock()
+	job := t.find(jobID)
+	job.State = JOB_TIMED_OUT
+	lastPoll := job.Error // a failed poll leaves its error here
+	job.Error = fmt.Sprintf("%s %s still %s after %s", job.Kind, job.Ref, job.Status, job.timeout)
+	if lastPoll != "" {
+		job.Error += ", last poll: " + lastPoll
+	}
+	copied := *job
+	t.mu.Unlock()
+
+	t.emit(JOB_EVENT_DONE, copied)
+	return fmt.Errorf("%s timed out: %s", copied.JobID, copied.Error)
+}
+
+func (t *JobTracker) result(jobID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job := t.find(jobID)
+	if job.State == JOB_SUCCEEDED {
+		return nil
+	}
+	return fmt.Errorf("%s", job.Error)
+}
+
+// Refresh polls each outstanding job once, in parallel, without waiting for any of them.  A call that
+// fails is left in the job's Error.
+func (t *JobTracker) Refresh() {
+	jobs := t.List(false)
+	forEachParallel(len(jobs), INSPECT_DEFAULT_WORKERS, nil, func(idx int) {
+		t.pollOnce(jobs[idx].JobID, true)
+	})
+}
+
+//////////////// the kinds of job, over the interface
+
+// TrackOperation starts a job for a v2 operation.  The description says what it does, "" for just its ID.
+func TrackOperation(clc CenturyLinkClient, operationID, description string, timeout time.Duration) string {
+	if description == "" {
+		description = "operation " + operationID
+	}
+
+	return clc.jobTracker().Start(JOB_OPERATION, operationID, description, OPERATION_NOT_STARTED, timeout,
+		pollOperation(clc, operationID))
+}
+
+func pollOperation(clc CenturyLinkClient, operationID string) JobPoller {
+	return func() (JobPoll, error) {
+		status, err := clc.uncached().operationStatus(operationID) // each poll, so it has the client's latest options
+		if err != nil {
+			return JobPoll{}, err
+		}
+
+		if status == OPERATION_SUCCEEDED {
+			return JobPoll{Status: status, State: JOB_SUCCEEDED}, nil
+		} else if status == OPERATION_FAILED {
+			return JobPoll{Status: status, State: JOB_FAILED}, nil
+		}
+		return JobPoll{Status: status, State: JOB_RUNNING}, nil
+	}
+}
+
+// TrackLB starts a job for the request that created a load balancer, which is done once inspectLB finds
+// the LB out of the pending states.  requestStatus is the request's own, from LoadBalancerCreationInfo.
+func TrackLB(clc CenturyLinkClient, dc, lbid, requestStatus string, timeout time.Duration) string {
+	description := fmt.Sprintf("create load balancer %s in %s", lbid, strings.ToUpper(dc))
+	return clc.jobTracker().Start(JOB_LB, lbid, description, requestStatus, timeout,
+		func() (JobPoll, error) {
+			lb, err := clc.uncached().inspectLB(dc, lbid) // a cached LB could look pending for the cache's TTL
+			if err != nil {
+				if err.Code() == 404 { // normal for a moment after creation
+					return JobPoll{State: JOB_RUNNING}, nil
+				}
+				return JobPoll{}, err
+			}
+
+			status := strings.ToLower(lb.Status)
+			if (status == "failed") || (status == "error") {
+				return JobPoll{Status: lb.Status, State: JOB_FAILED}, nil
+			} else if lbStatusPending(status) {
+				return JobPoll{Status: lb.Status, State: JOB_RUNNING}, nil
+			}
+			return JobPoll{Status: lb.Status, State: JOB_SUCCEEDED}, nil
+		})
+}
+
+// TrackLBRequest starts a job for the request an LB API change returned, e.g. a pool's.  A request that
+// was already over when it was made is never polled.  One without a link to poll isn't tracked, "".
+func TrackLBRequest(clc CenturyLinkClient, req *LBRequest, timeout time.Duration) string {
+	if req.Href == "" {
+		return ""
+	}
+
+	request := *req // as it was made, the poller doesn't see later changes to req
+	dc, requestID, status := req.DataCenter, req.RequestID, req.Status
+	description := fmt.Sprintf("%s in %s", req.Description, strings.ToUpper(dc))
+	return clc.jobTracker().Start(JOB_LB_REQUEST, requestID, description, status, timeout,
+		func() (JobPoll, error) {
+			if state := lbRequestState(status); state != JOB_RUNNING {
+				return JobPoll{Status: status, State: state}, nil
+			}
+
+			polled, err := clc.uncached().inspectLBRequest(&request)
+			if err != nil {
+				return JobPoll{}, err
+			}
+			return JobPoll{Status: polled.Status, State: lbRequestState(polled.Status)}, nil
+		})
+}
+
+// WaitLBRequest tracks the request and waits for it to be over.  One that can't be tracked isn't waited for.
+func WaitLBRequest(clc CenturyLinkClient, req *LBRequest, timeout time.Duration) error {
+	jobID := TrackLBRequest(clc, req, timeout)
+	if jobID == "" {
+		return nil
+	}
+
+	return clc.jobTracker().Wait(jobID)
+}
+
+// what a request's status means for its job.  Anything not known to be over is still running.
+func lbRequestState(status string) string {
+	switch strings.ToLower(status) {
+	case "complete", "completed", "succeeded":
+		return JOB_SUCCEEDED
+	case "failed", "error":
+		return JOB_FAILED
+	}
+
+	return JOB_RUNNING
+}
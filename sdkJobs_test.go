@@ -0,0 +1,291 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testJobOptions = JobOptions{Interval: time.Millisecond, MaxInterval: 4 * time.Millisecond}
+
+// answers with these errors (nil for "still running") and then success
+func scriptedPoller(errs ...error) JobPoller {
+	calls := 0
+	return func() (JobPoll, error) {
+		calls++
+		if calls <= len(errs) {
+			if err := errs[calls-1]; err != nil {
+				return JobPoll{}, err
+			}
+			return JobPoll{Status: "executing", State: JOB_RUNNING}, nil
+		}
+		return JobPoll{Status: "succeeded", State: JOB_SUCCEEDED}, nil
+	}
+}
+
+func TestJobWaitPollsThroughServerErrors(t *testing.T) {
+	tracker := NewJobTracker(testJobOptions)
+	unavailable := makeError("HTTP call failed", 503, nil)
+	id := tracker.Start(JOB_OPERATION, "op-1", "test", "notStarted", time.Minute,
+		scriptedPoller(unavailable, nil, makeError("HTTP call failed", HTTP_ERROR_CLIENT, nil), unavailable))
+
+	if err := tracker.Wait(id); err != nil {
+		t.Fatalf("Wait: %s", err.Error())
+	}
+	if job, _ := tracker.Get(id); (job.State != JOB_SUCCEEDED) || (job.Polls != 5) || (job.Error != "") {
+		t.Errorf("job = %+v", job)
+	}
+}
+
+// the same change started twice is one job while it runs; once it is over, or with no ref, a new one
+func TestJobStartReusesOnlyOutstandingJobs(t *testing.T) {
+	tracker := NewJobTracker(testJobOptions)
+	first := tracker.Start(JOB_LB_REQUEST, "req-1", "test", "ACCEPTED", time.Minute, scriptedPoller())
+	if again := tracker.Start(JOB_LB_REQUEST, "req-1", "test", "ACCEPTED", time.Minute, scriptedPoller()); again != first {
+		t.Errorf("an outstanding job started again as %s, want %s", again, first)
+	}
+
+	if err := tracker.Wait(first); err != nil {
+		t.Fatalf("Wait: %s", err.Error())
+	}
+	if again := tracker.Start(JOB_LB_REQUEST, "req-1", "test", "ACCEPTED", time.Minute, scriptedPoller()); again == first {
+		t.Errorf("a job that is over was returned again")
+	}
+
+	noRef := tracker.Start(JOB_LB_REQUEST, "", "test", "ACCEPTED", time.Minute, scriptedPoller())
+	if again := tracker.Start(JOB_LB_REQUEST, "", "test", "ACCEPTED", time.Minute, scriptedPoller()); again == noRef {
+		t.Errorf("two jobs without a ref were one, %s", noRef)
+	}
+}
+
+func TestJobWaitStopsOnClientError(t *testing.T) {
+	tracker := NewJobTracker(testJobOptions)
+	id := tracker.Start(JOB_OPERATION, "op-1", "test", "notStarted", time.Minute,
+		scriptedPoller(nil, makeError("HTTP call failed", 404, nil)))
+
+	err := tracker.Wait(id)
+	if herr, ok := err.(HttpError); !ok || (herr.Code() != 404) {
+		t.Fatalf("Wait = %v, want the 404", err)
+	}
+	if job, _ := tracker.Get(id); !job.Outstanding() {
+		t.Errorf("a job that couldn't be asked about is %s", job.State)
+	}
+}
+
+func TestJobTimeoutReportsLastPollError(t *testing.T) {
+	tracker := NewJobTracker(testJobOptions)
+	id := tracker.Start(JOB_OPERATION, "op-1", "test", "notStarted", 20*time.Millisecond, func() (JobPoll, error) {
+		return JobPoll{}, makeError("HTTP call failed", 502, nil)
+	})
+
+	err := tracker.Wait(id)
+	if (err == nil) || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "last poll: HTTP call failed") {
+		t.Fatalf("Wait = %v", err)
+	}
+}
+
+// with the mock both APIs are one host, v2 calls such as operation status still aren't cached
+func TestCacheTTLWhenBothAPIsShareAHost(t *testing.T) {
+	cfg := newClcConfig(WithServers("127.0.0.1:8443", "127.0.0.1:8443"))
+	cache := NewResponseCache(defaultCacheOptions)
+
+	if ttl := cache.ttlFor(cfg, cfg.serverAPIV2, "/v2/operations/TEST/status/op-1"); ttl != 0 {
+		t.Errorf("operation status cached for %s", ttl)
+	}
+	if ttl := cache.ttlFor(cfg, cfg.serverLB, "/TEST/WA1/loadbalancers"); ttl != defaultCacheOptions.LoadBalancerTTL {
+		t.Errorf("LB list cached for %s", ttl)
+	}
+}
+
+// TrackLB polls past the cache, which would keep answering "building"
+func TestTrackLBSkipsCache(t *testing.T) {
+	clc, mock := newMockClient(t)
+	ttl := 200 * time.Millisecond
+	clc.configure(WithCache(NewResponseCache(CacheOptions{LoadBalancerTTL: ttl})), WithJobs(NewJobTracker(testJobOptions)))
+	mock.Backend().SetProvisioningPolls(3)
+
+	info, err := clc.createLB("WA1", "web", "frontends")
+	if err != nil {
+		t.Fatalf("createLB: %s", err.Error())
+	}
+
+	// well past the create, the cache serves what it has without asking: provisioning
+	time.Sleep(ttl + 50*time.Millisecond)
+	if _, herr := clc.inspectLB("WA1", info.LBID); herr != nil {
+		t.Fatalf("inspectLB: %s", herr.Error())
+	}
+
+	id := TrackLB(clc, "WA1", info.LBID, info.Status, ttl/2)
+	if err := clc.jobTracker().Wait(id); err != nil {
+		t.Fatalf("Wait: %s", err.Error())
+	}
+}
+
+// a pool request still ACCEPTED is polled until it is COMPLETE, through the real client and the mock
+func TestTrackLBRequestPollsThroughMock(t *testing.T) {
+	clc, mock := newMockClient(t)
+	clc.configure(WithJobs(NewJobTracker(testJobOptions)))
+
+	info, err := clc.createLB("WA1", "web", "frontends")
+	if err != nil {
+		t.Fatalf("createLB: %s", err.Error())
+	}
+	pool, err := clc.createPool("WA1", info.LBID, &PoolDetails{IncomingPort: 80, Method: "roundrobin", Mode: "tcp",
+		Nodes: []PoolNode{{TargetIP: "10.0.0.1", TargetPort: 8080}}})
+	if err != nil {
+		t.Fatalf("createPool: %s", err.Error())
+	}
+
+	mock.Backend().SetOperationPolls(2)
+	pool.Nodes = append(pool.Nodes, PoolNode{TargetIP: "10.0.0.2", TargetPort: 8080})
+	updated, err := clc.updatePool("WA1", info.LBID, pool)
+	if err != nil {
+		t.Fatalf("updatePool: %s", err.Error())
+	}
+	if (updated.Request == nil) || (updated.Request.RequestID == "") || (updated.Request.Status != "ACCEPTED") {
+		t.Fatalf("updatePool request = %+v", updated.Request)
+	}
+
+	id := TrackLBRequest(clc, updated.Request, time.Minute)
+	if err := clc.jobTracker().Wait(id); err != nil {
+		t.Fatalf("Wait: %s", err.Error())
+	}
+	if job, _ := clc.jobTracker().Get(id); (job.Status != "COMPLETE") || (job.Polls != 3) {
+		t.Errorf("job = %+v, want COMPLETE after 3 polls", job)
+	}
+}
+
+// a request is polled where its status link says, on the LB server whatever host the link names, and
+// one without links isn't tracked at all
+func TestTrackLBRequestFollowsItsLink(t *testing.T) {
+	mock := NewMockServer("TEST", "testuser", "testpassword", DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"})
+	var polls atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.URL.Path == "/lbapi/v1/status/req-42") && (r.URL.RawQuery == "verbose=1") {
+			status := "IN_PROGRESS"
+			if polls.Add(1) > 1 {
+				status = "COMPLETE"
+			}
+			mockJSON(w, http.StatusOK, &lbCreateRequestJSON{LBID: "req-42", Status: status, Links: ApiLinks{}})
+			return
+		}
+
+		if (r.Method == "PUT") && strings.Contains(r.URL.Path, "/pools/") { // made, but answered with a request of our own
+			mock.ServeHTTP(httptest.NewRecorder(), r)
+			mockJSON(w, http.StatusAccepted, &lbCreateRequestJSON{LBID: "req-42", Status: "ACCEPTED", Links: ApiLinks{
+				LinkJSON{Rel: "status", Href: "https://elsewhere.example/lbapi/v1/status/req-42?verbose=1"}}})
+			return
+		}
+
+		if r.Method == "DELETE" { // a request without links
+			mock.ServeHTTP(httptest.NewRecorder(), r)
+			mockJSON(w, http.StatusAccepted, &lbCreateRequestJSON{LBID: "req-43", Status: "ACCEPTED", Links: ApiLinks{}})
+			return
+		}
+
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	addr := srv.Listener.Addr().String()
+	clc, err := ClientLogin("testuser", "testpassword", WithServers(addr, addr), WithRateLimit(RateLimit{}, RateLimit{}),
+		WithLogger(NewTextLogger(io.Discard, nil)), WithJobs(NewJobTracker(testJobOptions)))
+	if err != nil {
+		t.Fatalf("ClientLogin: %s", err.Error())
+	}
+
+	info, err := clc.createLB("WA1", "web", "frontends")
+	if err != nil {
+		t.Fatalf("createLB: %s", err.Error())
+	}
+	pool, err := clc.createPool("WA1", info.LBID, &PoolDetails{IncomingPort: 80, Method: "roundrobin", Mode: "tcp"})
+	if err != nil {
+		t.Fatalf("createPool: %s", err.Error())
+	}
+
+	updated, err := clc.updatePool("WA1", info.LBID, pool)
+	if err != nil {
+		t.Fatalf("updatePool: %s", err.Error())
+	}
+	if updated.Request.Href != "https://elsewhere.example/lbapi/v1/status/req-42?verbose=1" {
+		t.Fatalf("updatePool request = %+v", updated.Request)
+	}
+	if err := WaitLBRequest(clc, updated.Request, time.Minute); err != nil {
+		t.Fatalf("WaitLBRequest: %s", err.Error())
+	}
+	if n := polls.Load(); n != 2 {
+		t.Errorf("status link polled %d times, want 2", n)
+	}
+
+	deleted, err := clc.deletePool("WA1", info.LBID, pool.PoolID)
+	if err != nil {
+		t.Fatalf("deletePool: %s", err.Error())
+	}
+	if id := TrackLBRequest(clc, deleted, time.Minute); id != "" {
+		t.Errorf("a request without links was tracked as %s", id)
+	}
+}
+
+// apply waits for every pool change; a request that is COMPLETE when made isn't polled
+func TestApplyPlanWaitsForPoolRequests(t *testing.T) {
+	f := NewFakeClient("TEST", DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"})
+	f.configure(WithJobs(NewJobTracker(testJobOptions)))
+	f.SetOperationPolls(1)
+
+	doc := &ConfigDocument{LoadBalancers: []LBConfig{{DataCenter: "WA1", Name: "web", Pools: []PoolConfig{
+		{Port: 80, Nodes: []PoolNode{{TargetIP: "10.0.0.1", TargetPort: 8080}}},
+		{Port: 443, Nodes: []PoolNode{{TargetIP: "10.0.0.1", TargetPort: 8443}}},
+	}}}}
+	plan, err := PlanConfig(f, doc)
+	if err != nil {
+		t.Fatalf("PlanConfig: %s", err.Error())
+	}
+	if err := ApplyPlan(f, plan, nil); err != nil {
+		t.Fatalf("ApplyPlan: %s", err.Error())
+	}
+
+	done := 0
+	for _, job := range f.jobTracker().List(true) {
+		if (job.Kind == JOB_LB_REQUEST) && (job.State == JOB_SUCCEEDED) {
+			done++
+		}
+	}
+	if done != 2 {
+		t.Errorf("%d pool requests waited for, want 2", done)
+	}
+	if n := f.CallCount("inspectLBRequest"); n != 4 {
+		t.Errorf("CallCount(inspectLBRequest) = %d, want 4", n)
+	}
+
+	f.SetOperationPolls(0)
+	pool, err := f.createPool("WA1", plan.LoadBalancers[0].LBID, &PoolDetails{IncomingPort: 8080, Method: "roundrobin", Mode: "tcp"})
+	if err != nil {
+		t.Fatalf("createPool: %s", err.Error())
+	}
+	if err := f.jobTracker().Wait(TrackLBRequest(f, pool.Request, time.Minute)); err != nil {
+		t.Fatalf("Wait: %s", err.Error())
+	}
+	if n := f.CallCount("inspectLBRequest"); n != 4 {
+		t.Errorf("a COMPLETE request was polled, CallCount(inspectLBRequest) = %d", n)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//// Logger is what the SDK writes through.  *slog.Logger satisfies it, so any slog handler can be
+//// plugged in with WithLogger(slog.New(h)) on a client, or SetLogger for what isn't tied to a client
+//// (MockServer, cassettes).  The loggers made here redact secrets before writing:
+//// attributes named like a password or token, Authorization headers, and "password"/"bearerToken"
+//// values inside JSON bodies.  HTTP dumps are redacted by the client before they are logged at all, so
+//// they are safe with any handler; for the rest, handlers plugged in from outside do their own.
+
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+const LOG_REDACTED = "REDACTED"
+
+var sdkLogger Logger = NewTextLogger(os.Stderr, nil)
+
+// SetLogger replaces the package logger, nil restores the default text logger on stderr
+func SetLogger(l Logger) {
+	if l == nil {
+		l = NewTextLogger(os.Stderr, nil)
+	}
+	sdkLogger = l
+}
+
+func sdkLog(s string) { // formerly the gateway to glog.Info
+	sdkLogger.Info(s)
+}
+
+// one line per record, "msg key=value ...".  Multi-line values (HTTP dumps) follow on their own lines.
+// level may be a *slog.LevelVar to change later, nil means Info.
+func NewTextLogger(w io.Writer, level slog.Leveler) Logger {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return slog.New(&textLogHandler{w: w, mu: new(sync.Mutex), level: level})
+}
+
+// slog's JSON records, one per line
+func NewJSONLogger(w io.Writer, level slog.Leveler) Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}))
+}
+
+//////////////// redaction
+
+var secretJSONFieldRE = regexp.MustCompile(`("(?i:password|bearerToken)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+var authorizationRE = regexp.MustCompile(`(?im)^(Authorization:[ \t]*)[^\r\n]*`)
+var bearerRE = regexp.MustCompile(`(Bearer[ \t]+)[^\s"]+`)
+
+// replaces the value of any "password" or "bearerToken" field in a JSON text
+func redactJSONSecrets(s, replacement string) string {
+	return secretJSONFieldRE.ReplaceAllString(s, `${1}"`+replacement+`"`)
+}
+
+// redactSecrets cleans free text such as an HTTP dump
+func redactSecrets(s string) string {
+	s = authorizationRE.ReplaceAllString(s, "${1}"+LOG_REDACTED)
+	s = bearerRE.ReplaceAllString(s, "${1}"+LOG_REDACTED)
+	return redactJSONSecrets(s, LOG_REDACTED)
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "password") || strings.Contains(key, "token") || (key == "authorization")
+}
+
+// slog ReplaceAttr hook
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if isSecretKey(a.Key) {
+		return slog.String(a.Key, LOG_REDACTED)
+	}
+
+	if a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, redactSecrets(a.Value.String()))
+	}
+
+	return a
+}
+
+//////////////// text handler
+
+type textLogHandler struct {
+	w      io.Writer
+	mu     *sync.Mutex // shared with handlers made by WithAttrs, they write to the same w
+	level  slog.Leveler
+	attrs  []slog.Attr
+	prefix string // from WithGroup
+}
+
+func (h *textLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textLogHandler) Handle(_ context.Context, r slog.Record) error {
+	line := redactSecrets(r.Message)
+	if r.Level >= slog.LevelWarn {
+		line = r.Level.String() + ": " + line
+	}
+	trailer := ""
+
+	add := func(a slog.Attr) {
+		a = redactAttr(nil, a)
+		value := a.Value.String()
+		if strings.Contains(value, "\n") {
+			trailer += "\n" + strings.TrimRight(value, "\n")
+		} else {
+			line += fmt.Sprintf(" %s%s=%s", h.prefix, a.Key, value)
+		}
+	}
+
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		add(a)
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintf(h.w, "%s%s\n", line, trailer)
+	return err
+}
+
+func (h *textLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	ret := *h
+	ret.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &ret
+}
+
+func (h *textLogHandler) WithGroup(name string) slog.Handler {
+	ret := *h
+	ret.prefix = h.prefix + name + "."
+	return &ret
+}
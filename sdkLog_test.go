@@ -0,0 +1,64 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// the client redacts its HTTP dumps itself, a plain slog handler from outside gets no secrets
+func TestDumpsRedactedForAnyHandler(t *testing.T) {
+	mock := NewMockServer("TEST", "testuser", "testpassword", DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"})
+	srv, err := mock.StartTLS("")
+	if err != nil {
+		t.Fatalf("StartTLS: %s", err.Error())
+	}
+	t.Cleanup(srv.Close)
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	addr := srv.Listener.Addr().String()
+	clc, err := ClientLogin("testuser", "testpassword", WithServers(addr, addr), WithRateLimit(RateLimit{}, RateLimit{}),
+		WithLogger(logger), WithDebug(true, true)) // the login response, with its token, is dumped
+	if err != nil {
+		t.Fatalf("ClientLogin: %s", err.Error())
+	}
+
+	token := strings.TrimPrefix(strings.Split(clc.exportEnv(), "\n")[0], "export CLC_API_TOKEN=")
+	if token == "" {
+		t.Fatalf("no token in %q", clc.exportEnv())
+	}
+
+	if _, herr := clc.inspectLB("WA1", "nosuchlb"); herr == nil { // dumped while debugging
+		t.Fatalf("inspectLB of an unknown LB succeeded")
+	}
+	clc.configure(WithDebug(false, false))
+	if _, herr := clc.inspectLB("WA1", "nosuchlb"); herr == nil { // dumped as the failure's Warn
+		t.Fatalf("inspectLB of an unknown LB succeeded")
+	}
+
+	logged := out.String()
+	if !strings.Contains(logged, "HTTP call failed") || !strings.Contains(logged, LOG_REDACTED) {
+		t.Fatalf("expected redacted dumps, logged:\n%s", logged)
+	}
+	if strings.Contains(logged, token) || strings.Contains(logged, "testpassword") {
+		t.Errorf("secrets logged:\n%s", logged)
+	}
+}
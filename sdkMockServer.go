@@ -0,0 +1,1189 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//// MockServer answers the same HTTP calls clcImpl makes, so the real client code path (JSON
+//// mapping, bearer tokens, 401 reauth) can run with no network.  One handler serves both the v2
+//// API and the LBaaS API, their paths don't overlap:
+////
+////   POST   /v2/authentication/login
+////   GET    /v2/datacenters/{acct}
+////   GET    /v2/datacenters/{acct}/{dc}                 with a link to the root group
+////   GET    /v2/groups/{acct}/{id}                      with subgroups and server links
+////   GET    /v2/servers/{acct}/{id}
+////   GET    /{acct}/loadbalancers
+////   GET    /{acct}/{dc}/loadbalancers                  POST creates
+////   GET    /{acct}/{dc}/loadbalancers/{id}             DELETE deletes
+////   GET    /{acct}/{dc}/loadbalancers/{id}/pools       POST creates
+////   GET    /{acct}/{dc}/loadbalancers/{id}/pools/{id}  PUT updates, DELETE deletes
+////   GET    /{acct}/{dc}/requests/{id}                  how a pool change is getting on
+////
+//// GET responses carry an ETag and honor If-None-Match.
+//// LB, pool, group and server state lives in a FakeClient, optionally saved to a JSON file after every change.
+
+type MockServer struct {
+	mu sync.Mutex
+
+	backend  *FakeClient
+	username string
+	password string
+	account  string
+
+	tokens    map[string]time.Time // bearer token -> issued
+	tokenSeq  int
+	tokenTTL  time.Duration // 0 means tokens don't expire by age
+	logins    int
+	statePath string
+}
+
+// NewMockServer accepts one user, who belongs to the account
+func NewMockServer(account, username, password string, dcs ...DataCenterName) *MockServer {
+	return &MockServer{
+		backend:  NewFakeClient(account, dcs...),
+		username: username,
+		password: password,
+		account:  account,
+		tokens:   make(map[string]time.Time),
+	}
+}
+
+// Backend is the FakeClient holding the state, for seeding it or injecting failures
+func (m *MockServer) Backend() *FakeClient {
+	return m.backend
+}
+
+// SetStateFile loads state from path if the file exists, and saves to it after every change
+func (m *MockServer) SetStateFile(path string) error {
+	err := m.backend.LoadState(path)
+	if (err != nil) && !os.IsNotExist(err) {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statePath = path
+	return nil
+}
+
+// SetTokenTTL makes tokens older than ttl get 401, like real expiry
+func (m *MockServer) SetTokenTTL(ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokenTTL = ttl
+}
+
+// ExpireTokens makes every token issued so far get 401, so the client has to log in again
+func (m *MockServer) ExpireTokens() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = make(map[string]time.Time)
+}
+
+// Logins counts successful logins, e.g. to check that a reauth happened
+func (m *MockServer) Logins() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.logins
+}
+
+// StartTLS serves on addr ("" for a random local port) with a self-signed certificate, which
+// clcImpl accepts.  Point a client at it with WithServers(srv.Listener.Addr().String(), same).
+func (m *MockServer) StartTLS(addr string) (*httptest.Server, error) {
+	if addr == "" {
+		return httptest.NewTLSServer(m), nil
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := httptest.NewUnstartedServer(m)
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.StartTLS()
+	return srv, nil
+}
+
+//////////////// request handling
+
+// GETs get an ETag, and 304 when If-None-Match has it, the way a caching-aware server would
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		m.serve(w, r)
+		return
+	}
+
+	rec := httptest.NewRecorder()
+	m.serve(rec, r)
+
+	for key, values := range rec.Header() {
+		w.Header()[key] = values
+	}
+
+	if rec.Code == http.StatusOK {
+		etag := fmt.Sprintf("\"%x\"", sha1.Sum(rec.Body.Bytes()))
+		w.Header().Set("ETag", etag)
+
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	w.WriteHeader(rec.Code)
+	w.Write(rec.Body.Bytes())
+}
+
+func (m *MockServer) serve(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if (r.URL.Path == "/v2/authentication/login") && (r.Method == "POST") {
+		m.handleLogin(w, r)
+		return
+	}
+
+	if !m.authorized(r) {
+		mockStatus(w, http.StatusUnauthorized)
+		return
+	}
+
+	if (len(parts) == 3) && (parts[0] == "v2") && (parts[1] == "datacenters") {
+		m.handleDatacenters(w, r, parts[2])
+		return
+	}
+
+	if (len(parts) >= 4) && (parts[0] == "v2-experimental") && (parts[1] == "networks") {
+		m.handleNetworks(w, r, parts[2], parts[3], parts[4:])
+		return
+	}
+
+	if (len(parts) >= 4) && (parts[0] == "v2-experimental") {
+		m.handleFirewall(w, r, parts[1], parts[2], parts[3], parts[4:])
+		return
+	}
+
+	if (len(parts) >= 4) && (parts[0] == "v2") {
+		m.handleV2(w, r, parts[1], parts[2], parts[3:])
+		return
+	}
+
+	if (len(parts) == 3) && (parts[0] == "v2") && (parts[1] == "servers") { // POST, a new server
+		m.handleV2(w, r, parts[1], parts[2], nil)
+		return
+	}
+
+	if (len(parts) >= 2) && (parts[0] != m.account) {
+		mockStatus(w, http.StatusForbidden) // someone else's account
+		return
+	}
+
+	if (len(parts) == 2) && (parts[1] == "loadbalancers") && (r.Method == "GET") {
+		m.handleListLB(w, "")
+		return
+	}
+
+	if (len(parts) == 4) && (parts[2] == "requests") && (r.Method == "GET") {
+		m.handleLBRequest(w, parts[1], parts[3])
+		return
+	}
+
+	if (len(parts) >= 3) && (parts[2] == "loadbalancers") {
+		m.handleLB(w, r, parts[1], parts[3:])
+		return
+	}
+
+	mockStatus(w, http.StatusNotFound)
+}
+
+func (m *MockServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	req := AuthLoginRequestJSON{}
+	if json.NewDecoder(r.Body).Decode(&req) != nil {
+		mockStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	if (req.Username != m.username) || (req.Password != m.password) {
+		mockStatus(w, http.StatusBadRequest) // what the real API does for bad credentials
+		return
+	}
+
+	m.mu.Lock()
+	m.tokenSeq++
+	m.logins++
+	token := fmt.Sprintf("mock-token-%d-%d", m.tokenSeq, time.Now().UnixNano())
+	m.tokens[token] = time.Now()
+	m.mu.Unlock()
+
+	mockJSON(w, http.StatusOK, AuthLoginResponseJSON{
+		Username:      m.username,
+		AccountAlias:  m.account,
+		LocationAlias: "WA1",
+		Roles:         []string{"AccountAdmin"},
+		BearerToken:   token,
+	})
+}
+
+func (m *MockServer) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	issued, found := m.tokens[token]
+	if !found {
+		return false
+	}
+
+	if (m.tokenTTL > 0) && (time.Since(issued) > m.tokenTTL) {
+		delete(m.tokens, token)
+		return false
+	}
+
+	return true
+}
+
+func (m *MockServer) handleDatacenters(w http.ResponseWriter, r *http.Request, acct string) {
+	if acct != m.account {
+		mockStatus(w, http.StatusForbidden)
+		return
+	}
+
+	dcs, err := m.backend.listAllDC()
+	if err != nil {
+		mockError(w, err)
+		return
+	}
+
+	ret := make([]dcNamesJSON, len(dcs))
+	for idx, dc := range dcs {
+		ret[idx] = dcNamesJSON{ID: strings.ToLower(dc.DCID), Name: dc.Name} // the real API sends lowercase
+	}
+
+	mockJSON(w, http.StatusOK, ret)
+}
+
+// /v2/{resource}/{acct}/{rest...}
+func (m *MockServer) handleV2(w http.ResponseWriter, r *http.Request, resource, acct string, rest []string) {
+	if acct != m.account {
+		mockStatus(w, http.StatusForbidden)
+		return
+	}
+
+	if (resource == "servers") && (len(rest) >= 2) && (rest[1] == "publicIPAddresses") {
+		m.handlePublicIP(w, r, rest[0], rest[2:])
+		return
+	}
+
+	if (r.Method == "POST") && (resource == "operations") && (len(rest) == 2) && (rest[0] == "servers") {
+		m.handleServerOperation(w, r, rest[1])
+		return
+	}
+
+	if (r.Method == "POST") && (resource == "servers") && (len(rest) == 0) {
+		m.handleServerCreate(w, r)
+		return
+	}
+
+	if (r.Method == "GET") && (resource == "datacenters") && (len(rest) == 2) && (rest[1] == "deploymentCapabilities") {
+		caps, err := m.backend.deploymentCapabilities(rest[0])
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		mockJSON(w, http.StatusOK, capabilitiesToJSON(caps))
+		return
+	}
+
+	if (r.Method == "GET") && (resource == "datacenters") && (len(rest) == 2) && (rest[1] == "bareMetalCapabilities") {
+		bm, err := m.backend.bareMetalCapabilities(rest[0])
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		mockJSON(w, http.StatusOK, bareMetalToJSON(bm))
+		return
+	}
+
+	if (r.Method == "GET") && (resource == "operations") && (len(rest) == 2) && (rest[0] == "status") {
+		status, err := m.backend.operationStatus(rest[1])
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		mockJSON(w, http.StatusOK, operationStatusJSON{Status: status})
+		return
+	}
+
+	if (r.Method != "GET") || (len(rest) != 1) {
+		mockStatus(w, http.StatusNotFound)
+		return
+	}
+
+	switch resource {
+	case "datacenters":
+		dc := NormalizeDC(rest[0])
+		rootID, err := m.backend.rootGroupID(dc)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		ret := dcDetailsJSON{ID: strings.ToLower(dc), Name: dc, Links: v2Links{
+			v2LinkJSON{Rel: "self", Href: fmt.Sprintf("/v2/datacenters/%s/%s", m.account, strings.ToLower(dc))},
+		}}
+		if r.URL.Query().Get("groupLinks") == "true" {
+			ret.Links = append(ret.Links, v2LinkJSON{Rel: "group", Href: m.groupHref(rootID), ID: rootID})
+		}
+		mockJSON(w, http.StatusOK, ret)
+
+	case "groups":
+		group, err := m.backend.inspectGroup(rest[0])
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		mockJSON(w, http.StatusOK, m.groupJSON(group))
+
+	case "servers":
+		var server *ServerDetails
+		var err error
+		if r.URL.Query().Get("uuid") == "true" {
+			server, err = m.backend.inspectServerUUID(rest[0])
+		} else {
+			server, err = m.backend.inspectServer(rest[0])
+		}
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		mockJSON(w, http.StatusOK, m.serverJSON(server))
+
+	default:
+		mockStatus(w, http.StatusNotFound)
+	}
+}
+
+// /v2/servers/{acct}/{server}/publicIPAddresses[/{ip}].  Changes answer with a link to their operation.
+func (m *MockServer) handlePublicIP(w http.ResponseWriter, r *http.Request, serverID string, rest []string) {
+	var operationID string
+	var err error
+
+	switch {
+	case (len(rest) == 1) && (r.Method == "GET"):
+		ip, err := m.backend.inspectPublicIP(serverID, rest[0])
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		mockJSON(w, http.StatusOK, publicIPToJSON(ip))
+		return
+
+	case (len(rest) == 0) && (r.Method == "POST"), (len(rest) == 1) && (r.Method == "PUT"):
+		req := publicIPJSON{}
+		if json.NewDecoder(r.Body).Decode(&req) != nil {
+			mockStatus(w, http.StatusBadRequest)
+			return
+		}
+
+		if len(rest) == 0 {
+			operationID, err = m.backend.addPublicIP(serverID, publicIPFromJSON(&req, serverID, ""))
+		} else {
+			operationID, err = m.backend.updatePublicIP(serverID, publicIPFromJSON(&req, serverID, rest[0]))
+		}
+
+	case (len(rest) == 1) && (r.Method == "DELETE"):
+		operationID, err = m.backend.deletePublicIP(serverID, rest[0])
+
+	default:
+		mockStatus(w, http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		mockError(w, err)
+		return
+	}
+
+	m.saveState()
+	mockJSON(w, http.StatusAccepted, m.operationLink(operationID))
+}
+
+// /v2-experimental/{firewallPolicies|crossDcFirewallPolicies}/{acct}/{dc}[/{policyID}]
+func (m *MockServer) handleFirewall(w http.ResponseWriter, r *http.Request, resource, acct, dc string, rest []string) {
+	if acct != m.account {
+		mockStatus(w, http.StatusForbidden)
+		return
+	}
+
+	if ((resource != "firewallPolicies") && (resource != "crossDcFirewallPolicies")) || (len(rest) > 1) {
+		mockStatus(w, http.StatusNotFound)
+		return
+	}
+
+	crossDC := (resource == "crossDcFirewallPolicies")
+	policyID := ""
+	if len(rest) == 1 {
+		policyID = rest[0]
+	}
+
+	switch {
+	case (policyID == "") && (r.Method == "GET"):
+		policies, err := m.backend.listFirewallPolicies(dc, crossDC)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		if crossDC {
+			ret := make([]crossDCFirewallPolicyJSON, len(policies))
+			for idx := range policies {
+				ret[idx] = *m.crossDCFirewallJSON(&policies[idx])
+			}
+			mockJSON(w, http.StatusOK, ret)
+		} else {
+			ret := make([]firewallPolicyJSON, len(policies))
+			for idx := range policies {
+				ret[idx] = *m.firewallJSON(&policies[idx])
+			}
+			mockJSON(w, http.StatusOK, ret)
+		}
+
+	case (policyID != "") && (r.Method == "GET"):
+		policy, err := m.backend.inspectFirewallPolicy(dc, crossDC, policyID)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		if crossDC {
+			mockJSON(w, http.StatusOK, m.crossDCFirewallJSON(policy))
+		} else {
+			mockJSON(w, http.StatusOK, m.firewallJSON(policy))
+		}
+
+	case (policyID == "") && (r.Method == "POST"):
+		policy := &FirewallPolicy{CrossDC: crossDC}
+		if crossDC {
+			req := crossDCFirewallPolicyJSON{}
+			if json.NewDecoder(r.Body).Decode(&req) != nil {
+				mockStatus(w, http.StatusBadRequest)
+				return
+			}
+			policy.Enabled = req.Enabled
+			policy.Sources = []string{req.SourceCIDR}
+			policy.Destinations = []string{req.DestinationCIDR}
+			policy.DestinationAccount = req.DestinationAccountID
+			policy.DestinationDC = req.DestinationLocationID
+		} else {
+			req := firewallPolicyJSON{}
+			if json.NewDecoder(r.Body).Decode(&req) != nil {
+				mockStatus(w, http.StatusBadRequest)
+				return
+			}
+			policy = firewallFromJSON(&req, dc)
+		}
+
+		id, err := m.backend.createFirewallPolicy(dc, policy)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		m.saveState()
+
+		created, err := m.backend.inspectFirewallPolicy(dc, crossDC, id)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		if crossDC {
+			mockJSON(w, http.StatusCreated, m.crossDCFirewallJSON(created))
+		} else {
+			mockJSON(w, http.StatusCreated, firewallPolicyJSON{Links: m.firewallJSON(created).Links})
+		}
+
+	case (policyID != "") && (r.Method == "PUT"):
+		policy := &FirewallPolicy{PolicyID: policyID, CrossDC: crossDC}
+		if crossDC {
+			policy.Enabled = (r.URL.Query().Get("enabled") == "true")
+		} else {
+			req := firewallPolicyJSON{}
+			if json.NewDecoder(r.Body).Decode(&req) != nil {
+				mockStatus(w, http.StatusBadRequest)
+				return
+			}
+			policy = firewallFromJSON(&req, dc)
+			policy.PolicyID = policyID
+		}
+
+		if err := m.backend.updateFirewallPolicy(dc, policy); err != nil {
+			mockError(w, err)
+			return
+		}
+		m.saveState()
+		mockStatus(w, http.StatusNoContent)
+
+	case (policyID != "") && (r.Method == "DELETE"):
+		if err := m.backend.deleteFirewallPolicy(dc, crossDC, policyID); err != nil {
+			mockError(w, err)
+			return
+		}
+		m.saveState()
+		mockStatus(w, http.StatusNoContent)
+
+	default:
+		mockStatus(w, http.StatusNotFound)
+	}
+}
+
+// /v2-experimental/networks/{acct}/{dc}[/claim | /{networkID}[/release]]
+func (m *MockServer) handleNetworks(w http.ResponseWriter, r *http.Request, acct, dc string, rest []string) {
+	if acct != m.account {
+		mockStatus(w, http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case (len(rest) == 0) && (r.Method == "GET"):
+		networks, err := m.backend.listNetworks(dc)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		ret := make([]networkJSON, len(networks))
+		for idx := range networks {
+			ret[idx] = m.networkJSON(&networks[idx])
+		}
+		mockJSON(w, http.StatusOK, ret)
+
+	case (len(rest) == 1) && (rest[0] == "claim") && (r.Method == "POST"):
+		operationID, err := m.backend.claimNetwork(dc)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		m.saveState()
+		mockJSON(w, http.StatusAccepted, networkClaimJSON{OperationID: operationID, URI: m.operationLink(operationID).Href})
+
+	case (len(rest) == 1) && (r.Method == "GET"):
+		ipAddresses := r.URL.Query().Get("ipAddresses")
+		if ipAddresses == "" {
+			ipAddresses = NETWORK_IPS_NONE
+		}
+
+		network, err := m.backend.inspectNetwork(dc, rest[0], ipAddresses)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+		mockJSON(w, http.StatusOK, m.networkJSON(network))
+
+	case (len(rest) == 2) && (rest[1] == "release") && (r.Method == "POST"):
+		if err := m.backend.releaseNetwork(dc, rest[0]); err != nil {
+			mockError(w, err)
+			return
+		}
+		m.saveState()
+		mockStatus(w, http.StatusNoContent)
+
+	default:
+		mockStatus(w, http.StatusNotFound)
+	}
+}
+
+func (m *MockServer) networkJSON(network *NetworkDetails) networkJSON {
+	ret := networkJSON{
+		ID:          network.NetworkID,
+		CIDR:        network.CIDR,
+		Description: network.Description,
+		Gateway:     network.Gateway,
+		Name:        network.Name,
+		Netmask:     network.Netmask,
+		Type:        network.Type,
+		VLAN:        network.VLAN,
+		Links: v2Links{v2LinkJSON{Rel: "self",
+			Href: fmt.Sprintf("/v2-experimental/networks/%s/%s/%s", m.account, strings.ToLower(network.DataCenter), network.NetworkID)}},
+	}
+
+	for _, ip := range network.IPAddresses {
+		ret.IPAddresses = append(ret.IPAddresses, networkIPJSON{Address: ip.Address, Claimed: ip.Claimed, Server: ip.ServerID, Type: ip.Type})
+	}
+
+	return ret
+}
+
+func (m *MockServer) firewallJSON(policy *FirewallPolicy) *firewallPolicyJSON {
+	ret := firewallToJSON(policy)
+	ret.ID = policy.PolicyID
+	ret.Status = policy.Status
+	ret.Links = v2Links{v2LinkJSON{Rel: "self",
+		Href: fmt.Sprintf("/v2-experimental/firewallPolicies/%s/%s/%s", m.account, strings.ToLower(policy.DataCenter), policy.PolicyID)}}
+	return ret
+}
+
+func (m *MockServer) crossDCFirewallJSON(policy *FirewallPolicy) *crossDCFirewallPolicyJSON {
+	return &crossDCFirewallPolicyJSON{
+		ID:                  policy.PolicyID,
+		Status:              policy.Status,
+		Enabled:             policy.Enabled,
+		SourceCIDR:          policy.Sources[0],
+		SourceAccount:       strings.ToLower(m.account),
+		SourceLocation:      strings.ToLower(policy.DataCenter),
+		DestinationCIDR:     policy.Destinations[0],
+		DestinationAccount:  strings.ToLower(policy.DestinationAccount),
+		DestinationLocation: strings.ToLower(policy.DestinationDC),
+		Links: v2Links{v2LinkJSON{Rel: "self",
+			Href: fmt.Sprintf("/v2-experimental/crossDcFirewallPolicies/%s/%s/%s", m.account, strings.ToLower(policy.DataCenter), policy.PolicyID)}},
+	}
+}
+
+// the body is a list of server IDs, the answer says server by server what was queued
+func (m *MockServer) handleServerOperation(w http.ResponseWriter, r *http.Request, operation string) {
+	ids := make([]string, 0)
+	if json.NewDecoder(r.Body).Decode(&ids) != nil {
+		mockStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	results, err := m.backend.serverOperation(operation, ids)
+	if err != nil {
+		mockError(w, err)
+		return
+	}
+	m.saveState()
+
+	ret := make([]serverOperationJSON, len(results))
+	for idx, result := range results {
+		ret[idx] = serverOperationJSON{Server: result.ServerID, IsQueued: result.Queued, ErrorMessage: result.Error}
+		if result.Queued {
+			ret[idx].Links = v2Links{m.operationLink(result.OperationID)}
+		}
+	}
+	mockJSON(w, http.StatusOK, ret)
+}
+
+// POST /v2/servers/{acct}.  The answer links to the operation, and to the server by its UUID.
+func (m *MockServer) handleServerCreate(w http.ResponseWriter, r *http.Request) {
+	req := serverCreateJSON{}
+	if json.NewDecoder(r.Body).Decode(&req) != nil {
+		mockStatus(w, http.StatusBadRequest)
+		return
+	}
+
+	info, err := m.backend.createServer(serverSpecFromJSON(&req))
+	if err != nil {
+		mockError(w, err)
+		return
+	}
+	m.saveState()
+
+	mockJSON(w, http.StatusAccepted, serverCreateResponseJSON{Server: info.Name, IsQueued: true, Links: v2Links{
+		m.operationLink(info.OperationID),
+		v2LinkJSON{Rel: "self", Href: m.serverHref(info.UUID) + "?uuid=true", ID: info.UUID},
+	}})
+}
+
+func capabilitiesToJSON(caps *DeploymentCapabilities) deploymentCapabilitiesJSON {
+	ret := deploymentCapabilitiesJSON{
+		DataCenter:                 caps.DataCenter,
+		SupportsPremiumStorage:     caps.SupportsPremiumStorage,
+		SupportsSharedLoadBalancer: caps.SupportsSharedLoadBalancer,
+		SupportsBareMetalServers:   caps.SupportsBareMetal,
+		DeployableNetworks:         make([]deployableNetworkJSON, len(caps.Networks)),
+		Templates:                  make([]templateJSON, len(caps.Templates)),
+		ImportableOSTypes:          make([]importableOSTypeJSON, len(caps.ImportableOSTypes)),
+	}
+
+	for idx, n := range caps.Networks {
+		ret.DeployableNetworks[idx] = deployableNetworkJSON{Name: n.Name, NetworkID: n.NetworkID, Type: n.Type, AccountID: n.AccountID}
+	}
+	for idx, t := range caps.Templates {
+		ret.Templates[idx] = templateJSON{Name: t.Name, OSType: t.OSType, Description: t.Description, StorageSizeGB: t.StorageSizeGB,
+			Capabilities: t.Capabilities, ReservedDrivePaths: t.ReservedDrivePaths}
+	}
+	for idx, o := range caps.ImportableOSTypes {
+		ret.ImportableOSTypes[idx] = importableOSTypeJSON{ID: o.ID, Description: o.Description, LabProductCode: o.LabProductCode,
+			PremiumProductCode: o.PremiumProductCode, Type: o.Type}
+	}
+
+	return ret
+}
+
+func bareMetalToJSON(bm *BareMetalCapabilities) bareMetalCapabilitiesJSON {
+	ret := bareMetalCapabilitiesJSON{SKUs: make([]bareMetalSKUJSON, len(bm.SKUs)), OperatingSystems: bm.OperatingSystems}
+
+	for idx, sku := range bm.SKUs {
+		ret.SKUs[idx] = bareMetalSKUJSON{
+			ID:           sku.ID,
+			HourlyRate:   sku.HourlyRate,
+			Availability: sku.Availability,
+			Memory:       []bareMetalMemoryJSON{{CapacityGB: sku.MemoryGB}},
+			Processor:    bareMetalProcessorJSON{CoresPerSocket: sku.CoresPerSocket, Description: sku.Processor, Sockets: sku.Sockets},
+			Storage:      sku.Storage,
+		}
+	}
+
+	return ret
+}
+
+func (m *MockServer) operationLink(id string) v2LinkJSON {
+	return v2LinkJSON{Rel: "status", Href: fmt.Sprintf("/v2/operations/%s/status/%s", m.account, id), ID: id}
+}
+
+func (m *MockServer) groupHref(id string) string {
+	return fmt.Sprintf("/v2/groups/%s/%s", m.account, id)
+}
+
+func (m *MockServer) serverHref(id string) string {
+	return fmt.Sprintf("/v2/servers/%s/%s", m.account, id)
+}
+
+// subgroups come in full, as the real API sends them
+func (m *MockServer) groupJSON(group *GroupDetails) groupJSON {
+	ret := groupJSON{
+		ID:           group.GroupID,
+		Name:         group.Name,
+		Description:  group.Description,
+		LocationID:   group.DataCenter,
+		Type:         group.Type,
+		Status:       "active",
+		ServersCount: len(group.ServerIDs),
+		Groups:       make([]groupJSON, len(group.Groups)),
+		Links:        v2Links{v2LinkJSON{Rel: "self", Href: m.groupHref(group.GroupID), ID: group.GroupID}},
+	}
+
+	if group.ParentID != "" {
+		ret.Links = append(ret.Links, v2LinkJSON{Rel: "parentGroup", Href: m.groupHref(group.ParentID), ID: group.ParentID})
+	}
+
+	for _, id := range group.ServerIDs {
+		ret.Links = append(ret.Links, v2LinkJSON{Rel: "server", Href: m.serverHref(id), ID: id})
+	}
+
+	for idx := range group.Groups {
+		ret.Groups[idx] = m.groupJSON(&group.Groups[idx])
+	}
+
+	return ret
+}
+
+// ipAddresses pairs each public IP with the private one it is NAT'd to, the way the API lists them
+func (m *MockServer) serverJSON(server *ServerDetails) serverJSON {
+	ips := make([]serverIPJSON, 0, len(server.PrivateIPs)+len(server.PublicIPs))
+	paired := make(map[string]bool)
+	for _, publicIP := range server.PublicIPs {
+		internal := server.PrivateIP()
+		if details, err := m.backend.inspectPublicIP(server.ServerID, publicIP); err == nil {
+			internal = details.InternalIP
+		}
+		ips = append(ips, serverIPJSON{Internal: internal, Public: publicIP})
+		paired[internal] = true
+	}
+	for _, ip := range server.PrivateIPs {
+		if !paired[ip] {
+			ips = append(ips, serverIPJSON{Internal: ip})
+		}
+	}
+
+	return serverJSON{
+		ID:          server.ServerID,
+		Name:        server.ServerID,
+		Description: server.Description,
+		GroupID:     server.GroupID,
+		LocationID:  server.DataCenter,
+		OSType:      server.OSType,
+		Status:      server.Status,
+		Details: serverHardwareJSON{
+			IPAddresses:       ips,
+			CPU:               server.CPU,
+			MemoryMB:          server.MemoryMB,
+			StorageGB:         server.StorageGB,
+			PowerState:        server.PowerState,
+			InMaintenanceMode: server.InMaintenance,
+		},
+		Links: v2Links{
+			v2LinkJSON{Rel: "self", Href: m.serverHref(server.ServerID), ID: server.ServerID},
+			v2LinkJSON{Rel: "group", Href: m.groupHref(server.GroupID), ID: server.GroupID},
+		},
+	}
+}
+
+// "links" and "values", the LBaaS envelope for listings
+type mockListingJSON struct {
+	Links  ApiLinks    `json:"links"`
+	Values interface{} `json:"values"`
+}
+
+func (m *MockServer) handleListLB(w http.ResponseWriter, dc string) {
+	lbs, err := m.backend.listAllLB()
+	if err != nil {
+		mockError(w, err)
+		return
+	}
+
+	values := make([]lbListingDetailsJSON, 0, len(lbs))
+	for _, lb := range lbs {
+		if (dc == "") || (lb.DataCenter == dc) {
+			values = append(values, lbListingDetailsJSON{
+				LBID:        lb.LBID,
+				Name:        lb.Name,
+				Description: lb.Description,
+				PublicIP:    lb.PublicIP,
+				DataCenter:  lb.DataCenter,
+			})
+		}
+	}
+
+	mockJSON(w, http.StatusOK, mockListingJSON{Links: ApiLinks{}, Values: values})
+}
+
+func (m *MockServer) handleLBRequest(w http.ResponseWriter, dc, requestID string) {
+	href := fmt.Sprintf("/%s/%s/requests/%s", m.account, NormalizeDC(dc), requestID)
+	request, err := m.backend.inspectLBRequest(&LBRequest{RequestID: requestID, Href: href})
+	if err != nil {
+		mockError(w, err)
+		return
+	}
+
+	// the backend doesn't remember what the request changed, so just the request's own link
+	mockJSON(w, http.StatusOK, &lbCreateRequestJSON{LBID: request.RequestID, Status: request.Status,
+		Description: request.Description, Links: ApiLinks{LinkJSON{Rel: "self", Href: href, ID: requestID}}})
+}
+
+// rest is whatever follows /{acct}/{dc}/loadbalancers
+func (m *MockServer) handleLB(w http.ResponseWriter, r *http.Request, dc string, rest []string) {
+	lbHref := fmt.Sprintf("/%s/%s/loadbalancers", m.account, dc)
+
+	offered, err := m.backend.lbServiceOffered(dc)
+	if err != nil {
+		mockError(w, err)
+		return
+	}
+	if !offered { // as the LB API does in a DC it isn't in
+		mockStatus(w, http.StatusNotFound)
+		return
+	}
+
+	if len(rest) == 0 {
+		if r.Method == "GET" {
+			m.handleListLB(w, dc)
+			return
+		}
+
+		if r.Method == "POST" {
+			req := struct {
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			}{}
+			if json.NewDecoder(r.Body).Decode(&req) != nil {
+				mockStatus(w, http.StatusBadRequest)
+				return
+			}
+
+			info, err := m.backend.createLB(dc, req.Name, req.Description)
+			if err != nil {
+				mockError(w, err)
+				return
+			}
+
+			m.saveState()
+			request := mockRequestJSON("create load balancer", "loadbalancer", lbHref+"/"+info.LBID, info.LBID)
+			request.Status = info.Status
+			mockJSON(w, http.StatusAccepted, request)
+			return
+		}
+
+		mockStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	lbid := rest[0]
+	lbHref += "/" + lbid
+
+	if len(rest) == 1 {
+		if r.Method == "GET" {
+			lb, err := m.backend.inspectLB(dc, lbid)
+			if err != nil {
+				mockError(w, err)
+				return
+			}
+
+			ret := lbDetailsJSON{
+				LBID:        lb.LBID,
+				Status:      lb.Status,
+				Name:        lb.Name,
+				Description: lb.Description,
+				PublicIP:    lb.PublicIP,
+				DataCenter:  lb.DataCenter,
+				Pools:       make(ApiPools, len(lb.Pools)),
+			}
+			for idx := range lb.Pools {
+				ret.Pools[idx] = mockPoolJSON(&lb.Pools[idx])
+			}
+
+			mockJSON(w, http.StatusOK, ret)
+			return
+		}
+
+		if r.Method == "DELETE" {
+			found, err := m.backend.deleteLB(dc, lbid)
+			if err != nil {
+				mockError(w, err)
+				return
+			}
+			if !found {
+				mockStatus(w, http.StatusNotFound)
+				return
+			}
+
+			m.saveState()
+			mockJSON(w, http.StatusAccepted, mockRequestJSON("delete load balancer", "loadbalancer", lbHref, lbid))
+			return
+		}
+
+		mockStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if rest[1] != "pools" {
+		mockStatus(w, http.StatusNotFound)
+		return
+	}
+
+	if len(rest) == 2 {
+		if r.Method == "GET" {
+			lb, err := m.backend.inspectLB(dc, lbid)
+			if err != nil {
+				mockError(w, err)
+				return
+			}
+
+			values := make([]PoolJSON, len(lb.Pools))
+			for idx := range lb.Pools {
+				values[idx] = mockPoolJSON(&lb.Pools[idx])
+			}
+
+			mockJSON(w, http.StatusOK, mockListingJSON{Links: ApiLinks{}, Values: values})
+			return
+		}
+
+		if r.Method == "POST" {
+			req := PoolEntityJSON{}
+			if json.NewDecoder(r.Body).Decode(&req) != nil {
+				mockStatus(w, http.StatusBadRequest)
+				return
+			}
+
+			pool, err := m.backend.createPool(dc, lbid, mockPoolFromEntity(&req))
+			if err != nil {
+				mockError(w, err)
+				return
+			}
+
+			m.saveState()
+			mockJSON(w, http.StatusAccepted, mockPoolRequestJSON(pool.Request, lbHref+"/pools/"+pool.PoolID, pool.PoolID))
+			return
+		}
+
+		mockStatus(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	poolID := rest[2]
+	if len(rest) > 3 {
+		mockStatus(w, http.StatusNotFound)
+		return
+	}
+
+	if r.Method == "GET" {
+		pool, err := m.backend.inspectPool(dc, lbid, poolID)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		mockJSON(w, http.StatusOK, mockPoolJSON(pool))
+		return
+	}
+
+	if r.Method == "PUT" {
+		req := PoolEntityJSON{}
+		if json.NewDecoder(r.Body).Decode(&req) != nil {
+			mockStatus(w, http.StatusBadRequest)
+			return
+		}
+
+		newpool := mockPoolFromEntity(&req)
+		newpool.PoolID = poolID // the URL says which pool, not the body
+
+		pool, err := m.backend.updatePool(dc, lbid, newpool)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		m.saveState()
+		mockJSON(w, http.StatusAccepted, mockPoolRequestJSON(pool.Request, lbHref+"/pools/"+poolID, poolID))
+		return
+	}
+
+	if r.Method == "DELETE" {
+		request, err := m.backend.deletePool(dc, lbid, poolID)
+		if err != nil {
+			mockError(w, err)
+			return
+		}
+
+		m.saveState()
+		mockJSON(w, http.StatusAccepted, mockPoolRequestJSON(request, lbHref+"/pools/"+poolID, poolID))
+		return
+	}
+
+	mockStatus(w, http.StatusMethodNotAllowed)
+}
+
+func (m *MockServer) saveState() {
+	m.mu.Lock()
+	path := m.statePath
+	m.mu.Unlock()
+
+	if path != "" {
+		err := m.backend.SaveState(path)
+		if err != nil {
+			sdkLog(fmt.Sprintf("mock server could not save state: %s", err.Error()))
+		}
+	}
+}
+
+//////////////// wire format helpers
+
+// the async request object LBaaS returns for changes; the fake completes them at once
+func mockRequestJSON(desc, rel, href, id string) *lbCreateRequestJSON {
+	now := time.Now().Unix()
+	return &lbCreateRequestJSON{
+		LBID:           fmt.Sprintf("request-%d", time.Now().UnixNano()),
+		Status:         "COMPLETE",
+		Description:    desc,
+		RequestDate:    now,
+		CompletionDate: now,
+		Links:          ApiLinks{LinkJSON{Rel: rel, Href: href, ID: id}},
+	}
+}
+
+// a pool change's request as the backend has it, which may still be pending.  Its self link is where
+// to poll it.
+func mockPoolRequestJSON(request *LBRequest, href, poolID string) *lbCreateRequestJSON {
+	ret := mockRequestJSON(request.Description, "pool", href, poolID)
+	ret.Links = append(ret.Links, LinkJSON{Rel: "self", Href: request.Href, ID: request.RequestID})
+	ret.LBID = request.RequestID
+	ret.Status = request.Status
+	if request.Status != "COMPLETE" {
+		ret.CompletionDate = 0
+	}
+	return ret
+}
+
+func mockPoolJSON(pool *PoolDetails) PoolJSON {
+	ret := PoolJSON{
+		PoolID:       pool.PoolID,
+		IncomingPort: pool.IncomingPort,
+		Method:       pool.Method,
+		Persistence:  pool.Persistence,
+		TimeoutMS:    pool.TimeoutMS,
+		Mode:         pool.Mode,
+		Nodes:        make(ApiNodes, len(pool.Nodes)),
+	}
+
+	for idx, node := range pool.Nodes {
+		ret.Nodes[idx] = NodeJSON{TargetIP: node.TargetIP, TargetPort: node.TargetPort}
+	}
+
+	if pool.Health != nil {
+		ret.Health = &HealthCheckJSON{
+			UnhealthyThreshold: pool.Health.Unhealthy,
+			HealthyThreshold:   pool.Health.Healthy,
+			IntervalSeconds:    pool.Health.Interval,
+			TargetPort:         pool.Health.TargetPort,
+			Mode:               pool.Health.Mode,
+		}
+	}
+
+	return ret
+}
+
+func mockPoolFromEntity(req *PoolEntityJSON) *PoolDetails {
+	pool := &PoolDetails{
+		PoolID:       req.PoolID,
+		IncomingPort: req.IncomingPort,
+		Method:       req.Method,
+		Persistence:  req.Persistence,
+		TimeoutMS:    req.TimeoutMS,
+		Mode:         req.Mode,
+		Nodes:        make([]PoolNode, len(req.Nodes)),
+	}
+
+	for idx, node := range req.Nodes {
+		pool.Nodes[idx] = PoolNode{TargetIP: node.TargetIP, TargetPort: node.TargetPort}
+	}
+
+	if req.Health != nil {
+		pool.Health = &HealthCheckDetails{
+			Unhealthy:  req.Health.UnhealthyThreshold,
+			Healthy:    req.Health.HealthyThreshold,
+			Interval:   req.Health.IntervalSeconds,
+			TargetPort: req.Health.TargetPort,
+			Mode:       req.Health.Mode,
+		}
+	}
+
+	return pool
+}
+
+func mockJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func mockStatus(w http.ResponseWriter, code int) {
+	mockJSON(w, code, map[string]string{"message": http.StatusText(code)})
+}
+
+// FakeClient errors carry the HTTP code to answer with; anything else is a 404 ("pool not found")
+func mockError(w http.ResponseWriter, err error) {
+	if herr, ok := err.(HttpError); ok && (herr.Code() >= 400) {
+		mockStatus(w, herr.Code())
+		return
+	}
+
+	mockStatus(w, http.StatusNotFound)
+}
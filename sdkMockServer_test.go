@@ -0,0 +1,167 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// the LB and pool calls of the real client, end to end against the mock
+func TestClientAgainstMock(t *testing.T) {
+	clc, mock := newMockClient(t)
+
+	dcs, err := clc.listAllDC()
+	if (err != nil) || (len(dcs) != 1) || (dcs[0].DCID != "WA1") {
+		t.Fatalf("listAllDC = %v, %v", dcs, err)
+	}
+
+	info, err := clc.createLB("wa1", "web", "frontends") // normalised to WA1
+	if err != nil {
+		t.Fatalf("createLB: %s", err.Error())
+	}
+
+	pool, err := clc.createPool("WA1", info.LBID, &PoolDetails{IncomingPort: 443, Method: "leastconn", Mode: "tcp",
+		Nodes: []PoolNode{{TargetIP: "10.0.0.1", TargetPort: 8443}, {TargetIP: "10.0.0.2", TargetPort: 8443}}})
+	if err != nil {
+		t.Fatalf("createPool: %s", err.Error())
+	}
+
+	lb, herr := clc.inspectLB("WA1", info.LBID)
+	if herr != nil {
+		t.Fatalf("inspectLB: %s", herr.Error())
+	}
+	if (lb.Name != "web") || (len(lb.Pools) != 1) || (lb.Pools[0].PoolID != pool.PoolID) || (len(lb.Pools[0].Nodes) != 2) {
+		t.Errorf("inspectLB = %+v", lb)
+	}
+	if (lb.Pools[0].IncomingPort != 443) || (lb.Pools[0].Nodes[1].TargetIP != "10.0.0.2") || (lb.Pools[0].Nodes[1].TargetPort != 8443) {
+		t.Errorf("pool came back as %+v", lb.Pools[0])
+	}
+
+	mock.ExpireTokens() // the next call gets 401, logs in again and is sent again
+	logins := mock.Logins()
+
+	list, err := clc.listAllLB()
+	if (err != nil) || (len(list) != 1) || (list[0].LBID != info.LBID) {
+		t.Fatalf("listAllLB after the tokens expired = %v, %v", list, err)
+	}
+	if got := mock.Logins() - logins; got != 1 {
+		t.Errorf("%d logins after the tokens expired, want 1", got)
+	}
+
+	if _, err := clc.deletePool("WA1", info.LBID, pool.PoolID); err != nil {
+		t.Fatalf("deletePool: %s", err.Error())
+	}
+	if _, err := clc.deleteLB("WA1", info.LBID); err != nil {
+		t.Fatalf("deleteLB: %s", err.Error())
+	}
+
+	if _, herr := clc.inspectLB("WA1", info.LBID); (herr == nil) || (herr.Code() != 404) {
+		t.Errorf("inspectLB of a deleted LB = %v, want a 404", herr)
+	}
+}
+
+func TestMockRejectsBadPassword(t *testing.T) {
+	mock := NewMockServer("TEST", "testuser", "testpassword", DataCenterName{DCID: "WA1"})
+	srv, err := mock.StartTLS("")
+	if err != nil {
+		t.Fatalf("StartTLS: %s", err.Error())
+	}
+	defer srv.Close()
+
+	addr := srv.Listener.Addr().String()
+	_, err = ClientLogin("testuser", "wrong", WithServers(addr, addr), WithLogger(NewTextLogger(io.Discard, nil)))
+	if err == nil {
+		t.Fatalf("login with the wrong password succeeded")
+	}
+	if mock.Logins() != 0 {
+		t.Errorf("Logins() = %d after a refused login", mock.Logins())
+	}
+}
+
+// handlers save the state file concurrently, it has to end up whole and current
+func TestMockStateFileConcurrentSaves(t *testing.T) {
+	clc, mock := newMockClient(t)
+	path := filepath.Join(t.TempDir(), "mock.json")
+	if err := mock.SetStateFile(path); err != nil {
+		t.Fatalf("SetStateFile: %s", err.Error())
+	}
+
+	const n = 8
+	var wg sync.WaitGroup
+	for idx := 0; idx < n; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := clc.createLB("WA1", "web", "frontends"); err != nil {
+				t.Errorf("createLB: %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded := NewFakeClient("TEST", DataCenterName{DCID: "WA1"})
+	if err := loaded.LoadState(path); err != nil {
+		t.Fatalf("LoadState: %s", err.Error())
+	}
+	if list, _ := loaded.listAllLB(); len(list) != n {
+		t.Errorf("state file has %d LBs, want %d", len(list), n)
+	}
+
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+// LBaaS is found by asking the LB API, not from the v2 shared-LB flag, which the mock leaves false everywhere
+func TestLBServiceProbedAgainstMock(t *testing.T) {
+	mock := NewMockServer("TEST", "testuser", "testpassword",
+		DataCenterName{DCID: "WA1", Name: "WA1 - US West (Seattle)"}, DataCenterName{DCID: "UC1", Name: "UC1 - US West (Santa Clara)"})
+	mock.Backend().SetLBService("UC1", false)
+	srv, err := mock.StartTLS("")
+	if err != nil {
+		t.Fatalf("StartTLS: %s", err.Error())
+	}
+	t.Cleanup(srv.Close)
+
+	addr := srv.Listener.Addr().String()
+	clc, err := ClientLogin("testuser", "testpassword", WithServers(addr, addr), WithRateLimit(RateLimit{}, RateLimit{}),
+		WithLogger(NewTextLogger(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("ClientLogin: %s", err.Error())
+	}
+
+	for dc, want := range map[string]bool{"WA1": true, "uc1": false} {
+		details, err := InspectDataCenter(clc, dc)
+		if err != nil {
+			t.Fatalf("InspectDataCenter(%s): %s", dc, err.Error())
+		}
+		if (details.LoadBalancing != want) || details.Capabilities.SupportsSharedLoadBalancer {
+			t.Errorf("InspectDataCenter(%s) = loadBalancing %v, shared %v", dc, details.LoadBalancing, details.Capabilities.SupportsSharedLoadBalancer)
+		}
+	}
+
+	if _, err := clc.createLB("WA1", "web", "frontends"); err != nil {
+		t.Errorf("createLB in WA1: %s", err.Error())
+	}
+	_, err = clc.createLB("UC1", "web", "frontends")
+	if herr, ok := err.(HttpError); !ok || (herr.Code() != HTTP_ERROR_NOSERVICE) {
+		t.Errorf("createLB in UC1 = %v, want HTTP_ERROR_NOSERVICE", err)
+	}
+}
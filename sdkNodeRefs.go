@@ -0,0 +1,233 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+//// pool nodes may name servers instead of giving IPs:  server:WA1ACMEWEB01 is that server's private
+//// IP, group:web-tier the private IPs of the active servers in that group and its subgroups.  They
+//// are looked up when the command runs, so a server that was rebuilt with a new IP is picked up.
+
+const (
+	NODE_REF_SERVER = "server:"
+	NODE_REF_GROUP  = "group:"
+)
+
+// what one reference came to, Servers[i] having IPs[i]
+type NodeResolution struct {
+	Ref     string   `json:"ref"`
+	Servers []string `json:"servers"`
+	IPs     []string `json:"ips"`
+}
+
+func isNodeRef(s string) bool {
+	return strings.HasPrefix(s, NODE_REF_SERVER) || strings.HasPrefix(s, NODE_REF_GROUP)
+}
+
+// only these count as members of a group; one being built or deleted has no business in a pool
+func isActiveServer(server *ServerDetails) bool {
+	return server.Status == "active"
+}
+
+// ResolvePoolNodes replaces each reference in pool.Nodes with a node per IP, on the reference's target
+// port.  IPs are kept as they are.  A node that comes up twice (a server named and also in a named
+// group) is kept once.  Servers must be in dc, the pool's datacenter.
+func ResolvePoolNodes(clc CenturyLinkClient, dc string, pool *PoolDetails) ([]NodeResolution, error) {
+	resolutions := make([]NodeResolution, 0)
+	nodes := make([]PoolNode, 0, len(pool.Nodes))
+	seen := make(map[string]bool)
+	var root *GroupDetails // fetched for the first group: reference
+
+	add := func(node PoolNode) {
+		key := fmt.Sprintf("%s:%d", node.TargetIP, node.TargetPort)
+		if !seen[key] {
+			seen[key] = true
+			nodes = append(nodes, node)
+		}
+	}
+
+	for _, node := range pool.Nodes {
+		if !isNodeRef(node.TargetIP) {
+			add(node)
+			continue
+		}
+
+		var servers []ServerDetails
+		var err error
+
+		if strings.HasPrefix(node.TargetIP, NODE_REF_SERVER) {
+			servers, err = resolveServerRef(clc, dc, strings.TrimPrefix(node.TargetIP, NODE_REF_SERVER))
+		} else {
+			if root == nil {
+				root, err = inspectDCGroups(clc, dc)
+				if err != nil {
+					return nil, err
+				}
+			}
+			servers, err = resolveGroupRef(clc, root, strings.TrimPrefix(node.TargetIP, NODE_REF_GROUP))
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", node.TargetIP, err.Error())
+		}
+
+		resolution := NodeResolution{Ref: node.TargetIP, Servers: make([]string, 0), IPs: make([]string, 0)}
+		for idx := range servers {
+			ip := servers[idx].PrivateIP()
+			resolution.Servers = append(resolution.Servers, servers[idx].ServerID)
+			resolution.IPs = append(resolution.IPs, ip)
+			add(PoolNode{TargetIP: ip, TargetPort: node.TargetPort})
+		}
+		resolutions = append(resolutions, resolution)
+	}
+
+	pool.Nodes = nodes
+	return resolutions, nil
+}
+
+func resolveServerRef(clc CenturyLinkClient, dc, serverID string) ([]ServerDetails, error) {
+	server, err := clc.inspectServer(serverID)
+	if err != nil {
+		if herr, ok := err.(HttpError); ok && (herr.Code() == 404) {
+			return nil, fmt.Errorf("no such server")
+		}
+		return nil, err
+	}
+
+	if server.DataCenter != NormalizeDC(dc) {
+		return nil, fmt.Errorf("server is in %s, not %s", server.DataCenter, NormalizeDC(dc))
+	}
+	if server.PrivateIP() == "" {
+		return nil, fmt.Errorf("server has no private IP")
+	}
+
+	return []ServerDetails{*server}, nil
+}
+
+// the active servers under the one group with this name (or ID), those without an IP left out
+func resolveGroupRef(clc CenturyLinkClient, root *GroupDetails, name string) ([]ServerDetails, error) {
+	group, err := root.FindOne(name)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := InspectServers(clc, group.AllServerIDs())
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]ServerDetails, 0, len(servers))
+	for idx := range servers {
+		if isActiveServer(&servers[idx]) && (servers[idx].PrivateIP() != "") {
+			ret = append(ret, servers[idx])
+		}
+	}
+
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("group has no active servers")
+	}
+
+	return ret, nil
+}
+
+// the DC's root group, with all the groups under it
+func inspectDCGroups(clc CenturyLinkClient, dc string) (*GroupDetails, error) {
+	rootID, err := clc.rootGroupID(dc)
+	if err != nil {
+		return nil, err
+	}
+
+	return clc.inspectGroup(rootID)
+}
+
+//////////////// pool sync
+
+type PoolSyncResult struct {
+	Pool       *PoolDetails   `json:"pool"` // after the sync, or as it would be
+	Resolution NodeResolution `json:"resolution"`
+	Added      []PoolNode     `json:"added"`
+	Removed    []PoolNode     `json:"removed"`
+	Updated    bool           `json:"updated"` // false if already in sync, or a dry run
+}
+
+// SyncPoolToGroup makes a pool's nodes the active servers of a group, and nothing else.  New nodes
+// get targetPort; 0 means the port the pool's nodes already share, or 8080.  The pool is only updated
+// if something changed, and never with dryRun; the update's request is waited for.
+func SyncPoolToGroup(clc CenturyLinkClient, dc, lbid, poolID, group string, targetPort int, dryRun bool) (*PoolSyncResult, error) {
+	pool, err := clc.inspectPool(dc, lbid, poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetPort == 0 {
+		targetPort = commonTargetPort(pool.Nodes)
+	}
+
+	want := copyPool(pool)
+	want.Nodes = []PoolNode{{TargetIP: NODE_REF_GROUP + group, TargetPort: targetPort}}
+	resolutions, err := ResolvePoolNodes(clc, dc, want)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PoolSyncResult{Pool: want, Resolution: resolutions[0]}
+	result.Added, result.Removed = diffNodes(want.Nodes, pool.Nodes)
+	if result.Added == nil {
+		result.Added = make([]PoolNode, 0)
+	}
+	if result.Removed == nil {
+		result.Removed = make([]PoolNode, 0)
+	}
+
+	if dryRun || ((len(result.Added) == 0) && (len(result.Removed) == 0)) {
+		return result, nil
+	}
+
+	updated, err := clc.updatePool(dc, lbid, want)
+	if err != nil {
+		return nil, err
+	}
+
+	if updated.Request != nil {
+		err = WaitLBRequest(clc, updated.Request, lbReadyTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Pool = updated
+	result.Updated = true
+	return result, nil
+}
+
+// the port all the nodes have, defaultTargetPort if they differ or there are none
+func commonTargetPort(nodes []PoolNode) int {
+	if len(nodes) == 0 {
+		return defaultTargetPort
+	}
+
+	for _, node := range nodes {
+		if node.TargetPort != nodes[0].TargetPort {
+			return defaultTargetPort
+		}
+	}
+
+	return nodes[0].TargetPort
+}
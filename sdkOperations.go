@@ -0,0 +1,79 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+//// many v2 changes (public IPs, power operations, new servers) are queued: the call returns at once
+//// with the ID of an operation, whose status says when the change has actually happened.
+
+const ( // operation status values, as the API reports them
+	OPERATION_NOT_STARTED = "notStarted"
+	OPERATION_EXECUTING   = "executing"
+	OPERATION_SUCCEEDED   = "succeeded"
+	OPERATION_FAILED      = "failed"
+	OPERATION_RESUMED     = "resumed"
+)
+
+// a queued change, for those who don't wait for it
+type OperationRef struct {
+	OperationID string `json:"operationID"`
+	Status      string `json:"status"`
+	JobID       string `json:"jobID,omitempty"` // tracking it, see sdkJobs.go
+}
+
+var operationTimeout = 15 * time.Minute
+
+func operationDone(status string) bool {
+	return (status == OPERATION_SUCCEEDED) || (status == OPERATION_FAILED)
+}
+
+// WaitForOperation tracks the operation as a job (see TrackOperation) and waits until it succeeds (nil),
+// fails or takes longer than timeout
+func WaitForOperation(clc CenturyLinkClient, operationID, description string, timeout time.Duration) error {
+	return clc.jobTracker().Wait(TrackOperation(clc, operationID, description, timeout))
+}
+
+//////////////// wire format
+
+type operationStatusJSON struct {
+	Status string `json:"status"`
+}
+
+//////////////// clc method: operationStatus()
+
+func (clc *clcImpl) operationStatus(operationID string) (string, error) {
+	uri := fmt.Sprintf("/v2/operations/%s/status/%s", clc.creds.GetAccount(), operationID)
+	apiret := &operationStatusJSON{}
+
+	cfg := clc.config()
+	err := simpleGET(cfg, cfg.serverAPIV2, uri, clc.creds, apiret)
+	if err != nil {
+		return "", err
+	}
+
+	return apiret.Status, nil
+}
+
+//////////////// clc method: jobTracker()
+
+func (clc *clcImpl) jobTracker() *JobTracker {
+	return clc.config().jobs
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//// a token bucket per host, so bulk work (InspectAll, apply) stays under the API's limits instead of
+//// collecting 429s.  Each call takes a token first, waiting if there is none.  A 429 halves the rate
+//// and pauses the host for its Retry-After; the rate then creeps back up with each success.
+//// X-RateLimit-Remaining: 0 also pauses the host, until X-RateLimit-Reset.
+
+type RateLimit struct {
+	PerSecond float64 // 0 means no limit, though 429s still pause
+	Burst     int     // tokens a quiet host saves up, 0 for one second's worth
+}
+
+var defaultRateLimit = RateLimit{PerSecond: 20, Burst: 20}
+
+const (
+	rateLimitFloor     = 0.5 // per second, however many 429s
+	rateLimitRecovery  = 1.05
+	defaultRetryAfter  = 2 * time.Second // for a 429 that doesn't say
+	maxRetryAfter      = 5 * time.Minute
+	resetIsEpochCutoff = 1000000000 // larger X-RateLimit-Reset values are a unix time, smaller are seconds
+)
+
+// HostStats is what "stats" shows for one host
+type HostStats struct {
+	Host      string        `json:"host"`
+	Calls     int           `json:"calls"`
+	Throttled int           `json:"throttled"` // 429 responses
+	Waits     int           `json:"waits"`     // calls that had to wait for a token or a pause
+	Waited    time.Duration `json:"waitedNS"`
+	Limit     float64       `json:"limitPerSecond"`   // configured
+	Rate      float64       `json:"currentPerSecond"` // after adapting to 429s
+}
+
+type rateLimiter struct {
+	mu          sync.Mutex
+	limit       RateLimit
+	rate        float64 // current, <= limit.PerSecond
+	tokens      float64
+	last        time.Time // of the last refill
+	pausedUntil time.Time
+	stats       HostStats
+}
+
+func newRateLimiter(host string, limit RateLimit) *rateLimiter {
+	if (limit.Burst <= 0) && (limit.PerSecond > 0) {
+		limit.Burst = int(limit.PerSecond + 0.5)
+		if limit.Burst < 1 {
+			limit.Burst = 1
+		}
+	}
+
+	return &rateLimiter{
+		limit:  limit,
+		rate:   limit.PerSecond,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+		stats:  HostStats{Host: host, Limit: limit.PerSecond},
+	}
+}
+
+// wait blocks until the call may go ahead
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Calls++
+	var delay time.Duration
+
+	now := time.Now()
+	if now.Before(l.pausedUntil) {
+		delay = l.pausedUntil.Sub(now)
+		now = l.pausedUntil
+	}
+
+	if l.rate > 0 {
+		if now.After(l.last) { // last may be ahead, at the end of a pause
+			l.tokens += now.Sub(l.last).Seconds() * l.rate
+			if l.tokens > float64(l.limit.Burst) {
+				l.tokens = float64(l.limit.Burst)
+			}
+			l.last = now
+		}
+
+		l.tokens-- // may go negative, which is the queue of callers ahead of this one
+		if l.tokens < 0 {
+			delay += time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+
+	if delay <= 0 {
+		return
+	}
+
+	l.stats.Waits++
+	l.stats.Waited += delay
+
+	l.mu.Unlock() // sleep without the lock, others compute their own place in line meanwhile
+	time.Sleep(delay)
+	l.mu.Lock()
+}
+
+// observe adjusts to a response, and returns true if it was a 429
+func (l *rateLimiter) observe(resp *http.Response) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if resp.StatusCode == 429 {
+		l.stats.Throttled++
+		l.pause(retryAfter(resp.Header.Get("Retry-After")))
+
+		if l.rate > 0 {
+			l.rate /= 2
+			if l.rate < rateLimitFloor {
+				l.rate = rateLimitFloor
+			}
+		}
+		return true
+	}
+
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining == "0" {
+		l.pause(rateLimitReset(resp.Header.Get("X-RateLimit-Reset")))
+	}
+
+	if l.rate < l.limit.PerSecond {
+		l.rate *= rateLimitRecovery
+		if l.rate > l.limit.PerSecond {
+			l.rate = l.limit.PerSecond
+		}
+	}
+
+	return false
+}
+
+func (l *rateLimiter) pause(d time.Duration) {
+	if d > maxRetryAfter {
+		d = maxRetryAfter
+	}
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *rateLimiter) snapshot() HostStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ret := l.stats
+	ret.Rate = l.rate
+	return ret
+}
+
+// Retry-After is seconds or an HTTP date
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return defaultRetryAfter
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when)
+	}
+
+	return defaultRetryAfter
+}
+
+func rateLimitReset(value string) time.Duration {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return defaultRetryAfter
+	}
+
+	if n > resetIsEpochCutoff {
+		return time.Until(time.Unix(n, 0))
+	}
+	return time.Duration(n) * time.Second
+}
+
+//////////////// per client, one limiter per host
+
+type hostLimiters struct {
+	mu       sync.Mutex
+	apiV2    RateLimit
+	lb       RateLimit
+	limiters map[string]*rateLimiter
+}
+
+func newHostLimiters(apiV2, lb RateLimit) *hostLimiters {
+	return &hostLimiters{apiV2: apiV2, lb: lb, limiters: make(map[string]*rateLimiter)}
+}
+
+// the LB limit applies to the LB host, the v2 limit to anything else
+func (h *hostLimiters) forHost(cfg *clcConfig, host string) *rateLimiter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	l, found := h.limiters[host]
+	if !found {
+		limit := h.apiV2
+		if (host == cfg.serverLB) && (host != cfg.serverAPIV2) {
+			limit = h.lb
+		}
+
+		l = newRateLimiter(host, limit)
+		h.limiters[host] = l
+	}
+
+	return l
+}
+
+func (h *hostLimiters) stats() []HostStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ret := make([]HostStats, 0, len(h.limiters))
+	for _, l := range h.limiters {
+		ret = append(ret, l.snapshot())
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Host < ret[j].Host })
+	return ret
+}
@@ -0,0 +1,660 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//// a small YAML writer, enough for our own objects without pulling in a yaml library.
+//// Objects go through encoding/json first, so the json tags decide the field names and
+//// omitempty behaves the same in both formats.  Field order is kept.
+
+const (
+	yamlScalar = iota
+	yamlMap
+	yamlList
+)
+
+type yamlNode struct {
+	kind   int
+	scalar interface{} // string, json.Number, bool or nil
+	keys   []string    // yamlMap, in document order
+	values []*yamlNode // yamlMap values parallel to keys, or yamlList items
+}
+
+func yamlMarshal(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	node, err := yamlFromJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(bytes.Buffer)
+	if (node.kind != yamlScalar) && (len(node.values) == 0) {
+		if node.kind == yamlMap {
+			out.WriteString("{}\n")
+		} else {
+			out.WriteString("[]\n")
+		}
+	} else if node.kind == yamlScalar {
+		out.WriteString(yamlScalarText(node.scalar) + "\n")
+	} else {
+		yamlWrite(out, node, 0)
+	}
+
+	return out.Bytes(), nil
+}
+
+// builds the ordered tree from the json token stream
+func yamlFromJSON(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	delim, isDelim := tok.(json.Delim)
+	if !isDelim {
+		return &yamlNode{kind: yamlScalar, scalar: tok}, nil
+	}
+
+	if delim == '{' {
+		node := &yamlNode{kind: yamlMap}
+		for dec.More() {
+			keytok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := yamlFromJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			node.keys = append(node.keys, fmt.Sprint(keytok))
+			node.values = append(node.values, val)
+		}
+		_, err = dec.Token() // closing }
+		return node, err
+	}
+
+	node := &yamlNode{kind: yamlList}
+	for dec.More() {
+		val, err := yamlFromJSON(dec)
+		if err != nil {
+			return nil, err
+		}
+		node.values = append(node.values, val)
+	}
+	_, err = dec.Token() // closing ]
+	return node, err
+}
+
+func yamlIsEmptyCollection(node *yamlNode) bool {
+	return (node.kind != yamlScalar) && (len(node.values) == 0)
+}
+
+// inline form for scalars and empty collections, "" if the node needs block form
+func yamlInline(node *yamlNode) string {
+	if node.kind == yamlScalar {
+		return yamlScalarText(node.scalar)
+	} else if yamlIsEmptyCollection(node) && (node.kind == yamlMap) {
+		return "{}"
+	} else if yamlIsEmptyCollection(node) {
+		return "[]"
+	}
+
+	return ""
+}
+
+// writes a non-empty map or list in block form, at the given indent
+func yamlWrite(out io.Writer, node *yamlNode, indent int) {
+	pad := strings.Repeat(" ", indent)
+
+	if node.kind == yamlMap {
+		for idx, key := range node.keys {
+			val := node.values[idx]
+			if s := yamlInline(val); s != "" {
+				fmt.Fprintf(out, "%s%s: %s\n", pad, yamlScalarText(key), s)
+			} else if val.kind == yamlList {
+				fmt.Fprintf(out, "%s%s:\n", pad, yamlScalarText(key))
+				yamlWrite(out, val, indent) // lists sit at the same indent as their key
+			} else {
+				fmt.Fprintf(out, "%s%s:\n", pad, yamlScalarText(key))
+				yamlWrite(out, val, indent+2)
+			}
+		}
+		return
+	}
+
+	for _, item := range node.values {
+		if s := yamlInline(item); s != "" {
+			fmt.Fprintf(out, "%s- %s\n", pad, s)
+			continue
+		}
+
+		// write the item 2 deeper, then put the dash over the first line's indent
+		sub := new(bytes.Buffer)
+		yamlWrite(sub, item, indent+2)
+		text := sub.String()
+		fmt.Fprintf(out, "%s- %s", pad, text[indent+2:])
+	}
+}
+
+func yamlScalarText(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return t.String()
+	case string:
+		if yamlNeedsQuotes(t) {
+			b, _ := json.Marshal(t) // a JSON string is a valid YAML double-quoted scalar
+			return string(b)
+		}
+		return t
+	}
+
+	return fmt.Sprint(v)
+}
+
+func yamlNeedsQuotes(s string) bool {
+	if s == "" {
+		return true
+	}
+
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return true
+	}
+
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@` \t") || strings.HasSuffix(s, " ") {
+		return true
+	}
+
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s, "\n\r\t\\") {
+		return true
+	}
+
+	if _, err := json.Number(s).Float64(); err == nil { // would read back as a number
+		return true
+	}
+
+	return false
+}
+
+//// and a small YAML reader for the block-style subset people write by hand, and that yamlMarshal
+//// writes: nested maps and lists, plain/quoted scalars, # comments, and one-line flow [..] / {..}.
+//// Multi-line scalars (| and >), anchors and multiple documents are not supported.
+
+// yamlUnmarshal reads YAML (or JSON, which starts with { or [) into v through encoding/json,
+// so the json tags apply.  Unknown fields are an error, to catch typos in config files.
+func yamlUnmarshal(data []byte, v interface{}) error {
+	trimmed := bytes.TrimSpace(data)
+	if (len(trimmed) > 0) && ((trimmed[0] == '{') || (trimmed[0] == '[')) {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+	}
+
+	generic, err := yamlParse(data)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+type yamlLine struct {
+	num    int // 1-based, for error messages
+	indent int
+	text   string // comment and indent removed
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func yamlParse(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+
+	for idx, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := strings.TrimRight(yamlStripComment(raw), " \t")
+		content := strings.TrimLeft(text, " ")
+		if (content == "") || (content == "---") || (content == "...") {
+			continue
+		}
+
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", idx+1)
+		}
+
+		p.lines = append(p.lines, yamlLine{num: idx + 1, indent: len(text) - len(content), text: content})
+	}
+
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	v, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+
+	return v, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	num := 0
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+
+	return fmt.Errorf("yaml line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+func yamlIsDash(text string) bool {
+	return (text == "-") || strings.HasPrefix(text, "- ")
+}
+
+// parses the map or list whose lines start at exactly this indent
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if yamlIsDash(p.lines[p.pos].text) {
+		return p.parseList(indent)
+	}
+
+	if _, _, isKey := yamlSplitKey(p.lines[p.pos].text); !isKey {
+		line := p.lines[p.pos]
+		p.pos++
+		return yamlParseValue(line.text, line.num) // a lone scalar
+	}
+
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseList(indent int) (interface{}, error) {
+	list := make([]interface{}, 0)
+
+	for (p.pos < len(p.lines)) && (p.lines[p.pos].indent == indent) && yamlIsDash(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+
+		if rest == "" { // item is on the following lines, or is null
+			p.pos++
+			if (p.pos < len(p.lines)) && (p.lines[p.pos].indent > indent) {
+				item, err := p.parseBlock(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, item)
+			} else {
+				list = append(list, nil)
+			}
+			continue
+		}
+
+		// "- key: value" starts a map whose other keys line up with key, so re-read this
+		// line as if the dash were indentation
+		p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+		item, err := p.parseBlock(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+
+	return list, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+
+	for (p.pos < len(p.lines)) && (p.lines[p.pos].indent == indent) {
+		line := p.lines[p.pos]
+		if yamlIsDash(line.text) {
+			return nil, p.errorf("list item where a map key was expected")
+		}
+
+		key, value, isKey := yamlSplitKey(line.text)
+		if !isKey {
+			return nil, p.errorf("expected key: value")
+		}
+
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+
+		p.pos++
+
+		if value != "" {
+			v, err := yamlParseValue(value, line.num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+
+		} else if (p.pos < len(p.lines)) && (p.lines[p.pos].indent > indent) {
+			v, err := p.parseBlock(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+
+		} else if (p.pos < len(p.lines)) && (p.lines[p.pos].indent == indent) && yamlIsDash(p.lines[p.pos].text) {
+			v, err := p.parseList(indent) // list at the same indent as its key
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+
+		} else {
+			m[key] = nil
+		}
+	}
+
+	if (p.pos < len(p.lines)) && (p.lines[p.pos].indent > indent) {
+		return nil, p.errorf("unexpected indentation")
+	}
+
+	return m, nil
+}
+
+// removes a # comment, which must be at the start or follow whitespace, and not be inside quotes
+func yamlStripComment(s string) string {
+	var quote byte
+	for idx := 0; idx < len(s); idx++ {
+		c := s[idx]
+		if quote != 0 {
+			if (c == '\\') && (quote == '"') {
+				idx++
+			} else if c == quote {
+				quote = 0
+			}
+		} else if (c == '"') || (c == '\'') {
+			quote = c
+		} else if (c == '#') && ((idx == 0) || (s[idx-1] == ' ') || (s[idx-1] == '\t')) {
+			return s[:idx]
+		}
+	}
+
+	return s
+}
+
+// "key: value" or "key:" outside of quotes.  Keys may be quoted.
+func yamlSplitKey(text string) (string, string, bool) {
+	var quote byte
+	for idx := 0; idx < len(text); idx++ {
+		c := text[idx]
+		if quote != 0 {
+			if (c == '\\') && (quote == '"') {
+				idx++
+			} else if c == quote {
+				quote = 0
+			}
+		} else if ((c == '"') || (c == '\'')) && (idx == 0) {
+			quote = c
+		} else if (c == '[') || (c == '{') {
+			if idx == 0 {
+				return "", "", false // flow value, not a key
+			}
+		} else if (c == ':') && ((idx+1 == len(text)) || (text[idx+1] == ' ')) {
+			key := strings.TrimSpace(text[:idx])
+			if (len(key) >= 2) && ((key[0] == '"') || (key[0] == '\'')) {
+				unq, err := yamlUnquote(key)
+				if err != nil {
+					return "", "", false
+				}
+				key = unq
+			}
+			return key, strings.TrimSpace(text[idx+1:]), true
+		}
+	}
+
+	return "", "", false
+}
+
+func yamlParseValue(s string, num int) (interface{}, error) {
+	if (s == "|") || (s == ">") || strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">") {
+		return nil, fmt.Errorf("yaml line %d: multi-line scalars are not supported", num)
+	}
+	if strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*") || strings.HasPrefix(s, "!") {
+		return nil, fmt.Errorf("yaml line %d: anchors, aliases and tags are not supported", num)
+	}
+
+	if (s[0] == '[') || (s[0] == '{') {
+		f := &yamlFlow{text: s}
+		v, err := f.parse()
+		if err == nil {
+			f.skipSpace()
+			if f.pos < len(f.text) {
+				err = fmt.Errorf("unexpected %q after flow value", f.text[f.pos:])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %s", num, err.Error())
+		}
+		return v, nil
+	}
+
+	if (s[0] == '"') || (s[0] == '\'') {
+		v, err := yamlUnquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %s", num, err.Error())
+		}
+		return v, nil
+	}
+
+	return yamlPlainScalar(s), nil
+}
+
+func yamlUnquote(s string) (string, error) {
+	if (len(s) < 2) || (s[len(s)-1] != s[0]) {
+		return "", fmt.Errorf("unterminated quoted string %s", s)
+	}
+
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+
+	return strconv.Unquote(s)
+}
+
+func yamlPlainScalar(s string) interface{} {
+	switch s {
+	case "null", "Null", "NULL", "~":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(s)
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); (err == nil) && !strings.ContainsAny(s, "xXpP") {
+		if strings.ContainsAny(s, "0123456789") { // not inf or nan
+			return json.Number(s)
+		}
+	}
+
+	return s
+}
+
+// one-line flow collections: [a, "b", {c: 1}]
+type yamlFlow struct {
+	text string
+	pos  int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for (f.pos < len(f.text)) && (f.text[f.pos] == ' ') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) parse() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.text) {
+		return nil, fmt.Errorf("unexpected end of flow value")
+	}
+
+	c := f.text[f.pos]
+	if c == '[' {
+		f.pos++
+		list := make([]interface{}, 0)
+		for {
+			f.skipSpace()
+			if (f.pos < len(f.text)) && (f.text[f.pos] == ']') {
+				f.pos++
+				return list, nil
+			}
+
+			item, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if c == '{' {
+		f.pos++
+		m := make(map[string]interface{})
+		for {
+			f.skipSpace()
+			if (f.pos < len(f.text)) && (f.text[f.pos] == '}') {
+				f.pos++
+				return m, nil
+			}
+
+			keyv, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprint(keyv)
+
+			f.skipSpace()
+			if (f.pos >= len(f.text)) || (f.text[f.pos] != ':') {
+				return nil, fmt.Errorf("expected : after %s", key)
+			}
+			f.pos++
+
+			val, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			m[key] = val
+
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return f.scalar(false)
+}
+
+// after an item: either a comma, or the closing bracket (left for the caller's loop)
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	if f.pos >= len(f.text) {
+		return fmt.Errorf("missing %c", closing)
+	}
+
+	if f.text[f.pos] == ',' {
+		f.pos++
+		return nil
+	} else if f.text[f.pos] == closing {
+		return nil
+	}
+
+	return fmt.Errorf("expected , or %c", closing)
+}
+
+func (f *yamlFlow) scalar(isKey bool) (interface{}, error) {
+	f.skipSpace()
+	start := f.pos
+
+	if (f.pos < len(f.text)) && ((f.text[f.pos] == '"') || (f.text[f.pos] == '\'')) {
+		quote := f.text[f.pos]
+		f.pos++
+		for f.pos < len(f.text) {
+			if (f.text[f.pos] == '\\') && (quote == '"') {
+				f.pos += 2
+				continue
+			}
+			if f.text[f.pos] == quote {
+				if (quote == '\'') && (f.pos+1 < len(f.text)) && (f.text[f.pos+1] == '\'') {
+					f.pos += 2
+					continue
+				}
+				f.pos++
+				return yamlUnquote(f.text[start:f.pos])
+			}
+			f.pos++
+		}
+		return nil, fmt.Errorf("unterminated quoted string")
+	}
+
+	for f.pos < len(f.text) {
+		c := f.text[f.pos]
+		if (c == ',') || (c == ']') || (c == '}') || (isKey && (c == ':')) {
+			break
+		}
+		f.pos++
+	}
+
+	return yamlPlainScalar(strings.TrimSpace(f.text[start:f.pos])), nil
+}